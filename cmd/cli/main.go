@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bysoft-wallet/users/internal/adapters"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `Usage: cli <command> [flags]
+
+Commands:
+  create-admin   create a user with the admin role or grant it to an existing user
+  create-client  register a machine client for the client_credentials grant
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "create-admin":
+		createAdmin(ctx, os.Args[2:])
+	case "create-client":
+		createClient(ctx, os.Args[2:])
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+func createAdmin(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := flags.String("email", "", "admin email")
+	name := flags.String("name", "Admin", "admin name, used when the user is created")
+	password := flags.String("password", "", "admin password, used when the user is created")
+	flags.Parse(args)
+
+	if *email == "" {
+		fmt.Println("-email must be provided")
+		os.Exit(1)
+	}
+
+	pool := connect(ctx)
+	defer pool.Close()
+
+	authService := service.NewAuthService(
+		adapters.NewUserPgsqlRepository(pool),
+		jwt.NewJwtService(&jwt.JWTConfig{Secret: os.Getenv("JWT_SECRET")}),
+		adapters.NewRefreshPgsqlRepository(pool),
+		adapters.NewAPIKeyPgsqlRepository(pool),
+		adapters.NewRolePgsqlRepository(pool),
+		adapters.NewPgsqlTxManager(pool),
+		adapters.NewOutboxPgsqlRepository(pool),
+		adapters.NewAuditPgsqlRepository(pool),
+		0,
+	)
+
+	if *password == "" {
+		if _, err := authService.GetUserByEmail(ctx, *email); err != nil {
+			fmt.Println("-password must be provided to create a new admin")
+			os.Exit(1)
+		}
+	}
+
+	u, err := authService.BootstrapAdmin(ctx, *email, *name, *password)
+	if err != nil {
+		fmt.Printf("Could not create admin: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Admin %s (%s) is ready\n", u.Email, u.UUID)
+}
+
+func createClient(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet("create-client", flag.ExitOnError)
+	id := flags.String("id", "", "client id, e.g. transactions")
+	name := flags.String("name", "", "human readable client name")
+	scopes := flags.String("scopes", "", "comma separated scopes allowed to the client")
+	flags.Parse(args)
+
+	if *id == "" {
+		fmt.Println("-id must be provided")
+		os.Exit(1)
+	}
+
+	pool := connect(ctx)
+	defer pool.Close()
+
+	clientService := service.NewClientService(adapters.NewClientPgsqlRepository(pool), nil)
+
+	var allowed []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	created, err := clientService.CreateClient(ctx, &service.CreateClientRequest{
+		ID:     *id,
+		Name:   *name,
+		Scopes: allowed,
+	})
+	if err != nil {
+		fmt.Printf("Could not create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Client %s is created, secret (shown once): %s\n", created.Client.ID, created.Secret)
+}
+
+func connect(ctx context.Context) *pgxpool.Pool {
+	for _, env := range []string{"POSTGRES_DB", "POSTGRES_USER", "POSTGRES_PASSWORD", "DB_HOST"} {
+		if os.Getenv(env) == "" {
+			fmt.Printf("%s configuration must be provided\n", env)
+			os.Exit(1)
+		}
+	}
+
+	postgresString := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("DB_HOST"),
+		os.Getenv("POSTGRES_DB"),
+	)
+
+	pool, err := pgxpool.New(ctx, postgresString)
+	if err != nil {
+		fmt.Printf("pgxpool init error %v\n", err)
+		os.Exit(1)
+	}
+
+	return pool
+}
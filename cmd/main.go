@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/config"
 	"github.com/bysoft-wallet/users/internal/ports"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,7 +21,9 @@ import (
 )
 
 func main() {
-	ctx := context.Background()
+	// the first SIGINT or SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	//init logger
 	f, err := os.OpenFile("logs/app-log.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
@@ -34,41 +41,30 @@ func main() {
 		},
 	}
 
-	// init db connection pool
-	queryLog, err := strconv.ParseBool(os.Getenv("ENABLE_QUERY_LOG"))
+	// init config
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		queryLog = false
-	}
-
-	postgresDB := os.Getenv("POSTGRES_DB")
-	if postgresDB == "" {
-		fmt.Println("POSTGRES_DB configuration must be provided")
+		logger.Errorf("Configuration error %v", err)
 		os.Exit(1)
 	}
 
-	postgresUser := os.Getenv("POSTGRES_USER")
-	if postgresUser == "" {
-		fmt.Println("POSTGRES_USER configuration must be provided")
-		os.Exit(1)
-	}
-
-	postgresPass := os.Getenv("POSTGRES_PASSWORD")
-	if postgresPass == "" {
-		fmt.Println("POSTGRES_PASSWORD configuration must be provided")
-		os.Exit(1)
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			logger.Errorf("Configuration print error %v", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		fmt.Println("DB_HOST configuration must be provided")
-		os.Exit(1)
-	}
+	logger.WithFields(logrus.Fields(cfg.Redacted())).Info("Configuration loaded")
 
-	postgresString := fmt.Sprintf("postgres://%s:%s@%s:5432/%s?sslmode=disable", postgresUser, postgresPass, dbHost, postgresDB)
+	// init db connection pool
+	postgresString := cfg.Database.URL()
 
 	var pool *pgxpool.Pool
 
-	if queryLog {
+	if cfg.Database.QueryLog {
 		//init query  logger
 		queryf, err := os.OpenFile("logs/query-log.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 		if err != nil {
@@ -86,14 +82,14 @@ func main() {
 			},
 		}
 
-		config, err := pgxpool.ParseConfig(postgresString)
+		poolConfig, err := pgxpool.ParseConfig(postgresString)
 		if err != nil {
 			logger.Errorf("pgxpool init error %v", err)
 			os.Exit(1)
 		}
 
-		config.ConnConfig.Tracer = &QueryTracer{logger: qlogger}
-		pool, err = pgxpool.NewWithConfig(ctx, config)
+		poolConfig.ConnConfig.Tracer = &QueryTracer{logger: qlogger}
+		pool, err = pgxpool.NewWithConfig(ctx, poolConfig)
 		if err != nil {
 			logger.Errorf("pgxpool init error %v", err)
 			os.Exit(1)
@@ -106,57 +102,113 @@ func main() {
 		}
 	}
 
-	//init env variables
-	JWTSecret := os.Getenv("JWT_SECRET")
-	if JWTSecret == "" {
-		logger.Errorf("JWT configuration must be provided %v", err)
+	//init application
+	app, err := app.NewApplication(cfg.App(ctx, logger, pool))
+	if err != nil {
+		logger.Errorf("Application init error %v", err)
 		os.Exit(1)
 	}
 
-	var JWTAccessTTL, JWTRefreshTTL *int
-	attl, err := strconv.Atoi(os.Getenv("JWT_ACCESS_TTL"))
-	if err != nil {
-		JWTAccessTTL = nil
+	// workers outlive ctx, they are stopped after the servers are drained
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, run := range []func(context.Context){app.OutboxRelay.Run, app.WebhookService.Run} {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(workersCtx)
+		}(run)
 	}
-	JWTAccessTTL = &attl
 
-	rttl, err := strconv.Atoi(os.Getenv("JWT_REFRESH_TTL"))
-	if err != nil {
-		JWTRefreshTTL = nil
-	}
-	JWTRefreshTTL = &rttl	
+	grpcServer := ports.NewGrpcServer(app, strconv.Itoa(cfg.Grpc.Port))
+	server := ports.NewHttpServer(app, cfg.HttpServer())
 
-	maxSessions, err := strconv.Atoi(os.Getenv("MAX_USER_SESSIONS"))
-	if err != nil {
-		logger.Errorf("Max user sessions configuration must be provided %v", err)
-		os.Exit(1)
+	serverErrors := make(chan error, 2)
+	go func() {
+		if err := grpcServer.Start(); err != nil {
+			serverErrors <- fmt.Errorf("gRPC server error %w", err)
+		}
+	}()
+	go func() {
+		if err := server.Start(); err != nil {
+			serverErrors <- fmt.Errorf("HTTP server error %w", err)
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutting down")
+	case err := <-serverErrors:
+		logger.Errorf("%v, shutting down", err)
+		exitCode = 1
 	}
 
-	accessHeader := os.Getenv("ACCESS_TOKEN_HEADER")
-	if accessHeader == "" {
-		logger.Errorf("ACCESS_TOKEN_HEADER must be provided %v", err)
-		os.Exit(1)
+	// a second signal kills the process right away
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// servers are drained first so requests in flight can still use the
+	// workers and the pool
+	shutdownErrors := make(chan error, 2)
+	go func() {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			shutdownErrors <- fmt.Errorf("HTTP server shutdown error %w", err)
+			return
+		}
+		shutdownErrors <- nil
+	}()
+	go func() {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			shutdownErrors <- fmt.Errorf("gRPC server shutdown error %w", err)
+			return
+		}
+		shutdownErrors <- nil
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-shutdownErrors; err != nil {
+			logger.Error(err)
+			exitCode = 1
+		}
 	}
 
-	//init application
-	appConfig := app.Config{
-		Ctx:             ctx,
-		Logger:          logger,
-		DbPool:          pool,
-		JwtSecret:       JWTSecret,
-		JwtAccessTTL:    JWTAccessTTL,
-		JwtRefreshTTL:   JWTRefreshTTL,
-		MaxUserSessions: maxSessions,
+	// a batch cut short is retried, unsent rows are claimed again later
+	stopWorkers()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		app.DeviceService.Wait()
+		app.PasswordResetService.Wait()
+	}()
+	if err := wait(shutdownCtx, &workers); err != nil {
+		logger.Errorf("Workers shutdown error %v", err)
+		exitCode = 1
 	}
 
-	app, err := app.NewApplication(&appConfig)
-	if err != nil {
-		logger.Errorf("Application init error %v", err)
-		os.Exit(1)
+	pool.Close()
+	logger.Info("Shut down")
+
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
+}
 
-	server := ports.NewHttpServer(app, accessHeader)
-	server.Start()
+// wait waits for wg until ctx is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 type QueryTracer struct {
@@ -0,0 +1 @@
+ALTER TABLE public.users ADD email_verified_at timestamp NULL;
@@ -0,0 +1,47 @@
+CREATE TABLE public.roles (
+	name varchar NOT NULL,
+	description varchar NOT NULL DEFAULT '',
+	CONSTRAINT roles_pk PRIMARY KEY (name)
+);
+
+CREATE TABLE public.permissions (
+	name varchar NOT NULL,
+	description varchar NOT NULL DEFAULT '',
+	CONSTRAINT permissions_pk PRIMARY KEY (name)
+);
+
+CREATE TABLE public.role_permissions (
+	role varchar NOT NULL,
+	permission varchar NOT NULL,
+	CONSTRAINT role_permissions_pk PRIMARY KEY (role, permission),
+	CONSTRAINT role_permissions_role_fk FOREIGN KEY (role) REFERENCES public.roles(name) ON DELETE CASCADE,
+	CONSTRAINT role_permissions_permission_fk FOREIGN KEY (permission) REFERENCES public.permissions(name) ON DELETE CASCADE
+);
+
+CREATE TABLE public.user_roles (
+	user_uuid uuid NOT NULL,
+	role varchar NOT NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT user_roles_pk PRIMARY KEY (user_uuid, role),
+	CONSTRAINT user_roles_user_fk FOREIGN KEY (user_uuid) REFERENCES public.users(uuid) ON DELETE CASCADE,
+	CONSTRAINT user_roles_role_fk FOREIGN KEY (role) REFERENCES public.roles(name) ON DELETE CASCADE
+);
+
+INSERT INTO public.roles (name, description) VALUES
+	('user', 'Regular wallet user'),
+	('admin', 'Service administrator');
+
+INSERT INTO public.permissions (name, description) VALUES
+	('profile:read', 'Read own profile'),
+	('profile:write', 'Update own profile and settings'),
+	('admin', 'Access the admin API');
+
+INSERT INTO public.role_permissions (role, permission) VALUES
+	('user', 'profile:read'),
+	('user', 'profile:write'),
+	('admin', 'profile:read'),
+	('admin', 'profile:write'),
+	('admin', 'admin');
+
+INSERT INTO public.user_roles (user_uuid, role, created_at)
+	SELECT uuid, 'user', now() FROM public.users;
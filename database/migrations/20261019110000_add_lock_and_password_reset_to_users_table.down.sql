@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.users_email_pattern_idx;
+DROP INDEX IF EXISTS public.users_created_at_uuid_idx;
+
+ALTER TABLE public.users DROP COLUMN password_reset_required;
+ALTER TABLE public.users DROP COLUMN locked_at;
@@ -0,0 +1,5 @@
+ALTER TABLE public.users ADD locked_at timestamp NULL;
+ALTER TABLE public.users ADD password_reset_required bool NOT NULL DEFAULT false;
+
+CREATE INDEX users_created_at_uuid_idx ON public.users (created_at DESC, uuid DESC);
+CREATE INDEX users_email_pattern_idx ON public.users (email varchar_pattern_ops);
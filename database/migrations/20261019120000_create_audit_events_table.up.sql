@@ -0,0 +1,12 @@
+CREATE TABLE public.audit_events (
+	uuid uuid NOT NULL,
+	"type" varchar NOT NULL,
+	actor_uuid uuid NULL,
+	user_uuid uuid NULL,
+	data jsonb NOT NULL DEFAULT '{}',
+	created_at timestamp NOT NULL,
+	CONSTRAINT audit_events_pk PRIMARY KEY (uuid)
+);
+
+CREATE INDEX audit_events_user_uuid_idx ON public.audit_events (user_uuid, created_at DESC);
+CREATE INDEX audit_events_actor_uuid_idx ON public.audit_events (actor_uuid, created_at DESC);
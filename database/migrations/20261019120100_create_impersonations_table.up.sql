@@ -0,0 +1,12 @@
+CREATE TABLE public.impersonations (
+	uuid uuid NOT NULL,
+	admin_uuid uuid NOT NULL,
+	user_uuid uuid NOT NULL,
+	reason varchar NOT NULL DEFAULT '',
+	started_at timestamp NOT NULL,
+	expires_at timestamp NOT NULL,
+	ended_at timestamp NULL,
+	CONSTRAINT impersonations_pk PRIMARY KEY (uuid),
+	CONSTRAINT impersonations_admin_fk FOREIGN KEY (admin_uuid) REFERENCES public.users(uuid) ON DELETE CASCADE,
+	CONSTRAINT impersonations_user_fk FOREIGN KEY (user_uuid) REFERENCES public.users(uuid) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE public.refresh_tokens DROP CONSTRAINT refresh_tokens_fk;
+ALTER TABLE public.refresh_tokens ADD CONSTRAINT refresh_tokens_fk FOREIGN KEY (user_uuid) REFERENCES public.users(uuid);
@@ -0,0 +1,17 @@
+CREATE TABLE public.api_keys (
+	uuid uuid NOT NULL,
+	user_uuid uuid NOT NULL,
+	name varchar NOT NULL,
+	prefix varchar NOT NULL,
+	hash varchar NOT NULL,
+	scopes text[] NOT NULL DEFAULT '{}',
+	expires_at timestamp NULL,
+	last_used_at timestamp NULL,
+	revoked_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT api_keys_pk PRIMARY KEY (uuid),
+	CONSTRAINT api_keys_user_fk FOREIGN KEY (user_uuid) REFERENCES public.users(uuid) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX api_keys_hash_idx ON public.api_keys (hash);
+CREATE INDEX api_keys_user_uuid_idx ON public.api_keys (user_uuid);
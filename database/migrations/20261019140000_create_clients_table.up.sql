@@ -0,0 +1,9 @@
+CREATE TABLE public.clients (
+	id varchar NOT NULL,
+	name varchar NOT NULL,
+	secret_hash varchar NOT NULL,
+	scopes text[] NOT NULL DEFAULT '{}',
+	created_at timestamp NOT NULL,
+	disabled_at timestamp NULL,
+	CONSTRAINT clients_pk PRIMARY KEY (id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE public.outbox_events (
+	uuid uuid NOT NULL,
+	"type" varchar NOT NULL,
+	user_uuid uuid NOT NULL,
+	payload jsonb NOT NULL DEFAULT '{}',
+	occurred_at timestamp NOT NULL,
+	published_at timestamp NULL,
+	attempts int NOT NULL DEFAULT 0,
+	last_error text NULL,
+	next_attempt_at timestamp NOT NULL,
+	CONSTRAINT outbox_events_pk PRIMARY KEY (uuid)
+);
+
+CREATE INDEX outbox_events_pending_idx ON public.outbox_events (next_attempt_at, occurred_at) WHERE published_at IS NULL;
@@ -0,0 +1,35 @@
+CREATE TABLE public.webhook_subscriptions (
+	uuid uuid NOT NULL,
+	url varchar NOT NULL,
+	secret varchar NOT NULL,
+	event_types text[] NOT NULL DEFAULT '{}',
+	failure_count int NOT NULL DEFAULT 0,
+	disabled_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT webhook_subscriptions_pk PRIMARY KEY (uuid)
+);
+
+CREATE INDEX webhook_subscriptions_event_types_idx ON public.webhook_subscriptions USING gin (event_types);
+
+CREATE TABLE public.webhook_deliveries (
+	uuid uuid NOT NULL,
+	subscription_uuid uuid NOT NULL,
+	event_uuid uuid NOT NULL,
+	event_type varchar NOT NULL,
+	body text NOT NULL,
+	status varchar NOT NULL,
+	attempts int NOT NULL DEFAULT 0,
+	response_code int NULL,
+	last_error text NULL,
+	replay_of uuid NULL,
+	next_attempt_at timestamp NOT NULL,
+	delivered_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT webhook_deliveries_pk PRIMARY KEY (uuid),
+	CONSTRAINT webhook_deliveries_subscription_fk FOREIGN KEY (subscription_uuid) REFERENCES public.webhook_subscriptions(uuid) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX webhook_deliveries_event_idx ON public.webhook_deliveries (subscription_uuid, event_uuid) WHERE replay_of IS NULL;
+CREATE INDEX webhook_deliveries_subscription_idx ON public.webhook_deliveries (subscription_uuid, created_at DESC);
+CREATE INDEX webhook_deliveries_pending_idx ON public.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
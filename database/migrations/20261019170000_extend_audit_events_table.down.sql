@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON public.audit_events;
+DROP TRIGGER IF EXISTS audit_events_no_update_delete ON public.audit_events;
+DROP FUNCTION IF EXISTS public.audit_events_append_only();
+
+DROP INDEX IF EXISTS public.audit_events_type_idx;
+DROP INDEX IF EXISTS public.audit_events_created_at_idx;
+
+ALTER TABLE public.audit_events DROP COLUMN request_id;
+ALTER TABLE public.audit_events DROP COLUMN user_agent;
+ALTER TABLE public.audit_events DROP COLUMN ip;
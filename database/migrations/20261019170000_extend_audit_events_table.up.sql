@@ -0,0 +1,20 @@
+ALTER TABLE public.audit_events ADD ip varchar NULL;
+ALTER TABLE public.audit_events ADD user_agent varchar NULL;
+ALTER TABLE public.audit_events ADD request_id varchar NULL;
+
+CREATE INDEX audit_events_created_at_idx ON public.audit_events (created_at DESC, uuid DESC);
+CREATE INDEX audit_events_type_idx ON public.audit_events ("type", created_at DESC);
+
+CREATE FUNCTION public.audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+	BEFORE UPDATE OR DELETE ON public.audit_events
+	FOR EACH ROW EXECUTE FUNCTION public.audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+	BEFORE TRUNCATE ON public.audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION public.audit_events_append_only();
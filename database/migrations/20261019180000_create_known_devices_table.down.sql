@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.known_devices;
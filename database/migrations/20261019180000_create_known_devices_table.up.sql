@@ -0,0 +1,12 @@
+CREATE TABLE public.known_devices (
+	uuid uuid NOT NULL,
+	user_uuid uuid NOT NULL,
+	ip varchar NOT NULL,
+	user_agent varchar NOT NULL,
+	first_seen_at timestamp NOT NULL,
+	last_seen_at timestamp NOT NULL,
+	CONSTRAINT known_devices_pk PRIMARY KEY (uuid),
+	CONSTRAINT known_devices_user_fk FOREIGN KEY (user_uuid) REFERENCES public.users(uuid) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX known_devices_user_device_idx ON public.known_devices (user_uuid, ip, user_agent);
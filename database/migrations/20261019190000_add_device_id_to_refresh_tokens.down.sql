@@ -0,0 +1 @@
+ALTER TABLE public.refresh_tokens DROP COLUMN IF EXISTS device_id;
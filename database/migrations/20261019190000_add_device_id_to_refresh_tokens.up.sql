@@ -0,0 +1,5 @@
+ALTER TABLE public.refresh_tokens ADD COLUMN device_id varchar NOT NULL DEFAULT '';
+
+-- ips used to be stored with the client port, which made them useless for binding
+UPDATE public.refresh_tokens SET ip = regexp_replace(ip, '^\[(.*)\]:[0-9]+$', '\1') WHERE ip ~ '^\[.*\]:[0-9]+$';
+UPDATE public.refresh_tokens SET ip = regexp_replace(ip, ':[0-9]+$', '') WHERE ip ~ '^[0-9.]+:[0-9]+$';
@@ -0,0 +1,118 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKeyModel struct {
+	UUID       uuid.UUID  `db:"uuid"`
+	UserUUID   uuid.UUID  `db:"user_uuid"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	Hash       string     `db:"hash"`
+	Scopes     []string   `db:"scopes"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+type APIKeyPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAPIKeyPgsqlRepository(pool *pgxpool.Pool) *APIKeyPgsqlRepository {
+	return &APIKeyPgsqlRepository{pool}
+}
+
+func (s *APIKeyPgsqlRepository) Add(ctx context.Context, k *apikey.APIKey) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into api_keys(uuid, user_uuid, name, prefix, hash, scopes, expires_at, created_at) values($1,$2,$3,$4,$5,$6,$7,$8)",
+		k.UUID,
+		k.UserUUID,
+		k.Name,
+		k.Prefix,
+		k.Hash,
+		k.Scopes,
+		k.ExpiresAt,
+		k.CreatedAt,
+	)
+
+	return err
+}
+
+func (s *APIKeyPgsqlRepository) FindByHash(ctx context.Context, hash string) (*apikey.APIKey, error) {
+	model := &APIKeyModel{}
+	if err := pgxscan.Get(ctx, conn(ctx, s.pool), model, "select * from api_keys where hash = $1", hash); err != nil {
+		if pgxscan.NotFound(err) {
+			return &apikey.APIKey{}, errors.NewNotFoundError("API key not found", errors.SlugAPIKeyNotFound)
+		}
+
+		return &apikey.APIKey{}, err
+	}
+
+	return apiKeyFromModel(model), nil
+}
+
+func (s *APIKeyPgsqlRepository) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*apikey.APIKey, error) {
+	var models []*APIKeyModel
+	if err := pgxscan.Select(
+		ctx, conn(ctx, s.pool), &models, "select * from api_keys where user_uuid = $1 and revoked_at is null order by created_at desc",
+		userUUID,
+	); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*apikey.APIKey, 0, len(models))
+	for _, m := range models {
+		keys = append(keys, apiKeyFromModel(m))
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyPgsqlRepository) Revoke(ctx context.Context, uuid, userUUID uuid.UUID, revokedAt time.Time) error {
+	tag, err := conn(ctx, s.pool).Exec(ctx, "update api_keys set revoked_at = $1 where uuid = $2 and user_uuid = $3 and revoked_at is null", revokedAt, uuid, userUUID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.NewNotFoundError("API key not found", errors.SlugAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func (s *APIKeyPgsqlRepository) RevokeForUser(ctx context.Context, userUUID uuid.UUID, revokedAt time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update api_keys set revoked_at = $1 where user_uuid = $2 and revoked_at is null", revokedAt, userUUID)
+
+	return err
+}
+
+func (s *APIKeyPgsqlRepository) Touch(ctx context.Context, uuid uuid.UUID, usedAt time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update api_keys set last_used_at = $1 where uuid = $2", usedAt, uuid)
+
+	return err
+}
+
+func apiKeyFromModel(m *APIKeyModel) *apikey.APIKey {
+	return &apikey.APIKey{
+		UUID:       m.UUID,
+		UserUUID:   m.UserUUID,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		Hash:       m.Hash,
+		Scopes:     m.Scopes,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+}
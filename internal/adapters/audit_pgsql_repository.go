@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditEventModel struct {
+	UUID      uuid.UUID              `db:"uuid"`
+	Type      string                 `db:"type"`
+	ActorUUID *uuid.UUID             `db:"actor_uuid"`
+	UserUUID  *uuid.UUID             `db:"user_uuid"`
+	Data      map[string]interface{} `db:"data"`
+	Ip        *string                `db:"ip"`
+	UserAgent *string                `db:"user_agent"`
+	RequestId *string                `db:"request_id"`
+	CreatedAt time.Time              `db:"created_at"`
+}
+
+type AuditPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAuditPgsqlRepository(pool *pgxpool.Pool) *AuditPgsqlRepository {
+	return &AuditPgsqlRepository{pool}
+}
+
+func (s *AuditPgsqlRepository) Add(ctx context.Context, e *audit.Event) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into audit_events(uuid, type, actor_uuid, user_uuid, data, ip, user_agent, request_id, created_at) values($1,$2,$3,$4,$5,$6,$7,$8,$9)",
+		e.UUID,
+		string(e.Type),
+		e.ActorUUID,
+		e.UserUUID,
+		e.Data,
+		nullString(e.Ip),
+		nullString(e.UserAgent),
+		nullString(e.RequestId),
+		e.CreatedAt,
+	)
+
+	return err
+}
+
+func (s *AuditPgsqlRepository) Find(ctx context.Context, filter *audit.Filter) ([]*audit.Event, error) {
+	query := "select uuid, type, actor_uuid, user_uuid, data, ip, user_agent, request_id, created_at from audit_events where true"
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.UserUUID != nil {
+		query += " and user_uuid = " + arg(*filter.UserUUID)
+	}
+
+	if filter.ActorUUID != nil {
+		query += " and actor_uuid = " + arg(*filter.ActorUUID)
+	}
+
+	if len(filter.Types) > 0 {
+		query += " and type = ANY(" + arg(filter.Types) + ")"
+	}
+
+	if filter.Ip != "" {
+		query += " and ip = " + arg(filter.Ip)
+	}
+
+	if filter.From != nil {
+		query += " and created_at >= " + arg(*filter.From)
+	}
+
+	if filter.To != nil {
+		query += " and created_at < " + arg(*filter.To)
+	}
+
+	if filter.After != nil {
+		query += fmt.Sprintf(" and (created_at, uuid) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.UUID))
+	}
+
+	query += " order by created_at desc, uuid desc limit " + arg(filter.Limit)
+
+	var models []*AuditEventModel
+	if err := pgxscan.Select(ctx, conn(ctx, s.pool), &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	events := make([]*audit.Event, 0, len(models))
+	for _, m := range models {
+		events = append(events, &audit.Event{
+			UUID:      m.UUID,
+			Type:      audit.EventType(m.Type),
+			ActorUUID: m.ActorUUID,
+			UserUUID:  m.UserUUID,
+			Data:      m.Data,
+			Ip:        stringValue(m.Ip),
+			UserAgent: stringValue(m.UserAgent),
+			RequestId: stringValue(m.RequestId),
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	return events, nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ClientModel struct {
+	ID         string     `db:"id"`
+	Name       string     `db:"name"`
+	SecretHash string     `db:"secret_hash"`
+	Scopes     []string   `db:"scopes"`
+	CreatedAt  time.Time  `db:"created_at"`
+	DisabledAt *time.Time `db:"disabled_at"`
+}
+
+type ClientPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewClientPgsqlRepository(pool *pgxpool.Pool) *ClientPgsqlRepository {
+	return &ClientPgsqlRepository{pool}
+}
+
+func (s *ClientPgsqlRepository) FindById(ctx context.Context, id string) (*client.Client, error) {
+	model := &ClientModel{}
+	if err := pgxscan.Get(ctx, conn(ctx, s.pool), model, "select * from clients where id = $1", id); err != nil {
+		if pgxscan.NotFound(err) {
+			return &client.Client{}, errors.NewNotFoundError("Client not found", errors.SlugClientNotFound)
+		}
+
+		return &client.Client{}, err
+	}
+
+	return &client.Client{
+		ID:         model.ID,
+		Name:       model.Name,
+		SecretHash: model.SecretHash,
+		Scopes:     model.Scopes,
+		CreatedAt:  model.CreatedAt,
+		DisabledAt: model.DisabledAt,
+	}, nil
+}
+
+func (s *ClientPgsqlRepository) Add(ctx context.Context, c *client.Client) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into clients(id, name, secret_hash, scopes, created_at) values($1,$2,$3,$4,$5)",
+		c.ID,
+		c.Name,
+		c.SecretHash,
+		c.Scopes,
+		c.CreatedAt,
+	)
+
+	return conflictOnUnique(err, "Client already exists", errors.SlugClientAlreadyExists)
+}
@@ -0,0 +1,78 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/device"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeviceModel struct {
+	UUID        uuid.UUID `db:"uuid"`
+	UserUUID    uuid.UUID `db:"user_uuid"`
+	Ip          string    `db:"ip"`
+	UserAgent   string    `db:"user_agent"`
+	FirstSeenAt time.Time `db:"first_seen_at"`
+	LastSeenAt  time.Time `db:"last_seen_at"`
+}
+
+type DevicePgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDevicePgsqlRepository(pool *pgxpool.Pool) *DevicePgsqlRepository {
+	return &DevicePgsqlRepository{pool}
+}
+
+func (s *DevicePgsqlRepository) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*device.Device, error) {
+	var models []*DeviceModel
+	if err := pgxscan.Select(
+		ctx, conn(ctx, s.pool), &models, "select * from known_devices where user_uuid = $1 order by last_seen_at desc",
+		userUUID,
+	); err != nil {
+		return nil, err
+	}
+
+	devices := make([]*device.Device, 0, len(models))
+	for _, m := range models {
+		devices = append(devices, &device.Device{
+			UUID:        m.UUID,
+			UserUUID:    m.UserUUID,
+			Ip:          m.Ip,
+			UserAgent:   m.UserAgent,
+			FirstSeenAt: m.FirstSeenAt,
+			LastSeenAt:  m.LastSeenAt,
+		})
+	}
+
+	return devices, nil
+}
+
+func (s *DevicePgsqlRepository) Add(ctx context.Context, d *device.Device) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `insert into known_devices(uuid, user_uuid, ip, user_agent, first_seen_at, last_seen_at) values($1,$2,$3,$4,$5,$6)
+		on conflict (user_uuid, ip, user_agent) do update set last_seen_at = excluded.last_seen_at`,
+		d.UUID,
+		d.UserUUID,
+		d.Ip,
+		d.UserAgent,
+		d.FirstSeenAt,
+		d.LastSeenAt,
+	)
+
+	return err
+}
+
+func (s *DevicePgsqlRepository) Touch(ctx context.Context, uuid uuid.UUID, at time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update known_devices set last_seen_at = $1 where uuid = $2", at, uuid)
+
+	return err
+}
+
+func (s *DevicePgsqlRepository) Delete(ctx context.Context, uuid uuid.UUID) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "delete from known_devices where uuid = $1", uuid)
+
+	return err
+}
@@ -0,0 +1,70 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ImpersonationModel struct {
+	UUID      uuid.UUID  `db:"uuid"`
+	AdminUUID uuid.UUID  `db:"admin_uuid"`
+	UserUUID  uuid.UUID  `db:"user_uuid"`
+	Reason    string     `db:"reason"`
+	StartedAt time.Time  `db:"started_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	EndedAt   *time.Time `db:"ended_at"`
+}
+
+type ImpersonationPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewImpersonationPgsqlRepository(pool *pgxpool.Pool) *ImpersonationPgsqlRepository {
+	return &ImpersonationPgsqlRepository{pool}
+}
+
+func (s *ImpersonationPgsqlRepository) Add(ctx context.Context, i *service.Impersonation) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into impersonations(uuid, admin_uuid, user_uuid, reason, started_at, expires_at) values($1,$2,$3,$4,$5,$6)",
+		i.UUID,
+		i.AdminUUID,
+		i.UserUUID,
+		i.Reason,
+		i.StartedAt,
+		i.ExpiresAt,
+	)
+
+	return err
+}
+
+func (s *ImpersonationPgsqlRepository) FindById(ctx context.Context, uuid uuid.UUID) (*service.Impersonation, error) {
+	model := &ImpersonationModel{}
+	if err := pgxscan.Get(ctx, conn(ctx, s.pool), model, "select * from impersonations where uuid = $1", uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return &service.Impersonation{}, errors.NewNotFoundError("Impersonation not found", errors.SlugImpersonationNotFound)
+		}
+
+		return &service.Impersonation{}, err
+	}
+
+	return &service.Impersonation{
+		UUID:      model.UUID,
+		AdminUUID: model.AdminUUID,
+		UserUUID:  model.UserUUID,
+		Reason:    model.Reason,
+		StartedAt: model.StartedAt,
+		ExpiresAt: model.ExpiresAt,
+		EndedAt:   model.EndedAt,
+	}, nil
+}
+
+func (s *ImpersonationPgsqlRepository) End(ctx context.Context, uuid uuid.UUID, endedAt time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update impersonations set ended_at = $1 where uuid = $2 and ended_at is null", endedAt, uuid)
+
+	return err
+}
@@ -0,0 +1,27 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/bysoft-wallet/users/internal/app/mail"
+	"github.com/sirupsen/logrus"
+)
+
+// LogMailer writes emails to the log, it is used when SMTP is not configured.
+type LogMailer struct {
+	logger *logrus.Logger
+}
+
+func NewLogMailer(logger *logrus.Logger) *LogMailer {
+	return &LogMailer{logger}
+}
+
+func (s *LogMailer) Send(ctx context.Context, m *mail.Message) error {
+	s.logger.WithFields(logrus.Fields{
+		"to":      m.To,
+		"subject": m.Subject,
+		"text":    m.Text,
+	}).Info("Email")
+
+	return nil
+}
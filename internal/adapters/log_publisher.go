@@ -0,0 +1,30 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/sirupsen/logrus"
+)
+
+// LogPublisher writes events to the log, it is used when no webhook is
+// configured and in local development.
+type LogPublisher struct {
+	logger *logrus.Logger
+}
+
+func NewLogPublisher(logger *logrus.Logger) *LogPublisher {
+	return &LogPublisher{logger}
+}
+
+func (s *LogPublisher) Publish(ctx context.Context, e *event.Event) error {
+	s.logger.WithFields(logrus.Fields{
+		"event_id":    e.UUID.String(),
+		"event_type":  string(e.Type),
+		"user_uuid":   e.UserUUID.String(),
+		"payload":     e.Payload,
+		"occurred_at": e.OccurredAt,
+	}).Info("Domain event published")
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxEventModel struct {
+	UUID       uuid.UUID              `db:"uuid"`
+	Type       string                 `db:"type"`
+	UserUUID   uuid.UUID              `db:"user_uuid"`
+	Payload    map[string]interface{} `db:"payload"`
+	OccurredAt time.Time              `db:"occurred_at"`
+	Attempts   int                    `db:"attempts"`
+}
+
+type OutboxPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxPgsqlRepository(pool *pgxpool.Pool) *OutboxPgsqlRepository {
+	return &OutboxPgsqlRepository{pool}
+}
+
+func (s *OutboxPgsqlRepository) Add(ctx context.Context, e *event.Event) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into outbox_events(uuid, type, user_uuid, payload, occurred_at, next_attempt_at) values($1,$2,$3,$4,$5,$6)",
+		e.UUID,
+		string(e.Type),
+		e.UserUUID,
+		e.Payload,
+		e.OccurredAt,
+		e.OccurredAt,
+	)
+
+	return err
+}
+
+func (s *OutboxPgsqlRepository) ClaimPending(ctx context.Context, limit int, until time.Time) ([]*event.Event, error) {
+	var models []*OutboxEventModel
+	if err := pgxscan.Select(
+		ctx, conn(ctx, s.pool), &models, `with claimed as (
+			update outbox_events set next_attempt_at = $3
+			where uuid in (
+				select uuid from outbox_events
+				where published_at is null and next_attempt_at <= $1
+				order by occurred_at
+				limit $2
+				for update skip locked
+			)
+			returning uuid, type, user_uuid, payload, occurred_at, attempts
+		)
+		select * from claimed order by occurred_at`,
+		time.Now(),
+		limit,
+		until,
+	); err != nil {
+		return nil, err
+	}
+
+	events := make([]*event.Event, 0, len(models))
+	for _, m := range models {
+		events = append(events, &event.Event{
+			UUID:       m.UUID,
+			Type:       event.Type(m.Type),
+			UserUUID:   m.UserUUID,
+			Payload:    m.Payload,
+			OccurredAt: m.OccurredAt,
+			Attempts:   m.Attempts,
+		})
+	}
+
+	return events, nil
+}
+
+func (s *OutboxPgsqlRepository) MarkPublished(ctx context.Context, uuid uuid.UUID) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update outbox_events set published_at = $1, attempts = attempts + 1, last_error = null where uuid = $2", time.Now(), uuid)
+
+	return err
+}
+
+func (s *OutboxPgsqlRepository) MarkFailed(ctx context.Context, uuid uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update outbox_events set attempts = attempts + 1, last_error = $1, next_attempt_at = $2 where uuid = $3", reason, nextAttemptAt, uuid)
+
+	return err
+}
@@ -0,0 +1,21 @@
+package adapters
+
+import (
+	"errors"
+
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const pgUniqueViolation = "23505"
+
+// conflictOnUnique turns a unique constraint violation into a conflict error
+// with the slug, other errors are returned unchanged.
+func conflictOnUnique(err error, message, slug string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return appErr.NewConflictError(message, slug).WithCause(err)
+	}
+
+	return err
+}
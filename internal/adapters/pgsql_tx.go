@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type txContextKey struct{}
+
+// dbConn is implemented by both *pgxpool.Pool and pgx.Tx.
+type dbConn interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// conn returns the transaction started by PgsqlTxManager.InTx when the
+// context carries one, so repositories take part in it transparently.
+func conn(ctx context.Context, pool *pgxpool.Pool) dbConn {
+	if tx, ok := ctx.Value(txContextKey{}).(pgx.Tx); ok {
+		return tx
+	}
+
+	return pool
+}
+
+type PgsqlTxManager struct {
+	pool *pgxpool.Pool
+}
+
+func NewPgsqlTxManager(pool *pgxpool.Pool) *PgsqlTxManager {
+	return &PgsqlTxManager{pool}
+}
+
+// InTx runs fn in a transaction which is committed when fn succeeds. Nested
+// calls join the outer transaction.
+func (s *PgsqlTxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txContextKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = fn(context.WithValue(ctx, txContextKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
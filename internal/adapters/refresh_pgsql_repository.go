@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
 	"github.com/bysoft-wallet/users/pkg/jwt"
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/google/uuid"
@@ -15,6 +17,7 @@ type RefreshModel struct {
 	UserUUID  uuid.UUID `db:"user_uuid"`
 	Token     string    `db:"token"`
 	Ip        string    `db:"ip"`
+	DeviceId  string    `db:"device_id"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
@@ -28,11 +31,12 @@ func NewRefreshPgsqlRepository(pool *pgxpool.Pool) *RefreshPgsqlRepository {
 }
 
 func (s *RefreshPgsqlRepository) Add(ctx context.Context, refresh *jwt.RefreshJWT) error {
-	_, err := s.pool.Exec(ctx, "insert into refresh_tokens(uuid, user_uuid, token, ip, created_at, updated_at) values($1,$2,$3,$4,$5,$6)",
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into refresh_tokens(uuid, user_uuid, token, ip, device_id, created_at, updated_at) values($1,$2,$3,$4,$5,$6,$7)",
 		refresh.Claims.UUID,
 		refresh.Claims.UserId,
 		refresh.Token,
 		refresh.Ip,
+		refresh.DeviceId,
 		time.Now(),
 		time.Now())
 
@@ -42,36 +46,40 @@ func (s *RefreshPgsqlRepository) Add(ctx context.Context, refresh *jwt.RefreshJW
 
 	return nil
 }
-func (s *RefreshPgsqlRepository) Exists(ctx context.Context, uuid, userUUID uuid.UUID, ip string, token string) (bool, error) {
+func (s *RefreshPgsqlRepository) Find(ctx context.Context, uuid, userUUID uuid.UUID, token string) (*service.Session, error) {
 	model := &RefreshModel{}
 	if err := pgxscan.Get(
-		ctx, s.pool, model, "select * from refresh_tokens where uuid = $1 and user_uuid = $2 and token = $3",
+		ctx, conn(ctx, s.pool), model, "select * from refresh_tokens where uuid = $1 and user_uuid = $2 and token = $3",
 		uuid,
 		userUUID,
 		token,
 	); err != nil {
 		if pgxscan.NotFound(err) {
-			return false, nil
+			return &service.Session{}, errors.NewNotFoundError("Session not found", errors.SlugSessionNotFound)
 		}
 
-		return false, err
+		return &service.Session{}, err
 	}
 
-	return true, nil
+	return sessionFromModel(model), nil
 }
 
 func (s *RefreshPgsqlRepository) Delete(ctx context.Context, uuid uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, "delete from refresh_tokens where uuid = $1", uuid)
-
+	tag, err := conn(ctx, s.pool).Exec(ctx, "delete from refresh_tokens where uuid = $1", uuid)
 	if err != nil {
 		return err
 	}
 
+	// a concurrent refresh or logout deleted the session first
+	if tag.RowsAffected() == 0 {
+		return errors.NewNotFoundError("Session not found", errors.SlugSessionNotFound)
+	}
+
 	return nil
 }
 
 func (s *RefreshPgsqlRepository) DeleteForUserUUID(ctx context.Context, userUUID uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, "delete from refresh_tokens where user_uuid = $1", userUUID)
+	_, err := conn(ctx, s.pool).Exec(ctx, "delete from refresh_tokens where user_uuid = $1", userUUID)
 
 	if err != nil {
 		return err
@@ -80,9 +88,55 @@ func (s *RefreshPgsqlRepository) DeleteForUserUUID(ctx context.Context, userUUID
 	return nil
 }
 
+func (s *RefreshPgsqlRepository) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*service.Session, error) {
+	var models []*RefreshModel
+	if err := pgxscan.Select(
+		ctx, conn(ctx, s.pool), &models, "select * from refresh_tokens where user_uuid = $1 order by updated_at desc",
+		userUUID,
+	); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*service.Session, 0, len(models))
+	for _, m := range models {
+		sessions = append(sessions, sessionFromModel(m))
+	}
+
+	return sessions, nil
+}
+
 func (s *RefreshPgsqlRepository) CountForUser(ctx context.Context, userUUID uuid.UUID) (int, error) {
 	var counter int
 
-	err := s.pool.QueryRow(ctx, "SELECT count(*) FROM refresh_tokens where user_uuid = $1", userUUID).Scan(&counter)
+	err := conn(ctx, s.pool).QueryRow(ctx, "SELECT count(*) FROM refresh_tokens where user_uuid = $1", userUUID).Scan(&counter)
 	return counter, err
 }
+
+func sessionFromModel(m *RefreshModel) *service.Session {
+	return &service.Session{
+		UUID:      m.UUID,
+		UserUUID:  m.UserUUID,
+		Ip:        m.Ip,
+		DeviceId:  m.DeviceId,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func (s *RefreshPgsqlRepository) LockForUser(ctx context.Context, userUUID uuid.UUID) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "select uuid from users where uuid = $1 for update", userUUID)
+
+	return err
+}
+
+func (s *RefreshPgsqlRepository) DeleteLeastRecentlyUsed(ctx context.Context, userUUID uuid.UUID, keep int) ([]uuid.UUID, error) {
+	var deleted []uuid.UUID
+	err := pgxscan.Select(ctx, conn(ctx, s.pool), &deleted, `delete from refresh_tokens where uuid in (
+			select uuid from refresh_tokens where user_uuid = $1 order by updated_at desc offset $2
+		) returning uuid`,
+		userUUID,
+		keep,
+	)
+
+	return deleted, err
+}
@@ -0,0 +1,80 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RoleModel struct {
+	Name        string   `db:"name"`
+	Description string   `db:"description"`
+	Permissions []string `db:"permissions"`
+}
+
+type RolePgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewRolePgsqlRepository(pool *pgxpool.Pool) *RolePgsqlRepository {
+	return &RolePgsqlRepository{pool}
+}
+
+func (s *RolePgsqlRepository) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*role.Role, error) {
+	var models []*RoleModel
+	if err := pgxscan.Select(
+		ctx, conn(ctx, s.pool), &models, `select r.name, r.description,
+			coalesce(array_agg(rp.permission) filter (where rp.permission is not null), '{}') as permissions
+			from user_roles ur
+			join roles r on r.name = ur.role
+			left join role_permissions rp on rp.role = r.name
+			where ur.user_uuid = $1
+			group by r.name, r.description
+			order by r.name`,
+		userUUID,
+	); err != nil {
+		return nil, err
+	}
+
+	roles := make([]*role.Role, 0, len(models))
+	for _, m := range models {
+		roles = append(roles, &role.Role{
+			Name:        m.Name,
+			Description: m.Description,
+			Permissions: m.Permissions,
+		})
+	}
+
+	return roles, nil
+}
+
+func (s *RolePgsqlRepository) AssignToUser(ctx context.Context, userUUID uuid.UUID, roleName string) error {
+	var exists bool
+	err := conn(ctx, s.pool).QueryRow(ctx, "select exists(select 1 from roles where name = $1)", roleName).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return errors.NewNotFoundError("Role not found", errors.SlugRoleNotFound)
+	}
+
+	_, err = conn(ctx, s.pool).Exec(ctx, "insert into user_roles(user_uuid, role, created_at) values($1,$2,$3) on conflict do nothing",
+		userUUID,
+		roleName,
+		time.Now(),
+	)
+
+	return err
+}
+
+func (s *RolePgsqlRepository) RevokeFromUser(ctx context.Context, userUUID uuid.UUID, roleName string) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "delete from user_roles where user_uuid = $1 and role = $2", userUUID, roleName)
+
+	return err
+}
@@ -0,0 +1,52 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/mail"
+)
+
+type SmtpConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+type SmtpMailer struct {
+	config SmtpConfig
+}
+
+func NewSmtpMailer(config SmtpConfig) *SmtpMailer {
+	return &SmtpMailer{config}
+}
+
+func (s *SmtpMailer) Send(ctx context.Context, m *mail.Message) error {
+	var auth smtp.Auth
+	if s.config.User != "" {
+		auth = smtp.PlainAuth("", s.config.User, s.config.Password, s.config.Host)
+	}
+
+	headers := []string{
+		"From: " + s.config.From,
+		"To: " + m.To,
+		"Subject: " + m.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(m.Text, "\n", "\r\n")
+
+	addr := net.JoinHostPort(s.config.Host, s.config.Port)
+	if err := smtp.SendMail(addr, auth, s.config.From, []string{m.To}, []byte(body)); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", m.To, err)
+	}
+
+	return nil
+}
@@ -2,9 +2,12 @@ package adapters
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
 	"github.com/bysoft-wallet/users/internal/app/user"
 	"github.com/bysoft-wallet/users/pkg/currency"
 	"github.com/georgysavva/scany/v2/pgxscan"
@@ -13,23 +16,34 @@ import (
 )
 
 type UserModel struct {
-	UUID      uuid.UUID         `db:"uuid"`
-	Email     string            `db:"email"`
-	Name      string            `db:"name"`
-	Hash      string            `db:"hash"`
-	Settings  map[string]string `db:"settings"`
-	CreatedAt time.Time         `db:"created_at"`
-	UpdatedAt time.Time         `db:"updated_at"`
+	UUID                  uuid.UUID         `db:"uuid"`
+	Email                 string            `db:"email"`
+	Name                  string            `db:"name"`
+	Hash                  string            `db:"hash"`
+	Settings              map[string]string `db:"settings"`
+	EmailVerifiedAt       *time.Time        `db:"email_verified_at"`
+	LockedAt              *time.Time        `db:"locked_at"`
+	PasswordResetRequired bool              `db:"password_reset_required"`
+	CreatedAt             time.Time         `db:"created_at"`
+	UpdatedAt             time.Time         `db:"updated_at"`
 }
 
+const userColumns = "uuid, email, name, hash, settings, email_verified_at, locked_at, password_reset_required, created_at, updated_at"
+
 type UserPgsqlRepository struct {
 	pool *pgxpool.Pool
 }
 
 func UserSettingsToMap(s user.Settings) map[string]string {
-	return map[string]string{
+	settings := map[string]string{
 		"currency": s.Currency.String(),
 	}
+
+	if s.Locale != "" {
+		settings["locale"] = string(s.Locale)
+	}
+
+	return settings
 }
 
 func NewUserPgsqlRepository(pool *pgxpool.Pool) *UserPgsqlRepository {
@@ -39,10 +53,10 @@ func NewUserPgsqlRepository(pool *pgxpool.Pool) *UserPgsqlRepository {
 func (s *UserPgsqlRepository) FindById(ctx context.Context, uuid uuid.UUID) (*user.User, error) {
 	userModel := &UserModel{}
 	if err := pgxscan.Get(
-		ctx, s.pool, userModel, "select uuid, email, name, hash, settings, created_at, updated_at from users where uuid = $1", uuid,
+		ctx, conn(ctx, s.pool), userModel, "select "+userColumns+" from users where uuid = $1", uuid,
 	); err != nil {
 		if pgxscan.NotFound(err) {
-			return &user.User{}, errors.NewNotFoundError("User not found", "user-not-found")
+			return &user.User{}, errors.NewNotFoundError("User not found", errors.SlugUserNotFound)
 		}
 
 		return &user.User{}, err
@@ -54,10 +68,10 @@ func (s *UserPgsqlRepository) FindById(ctx context.Context, uuid uuid.UUID) (*us
 func (s *UserPgsqlRepository) FindByEmail(ctx context.Context, email string) (*user.User, error) {
 	userModel := &UserModel{}
 	if err := pgxscan.Get(
-		ctx, s.pool, userModel, "select uuid, email, name, hash, settings, created_at, updated_at from users where email = $1", email,
+		ctx, conn(ctx, s.pool), userModel, "select "+userColumns+" from users where email = $1", email,
 	); err != nil {
 		if pgxscan.NotFound(err) {
-			return &user.User{}, errors.NewNotFoundError("User not found", "user-not-found")
+			return &user.User{}, errors.NewNotFoundError("User not found", errors.SlugUserNotFound)
 		}
 
 		return &user.User{}, err
@@ -66,17 +80,80 @@ func (s *UserPgsqlRepository) FindByEmail(ctx context.Context, email string) (*u
 	return serviceUserFromModel(userModel)
 }
 
+func (s *UserPgsqlRepository) FindByIds(ctx context.Context, uuids []uuid.UUID) ([]*user.User, error) {
+	return s.selectUsers(ctx, "select "+userColumns+" from users where uuid = ANY($1)", uuids)
+}
+
+func (s *UserPgsqlRepository) FindByEmails(ctx context.Context, emails []string) ([]*user.User, error) {
+	return s.selectUsers(ctx, "select "+userColumns+" from users where email = ANY($1)", emails)
+}
+
+func (s *UserPgsqlRepository) Search(ctx context.Context, filter *user.Filter) ([]*user.User, error) {
+	query := "select " + userColumns + " from users where true"
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.EmailPrefix != "" {
+		query += " and email like " + arg(likePrefix(filter.EmailPrefix))
+	}
+
+	if filter.CreatedFrom != nil {
+		query += " and created_at >= " + arg(*filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		query += " and created_at < " + arg(*filter.CreatedTo)
+	}
+
+	if filter.EmailVerified != nil {
+		if *filter.EmailVerified {
+			query += " and email_verified_at is not null"
+		} else {
+			query += " and email_verified_at is null"
+		}
+	}
+
+	if filter.After != nil {
+		query += fmt.Sprintf(" and (created_at, uuid) < (%s, %s)", arg(filter.After.CreatedAt), arg(filter.After.UUID))
+	}
+
+	query += " order by created_at desc, uuid desc limit " + arg(filter.Limit)
+
+	return s.selectUsers(ctx, query, args...)
+}
+
+func (s *UserPgsqlRepository) selectUsers(ctx context.Context, query string, args ...interface{}) ([]*user.User, error) {
+	var models []*UserModel
+	if err := pgxscan.Select(ctx, conn(ctx, s.pool), &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	users := make([]*user.User, 0, len(models))
+	for _, m := range models {
+		u, err := serviceUserFromModel(m)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
 func (s *UserPgsqlRepository) Add(ctx context.Context, u *user.User) error {
-	_, err := s.pool.Exec(ctx, "insert into users(uuid, email, name, hash, settings, created_at, updated_at) values($1,$2,$3,$4,$5,$6, $7)", u.UUID, u.Email, u.Name, u.Hash, UserSettingsToMap(u.Settings), u.CreatedAt, u.UpdatedAt)
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into users(uuid, email, name, hash, settings, created_at, updated_at) values($1,$2,$3,$4,$5,$6, $7)", u.UUID, u.Email, u.Name, u.Hash, UserSettingsToMap(u.Settings), u.CreatedAt, u.UpdatedAt)
 	if err != nil {
-		return err
+		return conflictOnUnique(err, "Email already in use", errors.SlugEmailAlreadyInUse)
 	}
 
 	return nil
 }
 
 func (s *UserPgsqlRepository) UpdateSettings(ctx context.Context, user_uuid uuid.UUID, settings *user.Settings) (*user.User, error) {
-	_, err := s.pool.Exec(ctx, "update users set settings = $1 where uuid = $2", UserSettingsToMap(*settings), user_uuid)
+	_, err := conn(ctx, s.pool).Exec(ctx, "update users set settings = $1 where uuid = $2", UserSettingsToMap(*settings), user_uuid)
 	if err != nil {
 		return &user.User{}, err
 	}
@@ -84,19 +161,56 @@ func (s *UserPgsqlRepository) UpdateSettings(ctx context.Context, user_uuid uuid
 	return s.FindById(ctx, user_uuid)
 }
 
+func (s *UserPgsqlRepository) UpdatePassword(ctx context.Context, user_uuid uuid.UUID, hash string) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update users set hash = $1, password_reset_required = false, updated_at = $2 where uuid = $3", hash, time.Now(), user_uuid)
+
+	return err
+}
+
+func (s *UserPgsqlRepository) SetLocked(ctx context.Context, user_uuid uuid.UUID, lockedAt *time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update users set locked_at = $1, updated_at = $2 where uuid = $3", lockedAt, time.Now(), user_uuid)
+
+	return err
+}
+
+func (s *UserPgsqlRepository) SetPasswordResetRequired(ctx context.Context, user_uuid uuid.UUID, required bool) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update users set password_reset_required = $1, updated_at = $2 where uuid = $3", required, time.Now(), user_uuid)
+
+	return err
+}
+
+func (s *UserPgsqlRepository) Delete(ctx context.Context, user_uuid uuid.UUID) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "delete from users where uuid = $1", user_uuid)
+
+	return err
+}
+
+// likePrefix escapes LIKE wildcards so the value is matched literally as a prefix.
+func likePrefix(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value) + "%"
+}
+
 func serviceUserFromModel(model *UserModel) (*user.User, error) {
 	cur, err := currency.FromString(model.Settings["currency"])
 	if err != nil {
 		return &user.User{}, err
 	}
 
-	return user.NewUser(
+	settings := user.NewSettings(cur)
+	settings.Locale, _ = i18n.ParseLocale(model.Settings["locale"])
+
+	u := user.NewUser(
 		model.UUID,
 		model.Email,
 		model.Name,
 		model.Hash,
-		user.NewSettings(cur),
+		settings,
 		model.CreatedAt,
 		model.UpdatedAt,
-	), nil
+	)
+	u.EmailVerifiedAt = model.EmailVerifiedAt
+	u.LockedAt = model.LockedAt
+	u.PasswordResetRequired = model.PasswordResetRequired
+
+	return u, nil
 }
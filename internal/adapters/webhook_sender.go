@@ -0,0 +1,47 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+)
+
+// maxWebhookResponseSize bounds how much of a receiver response is read.
+const maxWebhookResponseSize = 64 * 1024
+
+type HttpWebhookSender struct {
+	client *http.Client
+}
+
+func NewHttpWebhookSender() *HttpWebhookSender {
+	return &HttpWebhookSender{
+		client: &http.Client{Timeout: defaultWebhookTimeout},
+	}
+}
+
+func (s *HttpWebhookSender) Send(ctx context.Context, r *webhook.Request) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxWebhookResponseSize))
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,275 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookSubscriptionModel struct {
+	UUID         uuid.UUID  `db:"uuid"`
+	URL          string     `db:"url"`
+	Secret       string     `db:"secret"`
+	EventTypes   []string   `db:"event_types"`
+	FailureCount int        `db:"failure_count"`
+	DisabledAt   *time.Time `db:"disabled_at"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
+}
+
+type WebhookDeliveryModel struct {
+	UUID             uuid.UUID  `db:"uuid"`
+	SubscriptionUUID uuid.UUID  `db:"subscription_uuid"`
+	EventUUID        uuid.UUID  `db:"event_uuid"`
+	EventType        string     `db:"event_type"`
+	Body             string     `db:"body"`
+	Status           string     `db:"status"`
+	Attempts         int        `db:"attempts"`
+	ResponseCode     *int       `db:"response_code"`
+	LastError        *string    `db:"last_error"`
+	ReplayOf         *uuid.UUID `db:"replay_of"`
+	NextAttemptAt    time.Time  `db:"next_attempt_at"`
+	DeliveredAt      *time.Time `db:"delivered_at"`
+	CreatedAt        time.Time  `db:"created_at"`
+}
+
+type WebhookSubscriptionPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookSubscriptionPgsqlRepository(pool *pgxpool.Pool) *WebhookSubscriptionPgsqlRepository {
+	return &WebhookSubscriptionPgsqlRepository{pool}
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) Add(ctx context.Context, sub *webhook.Subscription) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "insert into webhook_subscriptions(uuid, url, secret, event_types, created_at, updated_at) values($1,$2,$3,$4,$5,$6)",
+		sub.UUID,
+		sub.URL,
+		sub.Secret,
+		sub.EventTypes,
+		sub.CreatedAt,
+		sub.UpdatedAt,
+	)
+
+	return err
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) FindById(ctx context.Context, uuid uuid.UUID) (*webhook.Subscription, error) {
+	model := &WebhookSubscriptionModel{}
+	if err := pgxscan.Get(ctx, conn(ctx, s.pool), model, "select * from webhook_subscriptions where uuid = $1", uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return &webhook.Subscription{}, errors.NewNotFoundError("Webhook not found", errors.SlugWebhookNotFound)
+		}
+
+		return &webhook.Subscription{}, err
+	}
+
+	return webhookSubscriptionFromModel(model), nil
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) FindAll(ctx context.Context) ([]*webhook.Subscription, error) {
+	return s.selectSubscriptions(ctx, "select * from webhook_subscriptions order by created_at desc")
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) FindActiveForEvent(ctx context.Context, eventType string) ([]*webhook.Subscription, error) {
+	return s.selectSubscriptions(ctx, "select * from webhook_subscriptions where disabled_at is null and event_types @> array[$1]::text[]", eventType)
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) selectSubscriptions(ctx context.Context, query string, args ...interface{}) ([]*webhook.Subscription, error) {
+	var models []*WebhookSubscriptionModel
+	if err := pgxscan.Select(ctx, conn(ctx, s.pool), &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	subs := make([]*webhook.Subscription, 0, len(models))
+	for _, m := range models {
+		subs = append(subs, webhookSubscriptionFromModel(m))
+	}
+
+	return subs, nil
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) RecordFailure(ctx context.Context, uuid uuid.UUID, disableAfter int, at time.Time) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `update webhook_subscriptions set
+		failure_count = failure_count + 1,
+		disabled_at = case when disabled_at is null and failure_count + 1 >= $1 then $2 else disabled_at end,
+		updated_at = $2
+		where uuid = $3`,
+		disableAfter,
+		at,
+		uuid,
+	)
+
+	return err
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) ResetFailures(ctx context.Context, uuid uuid.UUID) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, "update webhook_subscriptions set failure_count = 0 where uuid = $1 and failure_count > 0", uuid)
+
+	return err
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) Enable(ctx context.Context, uuid uuid.UUID) error {
+	tag, err := conn(ctx, s.pool).Exec(ctx, "update webhook_subscriptions set disabled_at = null, failure_count = 0, updated_at = $1 where uuid = $2", time.Now(), uuid)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.NewNotFoundError("Webhook not found", errors.SlugWebhookNotFound)
+	}
+
+	return nil
+}
+
+func (s *WebhookSubscriptionPgsqlRepository) Delete(ctx context.Context, uuid uuid.UUID) error {
+	tag, err := conn(ctx, s.pool).Exec(ctx, "delete from webhook_subscriptions where uuid = $1", uuid)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.NewNotFoundError("Webhook not found", errors.SlugWebhookNotFound)
+	}
+
+	return nil
+}
+
+type WebhookDeliveryPgsqlRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookDeliveryPgsqlRepository(pool *pgxpool.Pool) *WebhookDeliveryPgsqlRepository {
+	return &WebhookDeliveryPgsqlRepository{pool}
+}
+
+func (s *WebhookDeliveryPgsqlRepository) Add(ctx context.Context, d *webhook.Delivery) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `insert into webhook_deliveries(uuid, subscription_uuid, event_uuid, event_type, body, status, replay_of, next_attempt_at, created_at)
+		values($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		on conflict do nothing`,
+		d.UUID,
+		d.SubscriptionUUID,
+		d.EventUUID,
+		d.EventType,
+		d.Body,
+		string(d.Status),
+		d.ReplayOf,
+		d.NextAttemptAt,
+		d.CreatedAt,
+	)
+
+	return err
+}
+
+func (s *WebhookDeliveryPgsqlRepository) FindById(ctx context.Context, uuid uuid.UUID) (*webhook.Delivery, error) {
+	model := &WebhookDeliveryModel{}
+	if err := pgxscan.Get(ctx, conn(ctx, s.pool), model, "select * from webhook_deliveries where uuid = $1", uuid); err != nil {
+		if pgxscan.NotFound(err) {
+			return &webhook.Delivery{}, errors.NewNotFoundError("Webhook delivery not found", errors.SlugWebhookDeliveryNotFound)
+		}
+
+		return &webhook.Delivery{}, err
+	}
+
+	return webhookDeliveryFromModel(model), nil
+}
+
+func (s *WebhookDeliveryPgsqlRepository) Find(ctx context.Context, filter *webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	query := "select * from webhook_deliveries where subscription_uuid = $1"
+	args := []interface{}{filter.SubscriptionUUID}
+
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		query += fmt.Sprintf(" and status = $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" order by created_at desc limit $%d", len(args))
+
+	return s.selectDeliveries(ctx, query, args...)
+}
+
+func (s *WebhookDeliveryPgsqlRepository) Claim(ctx context.Context, limit int, until time.Time) ([]*webhook.Delivery, error) {
+	return s.selectDeliveries(ctx, `update webhook_deliveries set next_attempt_at = $4
+		where uuid in (
+			select d.uuid from webhook_deliveries d
+			join webhook_subscriptions ws on ws.uuid = d.subscription_uuid and ws.disabled_at is null
+			where d.status = $1 and d.next_attempt_at <= $2
+			order by d.next_attempt_at
+			limit $3
+			for update of d skip locked
+		)
+		returning *`,
+		string(webhook.DeliveryPending),
+		time.Now(),
+		limit,
+		until,
+	)
+}
+
+func (s *WebhookDeliveryPgsqlRepository) selectDeliveries(ctx context.Context, query string, args ...interface{}) ([]*webhook.Delivery, error) {
+	var models []*WebhookDeliveryModel
+	if err := pgxscan.Select(ctx, conn(ctx, s.pool), &models, query, args...); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*webhook.Delivery, 0, len(models))
+	for _, m := range models {
+		deliveries = append(deliveries, webhookDeliveryFromModel(m))
+	}
+
+	return deliveries, nil
+}
+
+func (s *WebhookDeliveryPgsqlRepository) Update(ctx context.Context, d *webhook.Delivery) error {
+	_, err := conn(ctx, s.pool).Exec(ctx, `update webhook_deliveries set
+		status = $1, attempts = $2, response_code = $3, last_error = $4, next_attempt_at = $5, delivered_at = $6
+		where uuid = $7`,
+		string(d.Status),
+		d.Attempts,
+		d.ResponseCode,
+		d.LastError,
+		d.NextAttemptAt,
+		d.DeliveredAt,
+		d.UUID,
+	)
+
+	return err
+}
+
+func webhookSubscriptionFromModel(m *WebhookSubscriptionModel) *webhook.Subscription {
+	return &webhook.Subscription{
+		UUID:         m.UUID,
+		URL:          m.URL,
+		Secret:       m.Secret,
+		EventTypes:   m.EventTypes,
+		FailureCount: m.FailureCount,
+		DisabledAt:   m.DisabledAt,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+	}
+}
+
+func webhookDeliveryFromModel(m *WebhookDeliveryModel) *webhook.Delivery {
+	return &webhook.Delivery{
+		UUID:             m.UUID,
+		SubscriptionUUID: m.SubscriptionUUID,
+		EventUUID:        m.EventUUID,
+		EventType:        m.EventType,
+		Body:             m.Body,
+		Status:           webhook.DeliveryStatus(m.Status),
+		Attempts:         m.Attempts,
+		ResponseCode:     m.ResponseCode,
+		LastError:        m.LastError,
+		ReplayOf:         m.ReplayOf,
+		NextAttemptAt:    m.NextAttemptAt,
+		DeliveredAt:      m.DeliveredAt,
+		CreatedAt:        m.CreatedAt,
+	}
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Prefix makes API keys recognizable in headers, logs and secret scanners.
+const Prefix = "bsw_"
+
+const displayPrefixLength = len(Prefix) + 8
+
+type APIKey struct {
+	UUID       uuid.UUID
+	UserUUID   uuid.UUID
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type APIKeyRepository interface {
+	Add(ctx context.Context, key *APIKey) error
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*APIKey, error)
+	Revoke(ctx context.Context, uuid, userUUID uuid.UUID, revokedAt time.Time) error
+	RevokeForUser(ctx context.Context, userUUID uuid.UUID, revokedAt time.Time) error
+	Touch(ctx context.Context, uuid uuid.UUID, usedAt time.Time) error
+}
+
+// Generate returns a new random key secret.
+func Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return Prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Hash returns the value stored instead of the secret. Keys carry 256 bits of
+// entropy, so a plain SHA-256 is sufficient and keeps lookups indexable.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// DisplayPrefix returns the part of the secret that is safe to show in lists.
+func DisplayPrefix(secret string) string {
+	if len(secret) < displayPrefixLength {
+		return secret
+	}
+
+	return secret[:displayPrefixLength]
+}
@@ -2,31 +2,136 @@ package app
 
 import (
 	"context"
+	"time"
+
 	"github.com/bysoft-wallet/users/internal/adapters"
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/device"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/mail"
+	"github.com/bysoft-wallet/users/internal/app/role"
 	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/bysoft-wallet/users/pkg/dpop"
 	"github.com/bysoft-wallet/users/pkg/jwt"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
 type Application struct {
-	AuthService *service.AuthService
-	JWTService  *jwt.JWTService
-	Logger      *logrus.Logger
+	AuthService  *service.AuthService
+	AdminService *service.AdminService
+	// ImpersonationService issues and validates support impersonation tokens.
+	ImpersonationService *service.ImpersonationService
+	APIKeyService        *service.APIKeyService
+	ClientService        *service.ClientService
+	AccessService        *service.AccessService
+	LookupService        *service.LookupService
+	OutboxRelay          *service.OutboxRelay
+	// WebhookService manages partner webhooks, its delivery worker is
+	// started by the caller like the OutboxRelay.
+	WebhookService *service.WebhookService
+	AuditService   *service.AuditService
+	DeviceService  *service.DeviceService
+	// PasswordResetService completes forced password resets with emailed
+	// links.
+	PasswordResetService *service.PasswordResetService
+	JWTService           *jwt.JWTService
+	// DPoPVerifier checks DPoP proofs of sender constrained tokens.
+	DPoPVerifier *dpop.Verifier
+	PublicURL    string
+	Logger       *logrus.Logger
 }
 
 type Config struct {
-	Ctx             context.Context
-	Logger          *logrus.Logger
-	DbPool          *pgxpool.Pool
-	JwtSecret       string
-	JwtAccessTTL    *int
-	JwtRefreshTTL   *int
-	MaxUserSessions int
+	Ctx           context.Context
+	Logger        *logrus.Logger
+	DbPool        *pgxpool.Pool
+	JwtSecret     string
+	JwtAccessTTL  *int
+	JwtRefreshTTL *int
+	// JwtImpersonationTTL is the lifetime of impersonation tokens in seconds.
+	JwtImpersonationTTL *int
+	MaxUserSessions     int
+	// SessionLimitPolicy is applied when MaxUserSessions is reached:
+	// evict_lru, reject or confirm.
+	SessionLimitPolicy string
+	// JwtProfileClaims lists the profile claims (email, name, email_verified,
+	// currency) embedded into access tokens.
+	JwtProfileClaims []string
+	// MaxLookupSize limits the number of users resolved by one batch lookup.
+	MaxLookupSize int
+	// EventsWebhookURL receives domain events, they are only logged when empty.
+	EventsWebhookURL string
+	// OutboxRelayInterval is the outbox polling interval in seconds.
+	OutboxRelayInterval *int
+	OutboxBatchSize     int
+	// WebhookMaxAttempts is the number of delivery attempts of one event.
+	WebhookMaxAttempts int
+	// WebhookDisableAfter is the number of consecutive failures after which
+	// a webhook subscription is disabled.
+	WebhookDisableAfter int
+	// SessionBinding is the refresh token binding policy: none, ip, subnet
+	// or device.
+	SessionBinding string
+	// RevokeOnBindingViolation ends sessions refreshed from another client.
+	RevokeOnBindingViolation bool
+	// DPoPProofMaxAge limits the age of DPoP proofs in seconds.
+	DPoPProofMaxAge *int
+	// DPoPRequireNonce makes DPoP proofs carry a server issued nonce.
+	DPoPRequireNonce bool
+	// PublicURL is the externally reachable base URL used in email links.
+	PublicURL string
+	// Smtp configures outgoing email, emails are logged when Host is empty.
+	Smtp adapters.SmtpConfig
+	// Mailer replaces the SMTP and the logging mailer when set.
+	Mailer mail.Mailer
+}
+
+// Repositories are the storage the application runs on.
+type Repositories struct {
+	Users                user.UserRepository
+	Sessions             service.RefreshJWTRepository
+	Roles                role.RoleRepository
+	Tx                   service.TxManager
+	Outbox               event.OutboxRepository
+	Audit                audit.AuditRepository
+	Devices              device.DeviceRepository
+	Impersonations       service.ImpersonationRepository
+	APIKeys              apikey.APIKeyRepository
+	Clients              client.ClientRepository
+	WebhookSubscriptions webhook.SubscriptionRepository
+	WebhookDeliveries    webhook.DeliveryRepository
+}
+
+// PgsqlRepositories stores everything in PostgreSQL.
+func PgsqlRepositories(pool *pgxpool.Pool) *Repositories {
+	return &Repositories{
+		Users:                adapters.NewUserPgsqlRepository(pool),
+		Sessions:             adapters.NewRefreshPgsqlRepository(pool),
+		Roles:                adapters.NewRolePgsqlRepository(pool),
+		Tx:                   adapters.NewPgsqlTxManager(pool),
+		Outbox:               adapters.NewOutboxPgsqlRepository(pool),
+		Audit:                adapters.NewAuditPgsqlRepository(pool),
+		Devices:              adapters.NewDevicePgsqlRepository(pool),
+		Impersonations:       adapters.NewImpersonationPgsqlRepository(pool),
+		APIKeys:              adapters.NewAPIKeyPgsqlRepository(pool),
+		Clients:              adapters.NewClientPgsqlRepository(pool),
+		WebhookSubscriptions: adapters.NewWebhookSubscriptionPgsqlRepository(pool),
+		WebhookDeliveries:    adapters.NewWebhookDeliveryPgsqlRepository(pool),
+	}
 }
 
 func NewApplication(config *Config) (*Application, error) {
+	return NewApplicationWith(config, PgsqlRepositories(config.DbPool))
+}
 
+// NewApplicationWith builds the application on the given repositories,
+// config.DbPool is not used.
+func NewApplicationWith(config *Config, repositories *Repositories) (*Application, error) {
 	jwtService := jwt.NewJwtService(&jwt.JWTConfig{
 			Secret: config.JwtSecret,
 			AccessTTL: config.JwtAccessTTL,
@@ -34,16 +139,156 @@ func NewApplication(config *Config) (*Application, error) {
 		},
 	)
 
+	userRepository := repositories.Users
+	refreshRepository := repositories.Sessions
+	roleRepository := repositories.Roles
+	txManager := repositories.Tx
+	outboxRepository := repositories.Outbox
+	auditRepository := repositories.Audit
+
 	authService := service.NewAuthService(
-		adapters.NewUserPgsqlRepository(config.DbPool),
+		userRepository,
 		jwtService,
-		adapters.NewRefreshPgsqlRepository(config.DbPool),
+		refreshRepository,
+		repositories.APIKeys,
+		roleRepository,
+		txManager,
+		outboxRepository,
+		auditRepository,
 		config.MaxUserSessions,
 	)
 
+	binding, err := service.ParseSessionBinding(config.SessionBinding)
+	if err != nil {
+		return nil, err
+	}
+	authService.SetSessionBinding(binding, config.RevokeOnBindingViolation)
+
+	sessionLimit, err := service.ParseSessionLimitPolicy(config.SessionLimitPolicy)
+	if err != nil {
+		return nil, err
+	}
+	authService.SetSessionLimitPolicy(sessionLimit)
+
+	profileClaims, err := service.NewProfileClaimsEnricher(config.JwtProfileClaims)
+	if err != nil {
+		return nil, err
+	}
+	authService.AddClaimsEnricher(profileClaims, service.NewRoleClaimsEnricher(roleRepository))
+
+	var mailer mail.Mailer = adapters.NewLogMailer(config.Logger)
+	if config.Mailer != nil {
+		mailer = config.Mailer
+	} else if config.Smtp.Host != "" {
+		mailer = adapters.NewSmtpMailer(config.Smtp)
+	}
+
+	passwordResetService := service.NewPasswordResetService(
+		userRepository,
+		authService,
+		jwtService,
+		mailer,
+		config.Logger,
+		config.PublicURL,
+	)
+
+	deviceService := service.NewDeviceService(
+		repositories.Devices,
+		userRepository,
+		refreshRepository,
+		repositories.APIKeys,
+		jwtService,
+		mailer,
+		txManager,
+		outboxRepository,
+		auditRepository,
+		passwordResetService,
+		config.Logger,
+		config.PublicURL,
+	)
+	authService.AddSessionListener(deviceService)
+
+	var impersonationTTL time.Duration
+	if config.JwtImpersonationTTL != nil {
+		impersonationTTL = time.Duration(*config.JwtImpersonationTTL) * time.Second
+	}
+
+	impersonationService := service.NewImpersonationService(
+		authService,
+		jwtService,
+		userRepository,
+		roleRepository,
+		repositories.Impersonations,
+		txManager,
+		auditRepository,
+		impersonationTTL,
+	)
+
+	apiKeyService := service.NewAPIKeyService(
+		repositories.APIKeys,
+		userRepository,
+		roleRepository,
+		authService,
+	)
+
+	var relayInterval time.Duration
+	if config.OutboxRelayInterval != nil {
+		relayInterval = time.Duration(*config.OutboxRelayInterval) * time.Second
+	}
+
+	webhookService := service.NewWebhookService(
+		txManager,
+		repositories.WebhookSubscriptions,
+		repositories.WebhookDeliveries,
+		adapters.NewHttpWebhookSender(),
+		auditRepository,
+		config.Logger,
+		service.WebhookConfig{
+			MaxAttempts:  config.WebhookMaxAttempts,
+			DisableAfter: config.WebhookDisableAfter,
+			Interval:     relayInterval,
+			BatchSize:    config.OutboxBatchSize,
+		},
+	)
+
+	// partner webhook deliveries are recorded first, when a later publisher
+	// fails the event is relayed again and partners get it twice, they
+	// deduplicate by the event id
+	publisher := event.Publishers{webhookService, adapters.NewLogPublisher(config.Logger)}
+	if config.EventsWebhookURL != "" {
+		publisher = event.Publishers{webhookService, adapters.NewWebhookPublisher(config.EventsWebhookURL)}
+	}
+
+	dpopConfig := dpop.Config{}
+	if config.DPoPProofMaxAge != nil {
+		dpopConfig.MaxAge = time.Duration(*config.DPoPProofMaxAge) * time.Second
+	}
+
+	if config.DPoPRequireNonce {
+		// nonces are stateless, the key only has to be shared by instances
+		dpopConfig.NonceSecret = "dpop-nonce:" + config.JwtSecret
+	}
+
+	// the replay cache is per instance, a proof replayed against another
+	// instance within the max age is not detected
+	dpopVerifier := dpop.NewVerifier(dpopConfig, dpop.NewMemoryReplayCache())
+
 	return &Application{
-		AuthService: authService,
-		JWTService:  jwtService,
-		Logger:      config.Logger,
+		AuthService:          authService,
+		AdminService:         service.NewAdminService(userRepository, refreshRepository, repositories.APIKeys, roleRepository, txManager, outboxRepository, auditRepository, passwordResetService),
+		ImpersonationService: impersonationService,
+		APIKeyService:        apiKeyService,
+		ClientService:        service.NewClientService(repositories.Clients, jwtService),
+		AccessService:        service.NewAccessService(jwtService, apiKeyService, impersonationService),
+		LookupService:        service.NewLookupService(userRepository, config.MaxLookupSize),
+		OutboxRelay:          service.NewOutboxRelay(outboxRepository, publisher, config.Logger, relayInterval, config.OutboxBatchSize),
+		WebhookService:       webhookService,
+		AuditService:         service.NewAuditService(auditRepository),
+		DeviceService:        deviceService,
+		PasswordResetService: passwordResetService,
+		JWTService:           jwtService,
+		DPoPVerifier:         dpopVerifier,
+		PublicURL:            config.PublicURL,
+		Logger:               config.Logger,
 	}, nil
 }
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventImpersonationStarted EventType = "impersonation.started"
+	EventImpersonationStopped EventType = "impersonation.stopped"
+
+	EventSignInSucceeded        EventType = "auth.sign_in_succeeded"
+	EventSignInFailed           EventType = "auth.sign_in_failed"
+	EventTokenRefreshed         EventType = "auth.token_refreshed"
+	EventRefreshTokenReused     EventType = "auth.refresh_token_reused"
+	EventSessionBindingViolated EventType = "auth.session_binding_violated"
+
+	EventSignedUp        EventType = "user.signed_up"
+	EventSettingsChanged EventType = "user.settings_changed"
+	EventPasswordChanged EventType = "user.password_changed"
+	EventUserDeleted     EventType = "user.deleted"
+	EventSessionsRevoked EventType = "session.revoked"
+	EventNewDevice       EventType = "security.new_device"
+	EventDeviceDenied    EventType = "security.device_denied"
+
+	EventUserLocked          EventType = "admin.user_locked"
+	EventUserUnlocked        EventType = "admin.user_unlocked"
+	EventPasswordResetForced EventType = "admin.password_reset_forced"
+	EventWebhookCreated      EventType = "admin.webhook_created"
+	EventWebhookDeleted      EventType = "admin.webhook_deleted"
+	EventWebhookEnabled      EventType = "admin.webhook_enabled"
+	EventWebhookReplayed     EventType = "admin.webhook_delivery_replayed"
+)
+
+type Event struct {
+	UUID      uuid.UUID
+	Type      EventType
+	ActorUUID *uuid.UUID
+	UserUUID  *uuid.UUID
+	Data      map[string]interface{}
+	Ip        string
+	UserAgent string
+	RequestId string
+	CreatedAt time.Time
+}
+
+func NewEvent(t EventType, actor, subject *uuid.UUID, data map[string]interface{}) *Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	return &Event{
+		UUID:      uuid.New(),
+		Type:      t,
+		ActorUUID: actor,
+		UserUUID:  subject,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+}
+
+// WithMeta sets the request metadata of the event.
+func (e *Event) WithMeta(m Meta) *Event {
+	e.Ip = m.Ip
+	e.UserAgent = m.UserAgent
+	e.RequestId = m.RequestId
+
+	return e
+}
+
+// Meta describes the request an event is recorded in. Ports put it into the
+// context, services read it when recording events.
+type Meta struct {
+	Ip        string
+	UserAgent string
+	RequestId string
+	// ActorUUID is the authenticated user, the admin for impersonated requests.
+	ActorUUID *uuid.UUID
+}
+
+type metaContextKey struct{}
+
+func WithMeta(ctx context.Context, m Meta) context.Context {
+	return context.WithValue(ctx, metaContextKey{}, m)
+}
+
+func MetaFrom(ctx context.Context) Meta {
+	m, _ := ctx.Value(metaContextKey{}).(Meta)
+	return m
+}
+
+// WithActor sets the actor of the request metadata in the context.
+func WithActor(ctx context.Context, actor uuid.UUID) context.Context {
+	m := MetaFrom(ctx)
+	m.ActorUUID = &actor
+
+	return WithMeta(ctx, m)
+}
+
+type Filter struct {
+	UserUUID  *uuid.UUID
+	ActorUUID *uuid.UUID
+	Types     []string
+	Ip        string
+	From      *time.Time
+	To        *time.Time
+	After     *user.Cursor
+	Limit     int
+}
+
+type AuditRepository interface {
+	Add(ctx context.Context, event *Event) error
+	Find(ctx context.Context, filter *Filter) ([]*Event, error)
+}
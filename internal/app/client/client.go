@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Scopes grantable to machine clients.
+const (
+	ScopeUsersRead        = "users:read"
+	ScopeTokensIntrospect = "tokens:introspect"
+)
+
+// Client is an internal service authenticating with the client credentials grant.
+type Client struct {
+	ID         string
+	Name       string
+	SecretHash string
+	Scopes     []string
+	CreatedAt  time.Time
+	DisabledAt *time.Time
+}
+
+func (c *Client) IsDisabled() bool {
+	return c.DisabledAt != nil
+}
+
+func (c *Client) Allows(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ClientRepository interface {
+	FindById(ctx context.Context, id string) (*Client, error)
+	Add(ctx context.Context, client *Client) error
+}
+
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashSecret(secret string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(secret), 12)
+	return string(bytes), err
+}
+
+func CheckSecretHash(secret, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+	return err == nil
+}
@@ -0,0 +1,56 @@
+package device
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device is a combination of IP and user agent the user has signed in from.
+type Device struct {
+	UUID        uuid.UUID
+	UserUUID    uuid.UUID
+	Ip          string
+	UserAgent   string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+type DeviceRepository interface {
+	FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*Device, error)
+	Add(ctx context.Context, d *Device) error
+	Touch(ctx context.Context, uuid uuid.UUID, at time.Time) error
+	Delete(ctx context.Context, uuid uuid.UUID) error
+}
+
+// Novelty tells what is new about a sign in compared to the known devices.
+type Novelty struct {
+	NewIp        bool
+	NewUserAgent bool
+	// Known is the device with the same IP and user agent, if any.
+	Known *Device
+}
+
+func (n Novelty) IsNew() bool {
+	return n.NewIp || n.NewUserAgent
+}
+
+func Compare(known []*Device, ip, userAgent string) Novelty {
+	n := Novelty{NewIp: true, NewUserAgent: true}
+	for _, d := range known {
+		if d.Ip == ip {
+			n.NewIp = false
+		}
+
+		if d.UserAgent == userAgent {
+			n.NewUserAgent = false
+		}
+
+		if d.Ip == ip && d.UserAgent == userAgent {
+			n.Known = d
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,68 @@
+package errors
+
+import "net/http"
+
+// Definition describes a slug for client developers.
+type Definition struct {
+	Slug        string
+	Type        ErrorType
+	Description string
+}
+
+// Status is the HTTP status the slug is returned with as a top level error.
+// Field slugs of validation errors always come with 400.
+func (d Definition) Status() int {
+	return d.Type.Status()
+}
+
+// Status is the HTTP status of errors of the type.
+func (t ErrorType) Status() int {
+	switch t {
+	case ErrorTypeAuthorization:
+		return http.StatusUnauthorized
+	case ErrorTypeForbidden:
+		return http.StatusForbidden
+	case ErrorTypeIncorrectInput:
+		return http.StatusBadRequest
+	case ErrorNotFound:
+		return http.StatusNotFound
+	case ErrorTypeConflict:
+		return http.StatusConflict
+	case ErrorTypeRateLimited:
+		return http.StatusTooManyRequests
+	case ErrorTypeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+var registered = make(map[string]bool, len(catalog))
+
+func init() {
+	for _, d := range catalog {
+		if registered[d.Slug] {
+			panic("slug " + d.Slug + " is registered twice")
+		}
+		registered[d.Slug] = true
+	}
+}
+
+// Catalog returns every registered slug.
+func Catalog() []Definition {
+	return append([]Definition(nil), catalog...)
+}
+
+// Slugs returns the registered slug names.
+func Slugs() []string {
+	slugs := make([]string, 0, len(catalog))
+	for _, d := range catalog {
+		slugs = append(slugs, d.Slug)
+	}
+
+	return slugs
+}
+
+func IsRegistered(slug string) bool {
+	return registered[slug]
+}
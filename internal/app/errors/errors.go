@@ -1,23 +1,40 @@
 package errors
 
+import "errors"
+
 type ErrorType struct {
 	t string
 }
 
+func (t ErrorType) String() string {
+	return t.t
+}
+
 var (
 	ErrorTypeUnknown        = ErrorType{"unknown"}
 	ErrorTypeAuthorization  = ErrorType{"authorization"}
 	ErrorTypeIncorrectInput = ErrorType{"incorrect-input"}
 	ErrorNotFound           = ErrorType{"not-found"}
+	ErrorTypeConflict       = ErrorType{"conflict"}
+	ErrorTypeForbidden      = ErrorType{"forbidden"}
+	ErrorTypeRateLimited    = ErrorType{"rate-limited"}
+	ErrorTypeUnavailable    = ErrorType{"unavailable"}
 )
 
+// AppError is an error with a slug clients can rely on. It may wrap the
+// error that caused it, the cause is never shown to clients.
 type AppError struct {
 	error     string
 	slug      string
 	errorType ErrorType
+	cause     error
 }
 
 func (s AppError) Error() string {
+	if s.error == "" && s.cause != nil {
+		return s.cause.Error()
+	}
+
 	return s.error
 }
 
@@ -29,6 +46,40 @@ func (s AppError) ErrorType() ErrorType {
 	return s.errorType
 }
 
+func (s AppError) Unwrap() error {
+	return s.cause
+}
+
+// Is matches an AppError target by its type and slug, an empty slug in the
+// target matches any slug, so errors.Is(err, errors.NotFound) tells whether
+// anything in the chain is a not found error.
+func (s AppError) Is(target error) bool {
+	t, ok := target.(AppError)
+	if !ok {
+		return false
+	}
+
+	return s.errorType == t.errorType && (t.slug == "" || s.slug == t.slug)
+}
+
+// WithCause returns a copy of the error wrapping cause.
+func (s AppError) WithCause(cause error) AppError {
+	s.cause = cause
+	return s
+}
+
+// Targets for errors.Is matching any error of the type.
+var (
+	Unknown        = AppError{errorType: ErrorTypeUnknown}
+	Authorization  = AppError{errorType: ErrorTypeAuthorization}
+	IncorrectInput = AppError{errorType: ErrorTypeIncorrectInput}
+	NotFound       = AppError{errorType: ErrorNotFound}
+	Conflict       = AppError{errorType: ErrorTypeConflict}
+	Forbidden      = AppError{errorType: ErrorTypeForbidden}
+	RateLimited    = AppError{errorType: ErrorTypeRateLimited}
+	Unavailable    = AppError{errorType: ErrorTypeUnavailable}
+)
+
 func NewAppError(error string, slug string) AppError {
 	return AppError{
 		error:     error,
@@ -61,15 +112,75 @@ func NewNotFoundError(error string, slug string) AppError {
 	}
 }
 
+func NewConflictError(error string, slug string) AppError {
+	return AppError{
+		error:     error,
+		slug:      slug,
+		errorType: ErrorTypeConflict,
+	}
+}
+
+// NewForbiddenError is for authenticated callers lacking the right to do
+// something, unlike authorization errors it must not make clients sign in
+// again.
+func NewForbiddenError(error string, slug string) AppError {
+	return AppError{
+		error:     error,
+		slug:      slug,
+		errorType: ErrorTypeForbidden,
+	}
+}
+
+func NewRateLimitedError(error string, slug string) AppError {
+	return AppError{
+		error:     error,
+		slug:      slug,
+		errorType: ErrorTypeRateLimited,
+	}
+}
+
+func NewUnavailableError(error string, slug string) AppError {
+	return AppError{
+		error:     error,
+		slug:      slug,
+		errorType: ErrorTypeUnavailable,
+	}
+}
+
+// Wrap turns an unexpected error into an unknown AppError with the slug. An
+// AppError already in the chain is more specific and is returned as is.
+func Wrap(err error, slug string) error {
+	if err == nil {
+		return nil
+	}
+
+	if app, ok := As(err); ok {
+		return app
+	}
+
+	return AppError{
+		slug:      slug,
+		errorType: ErrorTypeUnknown,
+		cause:     err,
+	}
+}
+
+// As returns the first AppError in the chain of err.
+func As(err error) (AppError, bool) {
+	var app AppError
+	ok := errors.As(err, &app)
+	return app, ok
+}
+
 func IsApp(err error) bool {
-	_, ok := err.(AppError)
+	_, ok := As(err)
 	return ok
 }
 
 func IsNotFound(err error) bool {
-	app, ok := err.(AppError)
-	if ok {
-		return app.ErrorType() == ErrorNotFound
-	}
-	return false
+	return errors.Is(err, NotFound)
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, Conflict)
 }
@@ -0,0 +1,153 @@
+package errors
+
+// Slugs returned by the API, clients rely on them so they never change.
+const (
+	SlugAPIKeyForbidden                  = "api-key-forbidden"
+	SlugAPIKeyLoadingError               = "api-key-loading-error"
+	SlugAPIKeyNotFound                   = "api-key-not-found"
+	SlugAPIKeySavingError                = "api-key-saving-error"
+	SlugAuditSearchError                 = "audit-search-error"
+	SlugClientAlreadyExists              = "client-already-exists"
+	SlugClientNotFound                   = "client-not-found"
+	SlugClientSavingError                = "client-saving-error"
+	SlugCouldNotAuthorizeClient          = "could-not-authorize-client"
+	SlugCouldNotAuthorizeUser            = "could-not-authorize-user"
+	SlugCreateUserError                  = "create-user-error"
+	SlugCsrfTokenInvalid                 = "csrf-token-invalid"
+	SlugDeviceIdRequired                 = "device-id-required"
+	SlugDPoPNotSupported                 = "dpop-not-supported"
+	SlugEmailAlreadyInUse                = "email-already-in-use"
+	SlugFieldClientIdRequired            = "field-client-id-required"
+	SlugFieldCurrencyInvalid             = "field-currency-invalid"
+	SlugFieldCurrencyRequired            = "field-currency-required"
+	SlugFieldEmailInvalid                = "field-email-invalid"
+	SlugFieldEmailRequired               = "field-email-required"
+	SlugFieldEventTypesInvalid           = "field-event-types-invalid"
+	SlugFieldEventTypesRequired          = "field-event-types-required"
+	SlugFieldExpiresAtInvalid            = "field-expires-at-invalid"
+	SlugFieldLocaleInvalid               = "field-locale-invalid"
+	SlugFieldNameInvalidLength           = "field-name-invalid-length"
+	SlugFieldNameRequired                = "field-name-required"
+	SlugFieldNewPasswordInvalid          = "field-new-password-invalid"
+	SlugFieldNewPasswordInvalidLength    = "field-new-password-invalid-length"
+	SlugFieldNewPasswordRequired         = "field-new-password-required"
+	SlugFieldPasswordInvalidLength       = "field-password-invalid-length"
+	SlugFieldPasswordRequired            = "field-password-required"
+	SlugFieldReasonRequired              = "field-reason-required"
+	SlugFieldScopesInvalid               = "field-scopes-invalid"
+	SlugFieldTokenRequired               = "field-token-required"
+	SlugFieldURLInvalid                  = "field-url-invalid"
+	SlugFieldUUIDInvalid                 = "field-uuid-invalid"
+	SlugImpersonationError               = "impersonation-error"
+	SlugImpersonationForbidden           = "impersonation-forbidden"
+	SlugImpersonationNotActive           = "impersonation-not-active"
+	SlugImpersonationNotAllowed          = "impersonation-not-allowed"
+	SlugImpersonationNotFound            = "impersonation-not-found"
+	SlugInternalServerError              = "internal-server-error"
+	SlugInvalidClient                    = "invalid-client"
+	SlugInvalidCredentials               = "invalid-credentials"
+	SlugInvalidDPoPProof                 = "invalid-dpop-proof"
+	SlugInvalidInput                     = "invalid-input"
+	SlugInvalidScope                     = "invalid-scope"
+	SlugInvalidToken                     = "invalid-token"
+	SlugLookupError                      = "lookup-error"
+	SlugLookupTooManyUsers               = "lookup-too-many-users"
+	SlugMethodNotFound                   = "method-not-found"
+	SlugPasswordResetRequired            = "password-reset-required"
+	SlugPermissionDenied                 = "permission-denied"
+	SlugRoleNotFound                     = "role-not-found"
+	SlugServiceTokenRequired             = "service-token-required"
+	SlugSessionBindingViolated           = "session-binding-violated"
+	SlugSessionLimitConfirmationRequired = "session-limit-confirmation-required"
+	SlugSessionLimitReached              = "session-limit-reached"
+	SlugSessionNotFound                  = "session-not-found"
+	SlugUnsupportedGrantType             = "unsupported-grant-type"
+	SlugUseDPoPNonce                     = "use-dpop-nonce"
+	SlugUserDeletingError                = "user-deleting-error"
+	SlugUserLoadingError                 = "user-loading-error"
+	SlugUserLocked                       = "user-locked"
+	SlugUserNotFound                     = "user-not-found"
+	SlugUserSavingError                  = "user-saving-error"
+	SlugUserTokenRequired                = "user-token-required"
+	SlugUsersSearchError                 = "users-search-error"
+	SlugWebhookDeliveryNotFound          = "webhook-delivery-not-found"
+	SlugWebhookLoadingError              = "webhook-loading-error"
+	SlugWebhookNotFound                  = "webhook-not-found"
+	SlugWebhookSavingError               = "webhook-saving-error"
+)
+
+// catalog registers every slug, GET /api/v1/errors serves it.
+var catalog = []Definition{
+	{SlugAPIKeyForbidden, ErrorTypeForbidden, "The endpoint does not accept API keys, a user access token is required."},
+	{SlugAPIKeyLoadingError, ErrorTypeUnknown, "API keys could not be loaded."},
+	{SlugAPIKeyNotFound, ErrorNotFound, "The API key does not exist or belongs to another user."},
+	{SlugAPIKeySavingError, ErrorTypeUnknown, "The API key could not be saved."},
+	{SlugAuditSearchError, ErrorTypeUnknown, "The audit log could not be searched."},
+	{SlugClientAlreadyExists, ErrorTypeConflict, "A service client with the id is already registered."},
+	{SlugClientNotFound, ErrorNotFound, "The service client does not exist."},
+	{SlugClientSavingError, ErrorTypeUnknown, "The service client could not be saved."},
+	{SlugCouldNotAuthorizeClient, ErrorTypeAuthorization, "A token for the service client could not be issued."},
+	{SlugCouldNotAuthorizeUser, ErrorTypeAuthorization, "Tokens for the user could not be issued."},
+	{SlugCreateUserError, ErrorTypeUnknown, "The user could not be created."},
+	{SlugCsrfTokenInvalid, ErrorTypeForbidden, "A cookie authenticated request has no X-CSRF-Token header matching the csrf_token cookie."},
+	{SlugDeviceIdRequired, ErrorTypeIncorrectInput, "Device session binding is enabled and the X-Device-Id header is missing."},
+	{SlugDPoPNotSupported, ErrorTypeAuthorization, "DPoP bound tokens are only accepted by the HTTP API."},
+	{SlugEmailAlreadyInUse, ErrorTypeConflict, "Another user registered the email at the same time."},
+	{SlugFieldClientIdRequired, ErrorTypeIncorrectInput, "The client id is empty."},
+	{SlugFieldCurrencyInvalid, ErrorTypeIncorrectInput, "The currency is not supported."},
+	{SlugFieldCurrencyRequired, ErrorTypeIncorrectInput, "The currency is missing."},
+	{SlugFieldEmailInvalid, ErrorTypeIncorrectInput, "The email is malformed or already in use."},
+	{SlugFieldEmailRequired, ErrorTypeIncorrectInput, "The email is missing."},
+	{SlugFieldEventTypesInvalid, ErrorTypeIncorrectInput, "A webhook event type is unknown."},
+	{SlugFieldEventTypesRequired, ErrorTypeIncorrectInput, "A webhook needs at least one event type."},
+	{SlugFieldExpiresAtInvalid, ErrorTypeIncorrectInput, "The API key expiration is in the past."},
+	{SlugFieldLocaleInvalid, ErrorTypeIncorrectInput, "The locale is not supported, use en or ru."},
+	{SlugFieldNameInvalidLength, ErrorTypeIncorrectInput, "The name is too short or too long."},
+	{SlugFieldNameRequired, ErrorTypeIncorrectInput, "The name is missing."},
+	{SlugFieldNewPasswordInvalid, ErrorTypeIncorrectInput, "The new password equals the current one."},
+	{SlugFieldNewPasswordInvalidLength, ErrorTypeIncorrectInput, "The new password is too short."},
+	{SlugFieldNewPasswordRequired, ErrorTypeIncorrectInput, "The new password is missing."},
+	{SlugFieldPasswordInvalidLength, ErrorTypeIncorrectInput, "The password is too short."},
+	{SlugFieldPasswordRequired, ErrorTypeIncorrectInput, "The password is missing."},
+	{SlugFieldReasonRequired, ErrorTypeIncorrectInput, "The impersonation reason is missing."},
+	{SlugFieldScopesInvalid, ErrorTypeIncorrectInput, "An API key scope is not granted to the user."},
+	{SlugFieldTokenRequired, ErrorTypeIncorrectInput, "The token parameter is missing."},
+	{SlugFieldURLInvalid, ErrorTypeIncorrectInput, "The webhook URL is missing or malformed."},
+	{SlugFieldUUIDInvalid, ErrorTypeIncorrectInput, "The uuid is malformed."},
+	{SlugImpersonationError, ErrorTypeUnknown, "The impersonation could not be started or stopped."},
+	{SlugImpersonationForbidden, ErrorTypeForbidden, "The endpoint is not available under impersonation."},
+	{SlugImpersonationNotActive, ErrorTypeIncorrectInput, "The token is not an impersonation token or the impersonation has ended."},
+	{SlugImpersonationNotAllowed, ErrorTypeIncorrectInput, "The user can not be impersonated, e.g. it is the caller or an admin."},
+	{SlugImpersonationNotFound, ErrorNotFound, "The impersonation does not exist."},
+	{SlugInternalServerError, ErrorTypeUnknown, "An unexpected error, retry later."},
+	{SlugInvalidClient, ErrorTypeAuthorization, "The client id or secret is missing or wrong, or the client is disabled."},
+	{SlugInvalidCredentials, ErrorTypeIncorrectInput, "The email or password is wrong."},
+	{SlugInvalidDPoPProof, ErrorTypeAuthorization, "The DPoP proof is missing, malformed or does not match the request or the token."},
+	{SlugInvalidInput, ErrorTypeIncorrectInput, "The request body or parameters can not be parsed."},
+	{SlugInvalidScope, ErrorTypeIncorrectInput, "A requested scope is not allowed for the client."},
+	{SlugInvalidToken, ErrorTypeAuthorization, "The token is missing, malformed, expired or revoked."},
+	{SlugLookupError, ErrorTypeUnknown, "Users could not be looked up."},
+	{SlugLookupTooManyUsers, ErrorTypeIncorrectInput, "The lookup asks for more users than allowed at once."},
+	{SlugMethodNotFound, ErrorNotFound, "The gRPC method is not implemented."},
+	{SlugPasswordResetRequired, ErrorTypeAuthorization, "The user must set a new password with the link emailed to them."},
+	{SlugPermissionDenied, ErrorTypeForbidden, "The token lacks the permission or scope required by the endpoint."},
+	{SlugRoleNotFound, ErrorNotFound, "The role does not exist."},
+	{SlugServiceTokenRequired, ErrorTypeForbidden, "The endpoint only accepts service client tokens."},
+	{SlugSessionBindingViolated, ErrorTypeAuthorization, "The refresh token is used from another IP, network or device than it was issued to."},
+	{SlugSessionLimitConfirmationRequired, ErrorTypeAuthorization, "The session limit is reached, sign in again with evict_sessions to end the oldest sessions."},
+	{SlugSessionLimitReached, ErrorTypeAuthorization, "The session limit is reached and new sessions are rejected."},
+	{SlugSessionNotFound, ErrorNotFound, "The session has ended or does not exist."},
+	{SlugUnsupportedGrantType, ErrorTypeIncorrectInput, "The token endpoint grant type is missing or not supported."},
+	{SlugUseDPoPNonce, ErrorTypeAuthorization, "Retry the request with a DPoP proof carrying the nonce from the DPoP-Nonce header."},
+	{SlugUserDeletingError, ErrorTypeUnknown, "The user could not be deleted."},
+	{SlugUserLoadingError, ErrorTypeUnknown, "The user could not be loaded."},
+	{SlugUserLocked, ErrorTypeAuthorization, "The user is locked by an administrator."},
+	{SlugUserNotFound, ErrorNotFound, "The user does not exist."},
+	{SlugUserSavingError, ErrorTypeUnknown, "The user could not be saved."},
+	{SlugUserTokenRequired, ErrorTypeForbidden, "The endpoint only accepts user tokens."},
+	{SlugUsersSearchError, ErrorTypeUnknown, "Users could not be searched."},
+	{SlugWebhookDeliveryNotFound, ErrorNotFound, "The webhook delivery does not exist."},
+	{SlugWebhookLoadingError, ErrorTypeUnknown, "Webhooks could not be loaded."},
+	{SlugWebhookNotFound, ErrorNotFound, "The webhook does not exist."},
+	{SlugWebhookSavingError, ErrorTypeUnknown, "The webhook could not be saved."},
+}
@@ -0,0 +1,170 @@
+package errors_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+)
+
+const moduleRoot = "../../.."
+
+// slugArgs maps the functions taking a slug to the position of the slug
+// argument, negative positions count from the end.
+var slugArgs = map[string]int{
+	"NewAppError":            -1,
+	"NewAuthorizationError":  -1,
+	"NewIncorrectInputError": -1,
+	"NewNotFoundError":       -1,
+	"NewConflictError":       -1,
+	"NewForbiddenError":      -1,
+	"NewRateLimitedError":    -1,
+	"NewUnavailableError":    -1,
+	"Wrap":                   -1,
+	"BadRequest":             0,
+	"Unauthorised":           0,
+	"Forbidden":              0,
+	"NotFound":               0,
+	"Conflict":               0,
+	"TooManyRequests":        0,
+	"Unavailable":            0,
+	"InternalError":          0,
+}
+
+func TestSlugConstantsAreRegistered(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	found := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+
+			for i, ident := range spec.Names {
+				if !strings.HasPrefix(ident.Name, "Slug") || i >= len(spec.Values) {
+					continue
+				}
+
+				slug, ok := stringLit(spec.Values[i])
+				if !ok {
+					continue
+				}
+
+				found++
+				if !appErr.IsRegistered(slug) {
+					t.Errorf("%s: %s = %q is not in the catalog", fset.Position(ident.Pos()), ident.Name, slug)
+				}
+			}
+			return true
+		})
+	}
+
+	if found == 0 {
+		t.Fatal("no slug constants found")
+	}
+}
+
+// Slugs are passed as constants, a string literal must still be registered.
+func TestSlugLiteralsAreRegistered(t *testing.T) {
+	fset := token.NewFileSet()
+	err := filepath.Walk(moduleRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if name := info.Name(); name == ".git" || name == "vendor" || path == filepath.Join(moduleRoot, "pkg", "api") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			pos, ok := slugArgs[calleeName(call)]
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+
+			if pos < 0 {
+				pos += len(call.Args)
+			}
+			if pos < 0 || pos >= len(call.Args) {
+				return true
+			}
+
+			if slug, ok := stringLit(call.Args[pos]); ok && !appErr.IsRegistered(slug) {
+				t.Errorf("%s: slug %q is not in the catalog", fset.Position(call.Pos()), slug)
+			}
+			return true
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCatalogIsDescribed(t *testing.T) {
+	for _, d := range appErr.Catalog() {
+		if d.Description == "" {
+			t.Errorf("%s has no description", d.Slug)
+		}
+	}
+}
+
+func calleeName(call *ast.CallExpr) string {
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		return fn.Name
+	case *ast.SelectorExpr:
+		return fn.Sel.Name
+	}
+
+	return ""
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
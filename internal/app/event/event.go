@@ -0,0 +1,105 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	UserRegistered      Type = "user.registered"
+	UserSettingsChanged Type = "user.settings_changed"
+	SessionRevoked      Type = "session.revoked"
+	UserDeleted         Type = "user.deleted"
+)
+
+// Types lists every event type the service emits.
+func Types() []Type {
+	return []Type{UserRegistered, UserSettingsChanged, SessionRevoked, UserDeleted}
+}
+
+func IsKnownType(t string) bool {
+	for _, known := range Types() {
+		if string(known) == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event is a domain event stored in the outbox in the same transaction as the
+// change it describes. UUID is stable across redeliveries so consumers can
+// deduplicate.
+type Event struct {
+	UUID       uuid.UUID
+	Type       Type
+	UserUUID   uuid.UUID
+	Payload    map[string]interface{}
+	OccurredAt time.Time
+	Attempts   int
+}
+
+func New(t Type, userUUID uuid.UUID, payload map[string]interface{}) *Event {
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+
+	return &Event{
+		UUID:       uuid.New(),
+		Type:       t,
+		UserUUID:   userUUID,
+		Payload:    payload,
+		OccurredAt: time.Now(),
+	}
+}
+
+// Message is the JSON representation of an event sent to consumers.
+type Message struct {
+	ID         uuid.UUID              `json:"id"`
+	Type       string                 `json:"type"`
+	UserUUID   uuid.UUID              `json:"user_uuid"`
+	Payload    map[string]interface{} `json:"payload"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+func NewMessage(e *Event) *Message {
+	return &Message{
+		ID:         e.UUID,
+		Type:       string(e.Type),
+		UserUUID:   e.UserUUID,
+		Payload:    e.Payload,
+		OccurredAt: e.OccurredAt,
+	}
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// Publishers publishes every event to all of its publishers in order and
+// stops at the first failure.
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, event *Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type OutboxRepository interface {
+	Add(ctx context.Context, event *Event) error
+	// ClaimPending moves the next attempt of due unpublished events to until
+	// and returns them, so other relays skip them while they are published.
+	// It is a single statement and commits on its own outside a transaction.
+	ClaimPending(ctx context.Context, limit int, until time.Time) ([]*Event, error)
+	MarkPublished(ctx context.Context, uuid uuid.UUID) error
+	MarkFailed(ctx context.Context, uuid uuid.UUID, reason string, nextAttemptAt time.Time) error
+}
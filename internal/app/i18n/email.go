@@ -0,0 +1,135 @@
+package i18n
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+const (
+	EmailNewDevice     = "new_device"
+	EmailPasswordReset = "password_reset"
+)
+
+// Email is a localized email template, both parts are text/template
+// sources.
+type Email struct {
+	Subject string
+	Text    string
+}
+
+// NewDeviceData fills the EmailNewDevice template.
+type NewDeviceData struct {
+	Name      string
+	Time      string
+	Ip        string
+	UserAgent string
+	Link      string
+}
+
+// PasswordResetData fills the EmailPasswordReset template.
+type PasswordResetData struct {
+	Name string
+	Link string
+}
+
+var emails = map[Locale]map[string]*Email{
+	En: {
+		EmailNewDevice: {
+			Subject: "New sign in to your account",
+			Text: `Hello, {{.Name}}!
+
+Your account was just signed in to from a new device.
+
+Time: {{.Time}}
+IP address: {{.Ip}}
+Device: {{.UserAgent}}
+
+If it was you, no action is needed. If it wasn't, follow the link below to end
+this session and reset your password:
+
+{{.Link}}
+`,
+		},
+		EmailPasswordReset: {
+			Subject: "Set a new password",
+			Text: `Hello, {{.Name}}!
+
+All sessions of your account were ended and a new password is required to
+sign in again. Follow the link below to set it, the link works once and
+expires in 24 hours:
+
+{{.Link}}
+
+If you didn't ask for this, your account may be at risk. Don't reuse the old
+password.
+`,
+		},
+	},
+	Ru: {
+		EmailNewDevice: {
+			Subject: "Новый вход в ваш аккаунт",
+			Text: `Здравствуйте, {{.Name}}!
+
+В ваш аккаунт только что выполнен вход с нового устройства.
+
+Время: {{.Time}}
+IP-адрес: {{.Ip}}
+Устройство: {{.UserAgent}}
+
+Если это были вы, ничего делать не нужно. Если нет, перейдите по ссылке ниже,
+чтобы завершить этот сеанс и сменить пароль:
+
+{{.Link}}
+`,
+		},
+		EmailPasswordReset: {
+			Subject: "Задайте новый пароль",
+			Text: `Здравствуйте, {{.Name}}!
+
+Все сеансы вашего аккаунта завершены, для входа нужен новый пароль. Задайте
+его по ссылке ниже, ссылка одноразовая и действует 24 часа:
+
+{{.Link}}
+
+Если вы этого не запрашивали, ваш аккаунт может быть под угрозой. Не
+используйте старый пароль повторно.
+`,
+		},
+	},
+}
+
+// RenderEmail renders the named email in the locale, falling back to the
+// default locale.
+func RenderEmail(l Locale, name string, data interface{}) (subject string, text string, err error) {
+	e, ok := emails[l][name]
+	if !ok {
+		if e, ok = emails[Default][name]; !ok {
+			return "", "", fmt.Errorf("unknown email template %q", name)
+		}
+	}
+
+	if subject, err = render(e.Subject, data); err != nil {
+		return "", "", err
+	}
+
+	if text, err = render(e.Text, data); err != nil {
+		return "", "", err
+	}
+
+	return subject, text, nil
+}
+
+func render(source string, data interface{}) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,76 @@
+package i18n
+
+var en = map[string]string{
+	"api-key-forbidden":                   "API keys cannot be used for this action.",
+	"api-key-loading-error":               "Could not load API keys.",
+	"api-key-not-found":                   "API key not found.",
+	"api-key-saving-error":                "Could not save the API key.",
+	"audit-search-error":                  "Could not load the activity log.",
+	"client-already-exists":               "A client with this id already exists.",
+	"client-not-found":                    "Client not found.",
+	"client-saving-error":                 "Could not save the client.",
+	"could-not-authorize-client":          "Could not authorize the client.",
+	"could-not-authorize-user":            "Could not authorize the user.",
+	"create-user-error":                   "Could not create the user.",
+	"csrf-token-invalid":                  "The CSRF token is missing or invalid.",
+	"device-id-required":                  "The device id is required.",
+	"dpop-not-supported":                  "DPoP-bound tokens are not supported here.",
+	"email-already-in-use":                "This email has just been registered by someone else.",
+	"field-client-id-required":            "The client id is required.",
+	"field-currency-invalid":              "The currency is not supported.",
+	"field-currency-required":             "The currency is required.",
+	"field-email-invalid":                 "The email is invalid or already in use.",
+	"field-email-required":                "The email is required.",
+	"field-event-types-invalid":           "Some of the event types are unknown.",
+	"field-event-types-required":          "At least one event type is required.",
+	"field-expires-at-invalid":            "The expiration time must be in the future.",
+	"field-locale-invalid":                "The language is not supported.",
+	"field-name-invalid-length":           "The name length is invalid.",
+	"field-name-required":                 "The name is required.",
+	"field-new-password-invalid":          "The new password must differ from the current one.",
+	"field-new-password-invalid-length":   "The new password is too short.",
+	"field-new-password-required":         "The new password is required.",
+	"field-password-invalid-length":       "The password is too short.",
+	"field-password-required":             "The password is required.",
+	"field-reason-required":               "The reason is required.",
+	"field-scopes-invalid":                "Some of the scopes are not allowed.",
+	"field-token-required":                "The token is required.",
+	"field-url-invalid":                   "The URL is invalid.",
+	"field-uuid-invalid":                  "The identifier is invalid.",
+	"impersonation-error":                 "Could not start impersonation.",
+	"impersonation-forbidden":             "Impersonation is not allowed in this session.",
+	"impersonation-not-active":            "No impersonation is active.",
+	"impersonation-not-allowed":           "Impersonation is not allowed for this user.",
+	"impersonation-not-found":             "Impersonation not found.",
+	"internal-server-error":               "Something went wrong, please try again later.",
+	"invalid-client":                      "The client credentials are invalid.",
+	"invalid-credentials":                 "The email or password is incorrect.",
+	"invalid-dpop-proof":                  "The DPoP proof is invalid.",
+	"invalid-input":                       "The request is invalid.",
+	"invalid-scope":                       "The requested scope is not allowed.",
+	"invalid-token":                       "The token is invalid or expired.",
+	"lookup-error":                        "Could not look up users.",
+	"lookup-too-many-users":               "Too many users were requested at once.",
+	"method-not-found":                    "The method does not exist.",
+	"password-reset-required":             "A password reset is required, use the link sent to your email.",
+	"permission-denied":                   "You do not have permission for this action.",
+	"role-not-found":                      "Role not found.",
+	"service-token-required":              "A service token is required.",
+	"session-binding-violated":            "The session belongs to another device or network.",
+	"session-limit-confirmation-required": "Too many active sessions, confirm ending the oldest ones.",
+	"session-limit-reached":               "Too many active sessions.",
+	"session-not-found":                   "The session has ended.",
+	"unsupported-grant-type":              "The grant type is not supported.",
+	"use-dpop-nonce":                      "Retry the request with the DPoP nonce provided.",
+	"user-deleting-error":                 "Could not delete the user.",
+	"user-loading-error":                  "Could not load the user.",
+	"user-locked":                         "The account is locked.",
+	"user-not-found":                      "User not found.",
+	"user-saving-error":                   "Could not save the user.",
+	"user-token-required":                 "A user token is required.",
+	"users-search-error":                  "Could not search users.",
+	"webhook-delivery-not-found":          "Webhook delivery not found.",
+	"webhook-loading-error":               "Could not load webhooks.",
+	"webhook-not-found":                   "Webhook not found.",
+	"webhook-saving-error":                "Could not save the webhook.",
+}
@@ -0,0 +1,154 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Locale string
+
+const (
+	En Locale = "en"
+	Ru Locale = "ru"
+
+	Default = En
+)
+
+var Supported = []Locale{En, Ru}
+
+var catalogs = map[Locale]map[string]string{
+	En: en,
+	Ru: ru,
+}
+
+// ParseLocale accepts a language tag like "ru" or "ru-RU" and returns the
+// supported locale for its primary language.
+func ParseLocale(tag string) (Locale, bool) {
+	lang := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	for _, l := range Supported {
+		if string(l) == lang {
+			return l, true
+		}
+	}
+
+	return "", false
+}
+
+// FromAcceptLanguage picks the supported locale with the highest weight in an
+// Accept-Language header.
+func FromAcceptLanguage(header string) (Locale, bool) {
+	var (
+		best   Locale
+		weight float64
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = part[:i]
+			params := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(params, "q=") {
+				v, err := strconv.ParseFloat(params[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+
+		l, ok := ParseLocale(tag)
+		if !ok || q <= weight {
+			continue
+		}
+
+		best, weight = l, q
+	}
+
+	return best, best != ""
+}
+
+// Resolve returns the first supported locale among the candidates, e.g. the
+// Accept-Language one and the saved user one, or the default.
+func Resolve(candidates ...Locale) Locale {
+	for _, c := range candidates {
+		if _, ok := catalogs[c]; ok {
+			return c
+		}
+	}
+
+	return Default
+}
+
+// Message translates an error slug, falling back to the default locale.
+func Message(l Locale, slug string) (string, bool) {
+	if m, ok := catalogs[l][slug]; ok {
+		return m, true
+	}
+
+	m, ok := catalogs[Default][slug]
+	return m, ok
+}
+
+// Validate reports registered slugs, email templates and pages missing in any of
+// the supported locales, and translations of slugs that are not registered.
+func Validate(slugs []string) error {
+	registered := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		registered[slug] = true
+	}
+
+	var missing []string
+	for _, l := range Supported {
+		missing = append(missing, diff(l, messageKeys(catalogs[l]), registered)...)
+		missing = append(missing, diff(l, emailKeys(emails[l]), emailKeys(emails[Default]))...)
+		missing = append(missing, diff(l, pageKeys(pages[l]), pageKeys(pages[Default]))...)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing or unknown translations: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func diff(l Locale, got, want map[string]bool) []string {
+	var missing []string
+	for k := range want {
+		if !got[k] {
+			missing = append(missing, string(l)+":"+k)
+		}
+	}
+
+	for k := range got {
+		if !want[k] {
+			missing = append(missing, string(l)+":"+k+" (unknown)")
+		}
+	}
+
+	return missing
+}
+
+func messageKeys(m map[string]string) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+
+	return set
+}
+
+func emailKeys(m map[string]*Email) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+
+	return set
+}
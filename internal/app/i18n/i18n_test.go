@@ -0,0 +1,67 @@
+package i18n_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+)
+
+// Every registered slug, email and page must be translated to every
+// supported language, and nothing else.
+func TestTranslationsAreComplete(t *testing.T) {
+	if err := i18n.Validate(appErr.Slugs()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmailsRender(t *testing.T) {
+	emails := map[string]interface{}{
+		i18n.EmailNewDevice: &i18n.NewDeviceData{
+			Name:      "Ann",
+			Time:      "Mon, 19 Oct 2026 12:00:00 UTC",
+			Ip:        "203.0.113.7",
+			UserAgent: "Firefox",
+			Link:      "https://example.com/link",
+		},
+		i18n.EmailPasswordReset: &i18n.PasswordResetData{Name: "Ann", Link: "https://example.com/link"},
+	}
+
+	for _, l := range i18n.Supported {
+		for name, data := range emails {
+			subject, text, err := i18n.RenderEmail(l, name, data)
+			if err != nil {
+				t.Errorf("%s %s: %v", l, name, err)
+				continue
+			}
+
+			if subject == "" || !strings.Contains(text, "https://example.com/link") {
+				t.Errorf("%s %s: subject %q, text without the link:\n%s", l, name, subject, text)
+			}
+		}
+	}
+}
+
+func TestPagesRender(t *testing.T) {
+	pages := []string{i18n.PageNotMe, i18n.PageNotMeDone, i18n.PagePasswordReset, i18n.PagePasswordResetDone, i18n.PageError}
+	for _, l := range i18n.Supported {
+		for _, name := range pages {
+			if err := i18n.RenderPage(ioutil.Discard, l, name, &i18n.PageData{Action: "/action", Token: "token", Message: "message"}); err != nil {
+				t.Errorf("%s %s: %v", l, name, err)
+			}
+		}
+	}
+}
+
+func TestPagesEscapeData(t *testing.T) {
+	var b strings.Builder
+	if err := i18n.RenderPage(&b, i18n.En, i18n.PageNotMe, &i18n.PageData{Action: "/action", Token: `"><script>`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(b.String(), "<script>") {
+		t.Errorf("token is not escaped:\n%s", b.String())
+	}
+}
@@ -0,0 +1,145 @@
+package i18n
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+const (
+	PageNotMe             = "not_me"
+	PageNotMeDone         = "not_me_done"
+	PagePasswordReset     = "password_reset"
+	PagePasswordResetDone = "password_reset_done"
+	PageError             = "error"
+)
+
+// Page is a localized HTML page, Body is an html/template source rendered
+// inside the shared layout.
+type Page struct {
+	Title string
+	Body  string
+}
+
+// PageData fills the page templates. Action is the URL forms post to.
+type PageData struct {
+	Action  string
+	Token   string
+	Message string
+}
+
+const layout = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{template "body" .Data}}
+</body>
+</html>
+`
+
+var pages = map[Locale]map[string]*Page{
+	En: {
+		PageNotMe: {
+			Title: "Wasn't you?",
+			Body: `<p>Confirm to end every session of your account. You will get an email
+with a link to set a new password.</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">It wasn't me</button>
+</form>`,
+		},
+		PageNotMeDone: {
+			Title: "Account secured",
+			Body:  `<p>Every session was ended. Check your email for the link to set a new password.</p>`,
+		},
+		PagePasswordReset: {
+			Title: "Set a new password",
+			Body: `<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>New password <input type="password" name="new_password" minlength="5" required autocomplete="new-password"></label>
+<button type="submit">Save</button>
+</form>`,
+		},
+		PagePasswordResetDone: {
+			Title: "Password changed",
+			Body:  `<p>Your password was changed, sign in with the new one.</p>`,
+		},
+		PageError: {
+			Title: "Something went wrong",
+			Body:  `<p>{{.Message}}</p>`,
+		},
+	},
+	Ru: {
+		PageNotMe: {
+			Title: "Это были не вы?",
+			Body: `<p>Подтвердите, чтобы завершить все сеансы вашего аккаунта. Вы получите
+письмо со ссылкой для смены пароля.</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Это был не я</button>
+</form>`,
+		},
+		PageNotMeDone: {
+			Title: "Аккаунт защищён",
+			Body:  `<p>Все сеансы завершены. Ссылка для смены пароля отправлена вам на почту.</p>`,
+		},
+		PagePasswordReset: {
+			Title: "Задайте новый пароль",
+			Body: `<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>Новый пароль <input type="password" name="new_password" minlength="5" required autocomplete="new-password"></label>
+<button type="submit">Сохранить</button>
+</form>`,
+		},
+		PagePasswordResetDone: {
+			Title: "Пароль изменён",
+			Body:  `<p>Пароль изменён, войдите с новым паролем.</p>`,
+		},
+		PageError: {
+			Title: "Что-то пошло не так",
+			Body:  `<p>{{.Message}}</p>`,
+		},
+	},
+}
+
+// RenderPage writes the named page in the locale, falling back to the
+// default locale.
+func RenderPage(w io.Writer, l Locale, name string, data *PageData) error {
+	p, ok := pages[l][name]
+	if !ok {
+		if p, ok = pages[Default][name]; !ok {
+			return fmt.Errorf("unknown page %q", name)
+		}
+		l = Default
+	}
+
+	t, err := template.New("layout").Parse(layout)
+	if err != nil {
+		return err
+	}
+
+	if _, err = t.New("body").Parse(p.Body); err != nil {
+		return err
+	}
+
+	return t.Execute(w, map[string]interface{}{
+		"Lang":  string(l),
+		"Title": p.Title,
+		"Data":  data,
+	})
+}
+
+func pageKeys(m map[string]*Page) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+
+	return set
+}
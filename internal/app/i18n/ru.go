@@ -0,0 +1,76 @@
+package i18n
+
+var ru = map[string]string{
+	"api-key-forbidden":                   "Это действие нельзя выполнить с API-ключом.",
+	"api-key-loading-error":               "Не удалось загрузить API-ключи.",
+	"api-key-not-found":                   "API-ключ не найден.",
+	"api-key-saving-error":                "Не удалось сохранить API-ключ.",
+	"audit-search-error":                  "Не удалось загрузить журнал действий.",
+	"client-already-exists":               "Клиент с таким идентификатором уже существует.",
+	"client-not-found":                    "Клиент не найден.",
+	"client-saving-error":                 "Не удалось сохранить клиента.",
+	"could-not-authorize-client":          "Не удалось авторизовать клиента.",
+	"could-not-authorize-user":            "Не удалось авторизовать пользователя.",
+	"create-user-error":                   "Не удалось создать пользователя.",
+	"csrf-token-invalid":                  "CSRF-токен отсутствует или недействителен.",
+	"device-id-required":                  "Требуется идентификатор устройства.",
+	"dpop-not-supported":                  "Токены с привязкой DPoP здесь не поддерживаются.",
+	"email-already-in-use":                "Этот email только что зарегистрировал кто-то другой.",
+	"field-client-id-required":            "Требуется идентификатор клиента.",
+	"field-currency-invalid":              "Валюта не поддерживается.",
+	"field-currency-required":             "Укажите валюту.",
+	"field-email-invalid":                 "Email некорректен или уже используется.",
+	"field-email-required":                "Укажите email.",
+	"field-event-types-invalid":           "Некоторые типы событий неизвестны.",
+	"field-event-types-required":          "Укажите хотя бы один тип события.",
+	"field-expires-at-invalid":            "Срок действия должен быть в будущем.",
+	"field-locale-invalid":                "Язык не поддерживается.",
+	"field-name-invalid-length":           "Недопустимая длина имени.",
+	"field-name-required":                 "Укажите имя.",
+	"field-new-password-invalid":          "Новый пароль должен отличаться от текущего.",
+	"field-new-password-invalid-length":   "Новый пароль слишком короткий.",
+	"field-new-password-required":         "Укажите новый пароль.",
+	"field-password-invalid-length":       "Пароль слишком короткий.",
+	"field-password-required":             "Укажите пароль.",
+	"field-reason-required":               "Укажите причину.",
+	"field-scopes-invalid":                "Некоторые области доступа не разрешены.",
+	"field-token-required":                "Требуется токен.",
+	"field-url-invalid":                   "Некорректный URL.",
+	"field-uuid-invalid":                  "Некорректный идентификатор.",
+	"impersonation-error":                 "Не удалось войти от имени пользователя.",
+	"impersonation-forbidden":             "В этом сеансе нельзя войти от имени пользователя.",
+	"impersonation-not-active":            "Вход от имени пользователя не активен.",
+	"impersonation-not-allowed":           "Вход от имени этого пользователя запрещён.",
+	"impersonation-not-found":             "Вход от имени пользователя не найден.",
+	"internal-server-error":               "Что-то пошло не так, попробуйте позже.",
+	"invalid-client":                      "Неверные учётные данные клиента.",
+	"invalid-credentials":                 "Неверный email или пароль.",
+	"invalid-dpop-proof":                  "Недействительное DPoP-доказательство.",
+	"invalid-input":                       "Некорректный запрос.",
+	"invalid-scope":                       "Запрошенная область доступа не разрешена.",
+	"invalid-token":                       "Токен недействителен или истёк.",
+	"lookup-error":                        "Не удалось найти пользователей.",
+	"lookup-too-many-users":               "Запрошено слишком много пользователей сразу.",
+	"method-not-found":                    "Метод не существует.",
+	"password-reset-required":             "Требуется сменить пароль по ссылке из письма.",
+	"permission-denied":                   "Недостаточно прав для этого действия.",
+	"role-not-found":                      "Роль не найдена.",
+	"service-token-required":              "Требуется сервисный токен.",
+	"session-binding-violated":            "Сеанс принадлежит другому устройству или сети.",
+	"session-limit-confirmation-required": "Слишком много активных сеансов, подтвердите завершение самых старых.",
+	"session-limit-reached":               "Слишком много активных сеансов.",
+	"session-not-found":                   "Сеанс завершён.",
+	"unsupported-grant-type":              "Тип гранта не поддерживается.",
+	"use-dpop-nonce":                      "Повторите запрос с выданным DPoP nonce.",
+	"user-deleting-error":                 "Не удалось удалить пользователя.",
+	"user-loading-error":                  "Не удалось загрузить пользователя.",
+	"user-locked":                         "Аккаунт заблокирован.",
+	"user-not-found":                      "Пользователь не найден.",
+	"user-saving-error":                   "Не удалось сохранить пользователя.",
+	"user-token-required":                 "Требуется пользовательский токен.",
+	"users-search-error":                  "Не удалось найти пользователей.",
+	"webhook-delivery-not-found":          "Доставка вебхука не найдена.",
+	"webhook-loading-error":               "Не удалось загрузить вебхуки.",
+	"webhook-not-found":                   "Вебхук не найден.",
+	"webhook-saving-error":                "Не удалось сохранить вебхук.",
+}
@@ -0,0 +1,66 @@
+package role
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	PermissionProfileRead  = "profile:read"
+	PermissionProfileWrite = "profile:write"
+	PermissionAdmin        = "admin"
+)
+
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+type RoleRepository interface {
+	FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*Role, error)
+	AssignToUser(ctx context.Context, userUUID uuid.UUID, role string) error
+	RevokeFromUser(ctx context.Context, userUUID uuid.UUID, role string) error
+}
+
+// Names returns role names of the given roles.
+func Names(roles []*Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+
+	return names
+}
+
+// Permissions returns the deduplicated union of permissions of the given roles.
+func Permissions(roles []*Role) []string {
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, r := range roles {
+		for _, p := range r.Permissions {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+
+	return permissions
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+)
+
+// AccessService resolves any credential accepted by the protected APIs
+// (access JWT, impersonation token, API key, service token) to access claims.
+type AccessService struct {
+	jwtService           *jwt.JWTService
+	apiKeyService        *APIKeyService
+	impersonationService *ImpersonationService
+}
+
+func NewAccessService(jwt *jwt.JWTService, aks *APIKeyService, is *ImpersonationService) *AccessService {
+	return &AccessService{
+		jwtService:           jwt,
+		apiKeyService:        aks,
+		impersonationService: is,
+	}
+}
+
+func (h *AccessService) ValidateAccess(ctx context.Context, token string) (*jwt.AccessJWT, error) {
+	if apikey.IsAPIKey(token) {
+		return h.apiKeyService.Authenticate(ctx, token)
+	}
+
+	access, err := h.jwtService.ValidateAccess(token)
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	if access.Claims.IsImpersonated() {
+		if err = h.impersonationService.Validate(ctx, &access.Claims); err != nil {
+			return &jwt.AccessJWT{}, err
+		}
+	}
+
+	return access, nil
+}
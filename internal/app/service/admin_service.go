@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultUsersPageSize = 50
+	MaxUsersPageSize     = 200
+)
+
+type AdminService struct {
+	userRepository    user.UserRepository
+	refreshRepository RefreshJWTRepository
+	apiKeyRepository  apikey.APIKeyRepository
+	roleRepository    role.RoleRepository
+	txManager         TxManager
+	outbox            event.OutboxRepository
+	auditRepository   audit.AuditRepository
+	passwordResets    *PasswordResetService
+}
+
+type UsersPage struct {
+	Users []*user.User
+	Next  *user.Cursor
+}
+
+type UserDetails struct {
+	User     *user.User
+	Roles    []*role.Role
+	Sessions []*Session
+}
+
+func NewAdminService(
+	ur user.UserRepository,
+	rfr RefreshJWTRepository,
+	akr apikey.APIKeyRepository,
+	rr role.RoleRepository,
+	tm TxManager,
+	or event.OutboxRepository,
+	ar audit.AuditRepository,
+	prs *PasswordResetService,
+) *AdminService {
+	return &AdminService{
+		passwordResets:    prs,
+		userRepository:    ur,
+		refreshRepository: rfr,
+		apiKeyRepository:  akr,
+		roleRepository:    rr,
+		txManager:         tm,
+		outbox:            or,
+		auditRepository:   ar,
+	}
+}
+
+func (h *AdminService) ListUsers(ctx context.Context, filter *user.Filter) (*UsersPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultUsersPageSize
+	}
+
+	if filter.Limit > MaxUsersPageSize {
+		filter.Limit = MaxUsersPageSize
+	}
+
+	// one extra row tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	users, err := h.userRepository.Search(ctx, filter)
+	if err != nil {
+		return &UsersPage{}, appErr.Wrap(err, appErr.SlugUsersSearchError)
+	}
+
+	page := &UsersPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.Next = user.CursorFor(page.Users[limit-1])
+	}
+
+	return page, nil
+}
+
+func (h *AdminService) GetUser(ctx context.Context, userUUID uuid.UUID) (*UserDetails, error) {
+	u, err := h.userRepository.FindById(ctx, userUUID)
+	if err != nil {
+		return &UserDetails{}, err
+	}
+
+	roles, err := h.roleRepository.FindForUser(ctx, userUUID)
+	if err != nil {
+		return &UserDetails{}, appErr.Wrap(err, appErr.SlugUserLoadingError)
+	}
+
+	sessions, err := h.refreshRepository.FindForUser(ctx, userUUID)
+	if err != nil {
+		return &UserDetails{}, appErr.Wrap(err, appErr.SlugUserLoadingError)
+	}
+
+	return &UserDetails{
+		User:     u,
+		Roles:    roles,
+		Sessions: sessions,
+	}, nil
+}
+
+func (h *AdminService) ForceLogout(ctx context.Context, userUUID uuid.UUID) error {
+	if _, err := h.userRepository.FindById(ctx, userUUID); err != nil {
+		return err
+	}
+
+	err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, userUUID, RevokeReasonForcedLogout)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	return nil
+}
+
+// Lock prevents the user from signing in and refreshing tokens and ends all
+// active sessions.
+func (h *AdminService) Lock(ctx context.Context, userUUID uuid.UUID) error {
+	if _, err := h.userRepository.FindById(ctx, userUUID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.SetLocked(ctx, userUUID, &now); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventUserLocked, &userUUID, nil); err != nil {
+			return err
+		}
+
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, userUUID, RevokeReasonUserLocked)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	return nil
+}
+
+func (h *AdminService) Unlock(ctx context.Context, userUUID uuid.UUID) error {
+	if _, err := h.userRepository.FindById(ctx, userUUID); err != nil {
+		return err
+	}
+
+	err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.SetLocked(ctx, userUUID, nil); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, h.auditRepository, audit.EventUserUnlocked, &userUUID, nil)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	return nil
+}
+
+// ForcePasswordReset ends all user sessions, revokes the user API keys,
+// requires a new password and emails the user a password reset link.
+func (h *AdminService) ForcePasswordReset(ctx context.Context, userUUID uuid.UUID) error {
+	u, err := h.userRepository.FindById(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.SetPasswordResetRequired(ctx, userUUID, true); err != nil {
+			return err
+		}
+
+		if err := h.apiKeyRepository.RevokeForUser(ctx, userUUID, time.Now()); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventPasswordResetForced, &userUUID, nil); err != nil {
+			return err
+		}
+
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, userUUID, RevokeReasonPasswordReset)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	h.passwordResets.SendLink(u, "")
+
+	return nil
+}
+
+func (h *AdminService) UpdateSettings(ctx context.Context, request *UpdateSettingsRequest) (*user.User, error) {
+	u, err := h.userRepository.FindById(ctx, request.UserUUID)
+	if err != nil {
+		return &user.User{}, err
+	}
+
+	settings, err := settingsFromRequest(u.Settings, request)
+	if err != nil {
+		return &user.User{}, err
+	}
+
+	return updateSettings(ctx, h.txManager, h.userRepository, h.outbox, h.auditRepository, request.UserUUID, settings)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// APIKeyClaim is the "ext" claim holding the uuid of the API key a request
+// was authenticated with.
+const APIKeyClaim = "api_key"
+
+type APIKeyService struct {
+	apiKeyRepository apikey.APIKeyRepository
+	userRepository   user.UserRepository
+	roleRepository   role.RoleRepository
+	authService      *AuthService
+}
+
+type CreateAPIKeyRequest struct {
+	UserUUID  uuid.UUID
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type CreatedAPIKey struct {
+	Key    *apikey.APIKey
+	Secret string
+}
+
+func NewAPIKeyService(akr apikey.APIKeyRepository, ur user.UserRepository, rr role.RoleRepository, as *AuthService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepository: akr,
+		userRepository:   ur,
+		roleRepository:   rr,
+		authService:      as,
+	}
+}
+
+// Create issues a key limited to the given scopes, which must be a subset of
+// the user's own permissions. Without scopes the key gets all of them.
+func (h *APIKeyService) Create(ctx context.Context, r *CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return &CreatedAPIKey{}, appErr.NewIncorrectInputError("Expiration must be in the future", appErr.SlugFieldExpiresAtInvalid)
+	}
+
+	roles, err := h.roleRepository.FindForUser(ctx, r.UserUUID)
+	if err != nil {
+		return &CreatedAPIKey{}, appErr.Wrap(err, appErr.SlugAPIKeySavingError)
+	}
+
+	// keys never administer, a leaked key must not reach the admin API
+	var permissions []string
+	for _, p := range role.Permissions(roles) {
+		if p != role.PermissionAdmin {
+			permissions = append(permissions, p)
+		}
+	}
+
+	scopes := r.Scopes
+	if len(scopes) == 0 {
+		scopes = permissions
+	}
+
+	for _, scope := range scopes {
+		if scope == role.PermissionAdmin {
+			return &CreatedAPIKey{}, appErr.NewIncorrectInputError("Scope "+scope+" can't be granted to API keys", appErr.SlugFieldScopesInvalid)
+		}
+
+		if !contains(permissions, scope) {
+			return &CreatedAPIKey{}, appErr.NewIncorrectInputError("Scope "+scope+" is not granted to the user", appErr.SlugFieldScopesInvalid)
+		}
+	}
+
+	secret, err := apikey.Generate()
+	if err != nil {
+		return &CreatedAPIKey{}, appErr.Wrap(err, appErr.SlugAPIKeySavingError)
+	}
+
+	key := &apikey.APIKey{
+		UUID:      uuid.New(),
+		UserUUID:  r.UserUUID,
+		Name:      r.Name,
+		Prefix:    apikey.DisplayPrefix(secret),
+		Hash:      apikey.Hash(secret),
+		Scopes:    scopes,
+		ExpiresAt: r.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	err = h.apiKeyRepository.Add(ctx, key)
+	if err != nil {
+		return &CreatedAPIKey{}, appErr.Wrap(err, appErr.SlugAPIKeySavingError)
+	}
+
+	return &CreatedAPIKey{Key: key, Secret: secret}, nil
+}
+
+func (h *APIKeyService) List(ctx context.Context, userUUID uuid.UUID) ([]*apikey.APIKey, error) {
+	keys, err := h.apiKeyRepository.FindForUser(ctx, userUUID)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.SlugAPIKeyLoadingError)
+	}
+
+	return keys, nil
+}
+
+func (h *APIKeyService) Revoke(ctx context.Context, userUUID, keyUUID uuid.UUID) error {
+	return h.apiKeyRepository.Revoke(ctx, keyUUID, userUUID, time.Now())
+}
+
+// Authenticate resolves an API key secret to the same access claims a signed
+// in user gets, narrowed down to the key scopes.
+func (h *APIKeyService) Authenticate(ctx context.Context, secret string) (*jwt.AccessJWT, error) {
+	key, err := h.apiKeyRepository.FindByHash(ctx, apikey.Hash(secret))
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError("API key is not active", appErr.SlugInvalidToken)
+	}
+
+	u, err := h.userRepository.FindById(ctx, key.UserUUID)
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	if u.IsLocked() {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError("User is locked", appErr.SlugUserLocked)
+	}
+
+	if u.PasswordResetRequired {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError("Password reset required", appErr.SlugPasswordResetRequired)
+	}
+
+	claims, err := h.authService.AccessClaimsFor(ctx, u)
+	if err != nil {
+		return &jwt.AccessJWT{}, err
+	}
+
+	// permissions revoked from the user since the key was created are dropped
+	granted := claims.Scopes()
+	claims.Scope = ""
+	for _, scope := range key.Scopes {
+		if contains(granted, scope) {
+			claims.AddScopes(scope)
+		}
+	}
+
+	claims.SetExtra(APIKeyClaim, key.UUID.String())
+	if key.ExpiresAt != nil {
+		claims.SetExpiresAt(*key.ExpiresAt)
+	}
+
+	_ = h.apiKeyRepository.Touch(ctx, key.UUID, now)
+
+	return &jwt.AccessJWT{
+		Claims: *claims,
+		Token:  secret,
+	}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
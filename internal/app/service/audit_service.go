@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+type AuditService struct {
+	auditRepository audit.AuditRepository
+}
+
+type AuditPage struct {
+	Events []*audit.Event
+	Next   *user.Cursor
+}
+
+func NewAuditService(ar audit.AuditRepository) *AuditService {
+	return &AuditService{
+		auditRepository: ar,
+	}
+}
+
+// ListForUser returns the activity of one user, newest first.
+func (h *AuditService) ListForUser(ctx context.Context, userUUID uuid.UUID, after *user.Cursor, limit int) (*AuditPage, error) {
+	return h.Search(ctx, &audit.Filter{
+		UserUUID: &userUUID,
+		After:    after,
+		Limit:    limit,
+	})
+}
+
+func (h *AuditService) Search(ctx context.Context, filter *audit.Filter) (*AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+
+	if filter.Limit > MaxAuditPageSize {
+		filter.Limit = MaxAuditPageSize
+	}
+
+	// one extra row tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	events, err := h.auditRepository.Find(ctx, filter)
+	if err != nil {
+		return &AuditPage{}, appErr.Wrap(err, appErr.SlugAuditSearchError)
+	}
+
+	page := &AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.Next = &user.Cursor{CreatedAt: last.CreatedAt, UUID: last.UUID}
+	}
+
+	return page, nil
+}
+
+// recordAudit stores an event with the actor and request metadata found in
+// the context.
+func recordAudit(ctx context.Context, ar audit.AuditRepository, t audit.EventType, subject *uuid.UUID, data map[string]interface{}) error {
+	meta := audit.MetaFrom(ctx)
+
+	return ar.Add(ctx, audit.NewEvent(t, meta.ActorUUID, subject, data).WithMeta(meta))
+}
@@ -5,18 +5,35 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/audit"
 	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/internal/app/role"
 	"github.com/bysoft-wallet/users/internal/app/user"
 	"github.com/bysoft-wallet/users/pkg/currency"
 	"github.com/bysoft-wallet/users/pkg/jwt"
 	"github.com/google/uuid"
 )
 
+const minPasswordLength = 5
+
 type AuthService struct {
 	userRepository    user.UserRepository
 	jwtService        *jwt.JWTService
 	refreshRepository RefreshJWTRepository
+	apiKeyRepository  apikey.APIKeyRepository
+	roleRepository    role.RoleRepository
+	txManager         TxManager
+	outbox            event.OutboxRepository
+	auditRepository   audit.AuditRepository
 	maxUserSessions   int
+	claimsEnrichers   []ClaimsEnricher
+	sessionListeners  []SessionListener
+	sessionBinding    SessionBinding
+	revokeOnViolation bool
+	sessionLimit      SessionLimitPolicy
 }
 
 type LoginResponse struct {
@@ -25,51 +42,217 @@ type LoginResponse struct {
 }
 
 type SignInRequest struct {
-	Email    string
-	Password string
-	Ip       string
+	Email     string
+	Password  string
+	Ip        string
+	UserAgent string
+	DeviceId  string
+	// Locale is the client language, used for emails to users without a
+	// saved one.
+	Locale i18n.Locale
+	// Jkt is the thumbprint of a verified DPoP proof key, the issued tokens
+	// are bound to it.
+	Jkt string
+	// EvictSessions confirms ending the least recently used sessions when
+	// the session limit is reached and the confirm policy is used.
+	EvictSessions bool
 }
 
 type SignUpRequest struct {
-	Email    string
-	Password string
-	Name     string
+	Email     string
+	Password  string
+	Name      string
+	Ip        string
+	UserAgent string
+	DeviceId  string
+	Locale    i18n.Locale
+	Jkt       string
+}
+
+type ChangePasswordRequest struct {
+	UserUUID    uuid.UUID
+	Password    string
+	NewPassword string
+	Ip          string
+	DeviceId    string
+	Jkt         string
+}
+
+type RefreshRequest struct {
+	Token    string
+	Ip       string
+	DeviceId string
+	Jkt      string
+}
+
+// tokenParams describe the client a new session is issued to.
+type tokenParams struct {
 	Ip       string
+	DeviceId string
+	Jkt      string
+	Evict    bool
+}
+
+type DeleteUserRequest struct {
+	UserUUID uuid.UUID
+	Password string
 }
 
 type UpdateSettingsRequest struct {
 	UserUUID uuid.UUID
 	Currency string
+	// Locale is optional, the saved one is kept when it is empty.
+	Locale string
+}
+
+type Session struct {
+	UUID      uuid.UUID
+	UserUUID  uuid.UUID
+	Ip        string
+	DeviceId  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// StartedSession describes a session created by a sign in or sign up.
+type StartedSession struct {
+	UUID      uuid.UUID
+	Ip        string
+	UserAgent string
+	Locale    i18n.Locale
+	SignUp    bool
+}
+
+// SessionListener is notified after a sign in or sign up started a session,
+// it must not fail the authentication.
+type SessionListener interface {
+	SessionStarted(ctx context.Context, u *user.User, s *StartedSession)
 }
 
 type RefreshJWTRepository interface {
 	Add(ctx context.Context, refresh *jwt.RefreshJWT) error
-	Exists(ctx context.Context, uuid, userUUID uuid.UUID, ip string, token string) (bool, error)
+	Find(ctx context.Context, uuid, userUUID uuid.UUID, token string) (*Session, error)
+	// Delete fails with a not found error when the session already ended.
 	Delete(ctx context.Context, uuid uuid.UUID) error
 	DeleteForUserUUID(ctx context.Context, userUUID uuid.UUID) error
+	FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*Session, error)
 	CountForUser(ctx context.Context, userUUID uuid.UUID) (int, error)
+	// LockForUser serializes session changes of the user until the end of
+	// the transaction.
+	LockForUser(ctx context.Context, userUUID uuid.UUID) error
+	// DeleteLeastRecentlyUsed deletes all but the keep most recently used
+	// sessions of the user and returns the deleted session uuids.
+	DeleteLeastRecentlyUsed(ctx context.Context, userUUID uuid.UUID, keep int) ([]uuid.UUID, error)
 }
 
-func NewAuthService(ur user.UserRepository, jwt *jwt.JWTService, rfr RefreshJWTRepository, mus int) *AuthService {
+func NewAuthService(
+	ur user.UserRepository,
+	jwt *jwt.JWTService,
+	rfr RefreshJWTRepository,
+	akr apikey.APIKeyRepository,
+	rr role.RoleRepository,
+	tm TxManager,
+	or event.OutboxRepository,
+	ar audit.AuditRepository,
+	mus int,
+) *AuthService {
 	return &AuthService{
 		userRepository:    ur,
 		jwtService:        jwt,
 		refreshRepository: rfr,
+		apiKeyRepository:  akr,
+		roleRepository:    rr,
+		txManager:         tm,
+		outbox:            or,
+		auditRepository:   ar,
 		maxUserSessions:   mus,
+		sessionBinding:    BindingNone,
+		sessionLimit:      SessionLimitEvictLRU,
+	}
+}
+
+func (h *AuthService) SetSessionLimitPolicy(policy SessionLimitPolicy) {
+	h.sessionLimit = policy
+}
+
+// SetSessionBinding configures how refresh tokens are bound to the client
+// that signed in, with revoke a violating session is ended.
+func (h *AuthService) SetSessionBinding(binding SessionBinding, revoke bool) {
+	h.sessionBinding = binding
+	h.revokeOnViolation = revoke
+}
+
+// AddClaimsEnricher registers enrichers that are applied, in order, to every
+// access token issued by the service.
+func (h *AuthService) AddClaimsEnricher(enrichers ...ClaimsEnricher) {
+	h.claimsEnrichers = append(h.claimsEnrichers, enrichers...)
+}
+
+func (h *AuthService) AddSessionListener(listeners ...SessionListener) {
+	h.sessionListeners = append(h.sessionListeners, listeners...)
+}
+
+func (h *AuthService) notifySessionStarted(ctx context.Context, u *user.User, s *StartedSession) {
+	for _, listener := range h.sessionListeners {
+		listener.SessionStarted(ctx, u, s)
 	}
 }
 
 func (h *AuthService) SignIn(ctx context.Context, r *SignInRequest) (*LoginResponse, error) {
 	userFound, err := h.userRepository.FindByEmail(ctx, r.Email)
 	if err != nil {
-		return &LoginResponse{}, appErr.NewIncorrectInputError("User not found", "invalid-credentials")
+		h.auditSignInFailed(ctx, nil, r.Email, "unknown_email")
+		return &LoginResponse{}, appErr.NewIncorrectInputError("User not found", appErr.SlugInvalidCredentials)
 	}
 
 	if !user.CheckPasswordHash(r.Password, userFound.Hash) {
-		return &LoginResponse{}, appErr.NewIncorrectInputError("User not found", "invalid-credentials")
+		h.auditSignInFailed(ctx, &userFound.UUID, r.Email, "invalid_password")
+		return &LoginResponse{}, appErr.NewIncorrectInputError("User not found", appErr.SlugInvalidCredentials)
+	}
+
+	if userFound.IsLocked() {
+		h.auditSignInFailed(ctx, &userFound.UUID, r.Email, "user_locked")
+		return &LoginResponse{}, appErr.NewAuthorizationError("User is locked", appErr.SlugUserLocked)
+	}
+
+	// the old password may be known to whoever forced the reset, only the
+	// emailed link can set a new one
+	if userFound.PasswordResetRequired {
+		h.auditSignInFailed(ctx, &userFound.UUID, r.Email, "password_reset_required")
+		return &LoginResponse{}, appErr.NewAuthorizationError("Password reset required", appErr.SlugPasswordResetRequired)
+	}
+
+	tokens, err := h.createTokens(ctx, userFound, &tokenParams{
+		Ip:       r.Ip,
+		DeviceId: r.DeviceId,
+		Jkt:      r.Jkt,
+		Evict:    r.EvictSessions,
+	})
+	if err != nil {
+		return &LoginResponse{}, err
 	}
 
-	return h.createTokens(ctx, userFound, r.Ip)
+	// authentication events are best effort, a failing audit store must not
+	// lock users out
+	_ = recordAudit(ctx, h.auditRepository, audit.EventSignInSucceeded, &userFound.UUID, map[string]interface{}{
+		"session_uuid": tokens.Refresh.Claims.UUID,
+	})
+
+	h.notifySessionStarted(ctx, userFound, &StartedSession{
+		UUID:      tokens.Refresh.Claims.UUID,
+		Ip:        r.Ip,
+		UserAgent: r.UserAgent,
+		Locale:    r.Locale,
+	})
+
+	return tokens, nil
+}
+
+func (h *AuthService) auditSignInFailed(ctx context.Context, userUUID *uuid.UUID, email, reason string) {
+	_ = recordAudit(ctx, h.auditRepository, audit.EventSignInFailed, userUUID, map[string]interface{}{
+		"email":  email,
+		"reason": reason,
+	})
 }
 
 func (h *AuthService) SignUp(ctx context.Context, r *SignUpRequest) (*LoginResponse, error) {
@@ -79,79 +262,224 @@ func (h *AuthService) SignUp(ctx context.Context, r *SignUpRequest) (*LoginRespo
 			return &LoginResponse{}, err
 		}
 	} else {
-		return &LoginResponse{}, appErr.NewIncorrectInputError("Email already in use", "field-email-invalid")
+		return &LoginResponse{}, appErr.NewIncorrectInputError("Email already in use", appErr.SlugFieldEmailInvalid)
+	}
+
+	user, err := h.createUser(ctx, r.Email, r.Name, r.Password, role.RoleUser)
+	if err != nil {
+		return &LoginResponse{}, err
 	}
 
-	hash, err := user.HashPassword(r.Password)
+	tokens, err := h.createTokens(ctx, user, &tokenParams{
+		Ip:       r.Ip,
+		DeviceId: r.DeviceId,
+		Jkt:      r.Jkt,
+	})
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAppError(err.Error(), "create-user-error")
+		return &LoginResponse{}, err
 	}
 
-	user := user.NewUser(
+	h.notifySessionStarted(ctx, user, &StartedSession{
+		UUID:      tokens.Refresh.Claims.UUID,
+		Ip:        r.Ip,
+		UserAgent: r.UserAgent,
+		Locale:    r.Locale,
+		SignUp:    true,
+	})
+
+	return tokens, nil
+}
+
+// BootstrapAdmin grants the admin role to the user with the given email,
+// creating the user first when it does not exist yet.
+func (h *AuthService) BootstrapAdmin(ctx context.Context, email, name, password string) (*user.User, error) {
+	existing, err := h.userRepository.FindByEmail(ctx, email)
+	if err == nil {
+		err = h.roleRepository.AssignToUser(ctx, existing.UUID, role.RoleAdmin)
+		if err != nil {
+			return &user.User{}, err
+		}
+
+		return existing, nil
+	}
+
+	if !appErr.IsNotFound(err) {
+		return &user.User{}, err
+	}
+
+	return h.createUser(ctx, email, name, password, role.RoleUser, role.RoleAdmin)
+}
+
+func (h *AuthService) createUser(ctx context.Context, email, name, password string, roles ...string) (*user.User, error) {
+	hash, err := user.HashPassword(password)
+	if err != nil {
+		return &user.User{}, appErr.Wrap(err, appErr.SlugCreateUserError)
+	}
+
+	u := user.NewUser(
 		uuid.New(),
-		r.Email,
-		r.Name,
+		email,
+		name,
 		hash,
 		user.DefaultUserSettings(),
 		time.Now(),
 		time.Now(),
 	)
 
-	err = h.userRepository.Add(ctx, user)
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.Add(ctx, u); err != nil {
+			return err
+		}
+
+		for _, r := range roles {
+			if err := h.roleRepository.AssignToUser(ctx, u.UUID, r); err != nil {
+				return err
+			}
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventSignedUp, &u.UUID, map[string]interface{}{
+			"email": u.Email,
+			"roles": roles,
+		}); err != nil {
+			return err
+		}
+
+		return h.outbox.Add(ctx, event.New(event.UserRegistered, u.UUID, map[string]interface{}{
+			"email":    u.Email,
+			"name":     u.Name,
+			"currency": u.Settings.Currency.String(),
+		}))
+	})
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAppError(err.Error(), "user-saving-error")
+		return &user.User{}, appErr.Wrap(err, appErr.SlugUserSavingError)
 	}
 
-	return h.createTokens(ctx, user, r.Ip)
+	return u, nil
 }
 
-func (h *AuthService) createTokens(ctx context.Context, user *user.User, ip string) (*LoginResponse, error) {
-	accessClaims := jwt.NewAccessClaims(
-		user.UUID,
-		user.Email,
-		user.Name,
-	)
+func (h *AuthService) changePassword(ctx context.Context, u *user.User, password string) error {
+	if len(password) < minPasswordLength {
+		return appErr.NewIncorrectInputError("Password is too short", appErr.SlugFieldPasswordInvalidLength)
+	}
+
+	if user.CheckPasswordHash(password, u.Hash) {
+		return appErr.NewIncorrectInputError("New password must differ from the current one", appErr.SlugFieldNewPasswordInvalid)
+	}
+
+	hash, err := user.HashPassword(password)
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.UpdatePassword(ctx, u.UUID, hash); err != nil {
+			return err
+		}
+
+		if err := h.apiKeyRepository.RevokeForUser(ctx, u.UUID, time.Now()); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventPasswordChanged, &u.UUID, map[string]interface{}{
+			"reset_required": u.PasswordResetRequired,
+		}); err != nil {
+			return err
+		}
+
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, u.UUID, RevokeReasonPasswordChanged)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	u.Hash = hash
+	u.PasswordResetRequired = false
+
+	return nil
+}
+
+// AccessClaimsFor builds access token claims for the user with every
+// registered enricher applied.
+func (h *AuthService) AccessClaimsFor(ctx context.Context, u *user.User) (*jwt.AccessClaims, error) {
+	claims := jwt.NewAccessClaims(u.UUID)
+	for _, enricher := range h.claimsEnrichers {
+		if err := enricher.Enrich(ctx, u, claims); err != nil {
+			return &jwt.AccessClaims{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugCouldNotAuthorizeUser)
+		}
+	}
+
+	return claims, nil
+}
+
+func (h *AuthService) createTokens(ctx context.Context, user *user.User, p *tokenParams) (*LoginResponse, error) {
+	if h.sessionBinding == BindingDevice && p.DeviceId == "" {
+		return &LoginResponse{}, appErr.NewIncorrectInputError("Device id required", appErr.SlugDeviceIdRequired)
+	}
+
+	ip := normalizeIp(p.Ip)
+
+	accessClaims, err := h.AccessClaimsFor(ctx, user)
+	if err != nil {
+		return &LoginResponse{}, err
+	}
 
 	refreshClaims := jwt.NewRefreshClaims(
 		user.UUID,
 	)
 
+	if p.Jkt != "" {
+		accessClaims.Cnf = &jwt.Confirmation{Jkt: p.Jkt}
+		refreshClaims.Cnf = &jwt.Confirmation{Jkt: p.Jkt}
+	}
+
 	var access *jwt.AccessJWT
 	var refresh *jwt.RefreshJWT
-	var err error
+	var accessErr, refreshErr error
 	var wg sync.WaitGroup
 
+	wg.Add(2)
+
 	go func() {
-		access, err = h.jwtService.CreateAccess(*accessClaims)
+		access, accessErr = h.jwtService.CreateAccess(*accessClaims)
 		wg.Done()
 	}()
 
 	go func() {
-		refresh, err = h.jwtService.CreateRefresh(*refreshClaims, ip)
+		refresh, refreshErr = h.jwtService.CreateRefresh(*refreshClaims, ip)
 		wg.Done()
 	}()
 
-	wg.Add(2)
+	wg.Wait()
 
-	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "could-not-authorize-user")
+	if accessErr != nil {
+		return &LoginResponse{}, appErr.NewAuthorizationError(accessErr.Error(), appErr.SlugCouldNotAuthorizeUser)
 	}
 
-	refreshCount, err := h.refreshRepository.CountForUser(ctx, refreshClaims.UserId)
-	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "could-not-authorize-user")
+	if refreshErr != nil {
+		return &LoginResponse{}, appErr.NewAuthorizationError(refreshErr.Error(), appErr.SlugCouldNotAuthorizeUser)
 	}
 
-	if refreshCount > h.maxUserSessions {
-		err = h.refreshRepository.DeleteForUserUUID(ctx, refreshClaims.UserId)
-		if err != nil {
-			return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "could-not-authorize-user")
+	refresh.DeviceId = p.DeviceId
+
+	// the user row lock makes concurrent sign ins wait for each other so
+	// the limit check and the insert can't interleave
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.refreshRepository.LockForUser(ctx, user.UUID); err != nil {
+			return err
 		}
-	}
 
-	err = h.refreshRepository.Add(ctx, refresh)
+		if err := h.enforceSessionLimit(ctx, user.UUID, p.Evict); err != nil {
+			return err
+		}
+
+		return h.refreshRepository.Add(ctx, refresh)
+	})
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "could-not-authorize-user")
+		if appErr.IsApp(err) {
+			return &LoginResponse{}, err
+		}
+
+		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugCouldNotAuthorizeUser).WithCause(err)
 	}
 
 	return &LoginResponse{
@@ -160,54 +488,328 @@ func (h *AuthService) createTokens(ctx context.Context, user *user.User, ip stri
 	}, nil
 }
 
+// enforceSessionLimit makes room for one more session according to the
+// session limit policy, call it inside a transaction.
+func (h *AuthService) enforceSessionLimit(ctx context.Context, userUUID uuid.UUID, evict bool) error {
+	if h.maxUserSessions <= 0 {
+		return nil
+	}
+
+	count, err := h.refreshRepository.CountForUser(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	if count < h.maxUserSessions {
+		return nil
+	}
+
+	switch h.sessionLimit {
+	case SessionLimitReject:
+		return appErr.NewAuthorizationError("Session limit reached", appErr.SlugSessionLimitReached)
+	case SessionLimitConfirm:
+		if !evict {
+			return appErr.NewAuthorizationError("Session limit reached, confirm ending the oldest sessions", appErr.SlugSessionLimitConfirmationRequired)
+		}
+	}
+
+	evicted, err := h.refreshRepository.DeleteLeastRecentlyUsed(ctx, userUUID, h.maxUserSessions-1)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"reason":        RevokeReasonSessionLimit,
+		"session_uuids": evicted,
+	}
+
+	if err = recordAudit(ctx, h.auditRepository, audit.EventSessionsRevoked, &userUUID, data); err != nil {
+		return err
+	}
+
+	return h.outbox.Add(ctx, event.New(event.SessionRevoked, userUUID, data))
+}
+
 func (h *AuthService) GetUser(ctx context.Context, user_uuid uuid.UUID) (*user.User, error) {
 	return h.userRepository.FindById(ctx, user_uuid)
 }
 
+func (h *AuthService) GetUserByEmail(ctx context.Context, email string) (*user.User, error) {
+	return h.userRepository.FindByEmail(ctx, email)
+}
+
 func (h *AuthService) SaveRefresh(ctx context.Context, user_uuid uuid.UUID) (*user.User, error) {
 	return h.userRepository.FindById(ctx, user_uuid)
 }
 
-func (h *AuthService) Refresh(ctx context.Context, tokenString, ip string) (*LoginResponse, error) {
-	refresh, err := h.jwtService.ValidateRefresh(tokenString, ip)
+func (h *AuthService) Refresh(ctx context.Context, r *RefreshRequest) (*LoginResponse, error) {
+	refresh, err := h.jwtService.ValidateRefresh(r.Token, r.Ip)
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "invalid-token")
+		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
 	}
 
-	exists, err := h.refreshRepository.Exists(ctx, refresh.Claims.UUID, refresh.Claims.UserId, ip, tokenString)
+	session, err := h.refreshRepository.Find(ctx, refresh.Claims.UUID, refresh.Claims.UserId, r.Token)
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "invalid-token")
+		if appErr.IsNotFound(err) {
+			return &LoginResponse{}, h.refreshTokenReused(ctx, refresh)
+		}
+
+		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
 	}
 
-	if !exists {
-		return &LoginResponse{}, appErr.NewAuthorizationError("Refresh not found", "invalid-token")
+	// a refresh token bound to a DPoP key is only accepted with a proof made
+	// with that key
+	if jkt := refresh.Claims.Thumbprint(); jkt != "" && jkt != r.Jkt {
+		return &LoginResponse{}, appErr.NewAuthorizationError("DPoP proof required", appErr.SlugInvalidDPoPProof)
 	}
 
-	err = h.refreshRepository.Delete(ctx, refresh.Claims.UUID)
-	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "invalid-token")
+	if !h.sessionBinding.Allows(session, r.Ip, r.DeviceId) {
+		return &LoginResponse{}, h.bindingViolated(ctx, session, r)
 	}
 
 	user, err := h.userRepository.FindById(ctx, refresh.Claims.UserId)
 	if err != nil {
-		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), "invalid-token")
+		return &LoginResponse{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	if user.IsLocked() {
+		return &LoginResponse{}, appErr.NewAuthorizationError("User is locked", appErr.SlugUserLocked)
+	}
+
+	if user.PasswordResetRequired {
+		return &LoginResponse{}, appErr.NewAuthorizationError("Password reset required", appErr.SlugPasswordResetRequired)
+	}
+
+	// the rotated token stays bound to the device of the session
+	deviceId := session.DeviceId
+	if deviceId == "" {
+		deviceId = r.DeviceId
+	}
+
+	// the old session ends only together with the new one being saved, a
+	// failed rotation leaves the client its refresh token
+	var tokens *LoginResponse
+	reused := false
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.refreshRepository.Delete(ctx, refresh.Claims.UUID); err != nil {
+			// a concurrent refresh rotated the token first
+			reused = appErr.IsNotFound(err)
+			return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+		}
+
+		tokens, err = h.createTokens(ctx, user, &tokenParams{
+			Ip:       r.Ip,
+			DeviceId: deviceId,
+			Jkt:      r.Jkt,
+		})
+		return err
+	})
+	if reused {
+		return &LoginResponse{}, h.refreshTokenReused(ctx, refresh)
+	}
+	if err != nil {
+		return &LoginResponse{}, err
 	}
 
-	return h.createTokens(ctx, user, ip)
+	_ = recordAudit(ctx, h.auditRepository, audit.EventTokenRefreshed, &user.UUID, map[string]interface{}{
+		"previous_session_uuid": refresh.Claims.UUID,
+		"session_uuid":          tokens.Refresh.Claims.UUID,
+	})
+
+	return tokens, nil
+}
+
+// refreshTokenReused ends every session of the user. A validly signed token
+// that is no longer stored was rotated or revoked already, its reuse may mean
+// it leaked.
+func (h *AuthService) refreshTokenReused(ctx context.Context, refresh *jwt.RefreshJWT) error {
+	userUUID := refresh.Claims.UserId
+	err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := recordAudit(ctx, h.auditRepository, audit.EventRefreshTokenReused, &userUUID, map[string]interface{}{
+			"session_uuid": refresh.Claims.UUID,
+		}); err != nil {
+			return err
+		}
+
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, userUUID, RevokeReasonTokenReused)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	return appErr.NewAuthorizationError("Refresh not found", appErr.SlugInvalidToken)
+}
+
+// Logout ends the session of the refresh token, a session that already
+// ended is not an error.
+func (h *AuthService) Logout(ctx context.Context, tokenString string) error {
+	refresh, err := h.jwtService.ValidateRefresh(tokenString, "")
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	session, err := h.refreshRepository.Find(ctx, refresh.Claims.UUID, refresh.Claims.UserId, tokenString)
+	if err != nil {
+		if appErr.IsNotFound(err) {
+			return nil
+		}
+
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.refreshRepository.Delete(ctx, session.UUID); err != nil {
+			if appErr.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		data := map[string]interface{}{
+			"reason":       RevokeReasonLogout,
+			"session_uuid": session.UUID,
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventSessionsRevoked, &session.UserUUID, data); err != nil {
+			return err
+		}
+
+		return h.outbox.Add(ctx, event.New(event.SessionRevoked, session.UserUUID, data))
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	return nil
+}
+
+// bindingViolated records a refresh attempt from a client the session is not
+// bound to and, when configured, ends the session.
+func (h *AuthService) bindingViolated(ctx context.Context, session *Session, r *RefreshRequest) error {
+	_ = recordAudit(ctx, h.auditRepository, audit.EventSessionBindingViolated, &session.UserUUID, map[string]interface{}{
+		"session_uuid": session.UUID,
+		"binding":      string(h.sessionBinding),
+		"session_ip":   session.Ip,
+		"ip":           normalizeIp(r.Ip),
+		"revoked":      h.revokeOnViolation,
+	})
+
+	if h.revokeOnViolation {
+		err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+			if err := h.refreshRepository.Delete(ctx, session.UUID); err != nil {
+				if appErr.IsNotFound(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			data := map[string]interface{}{
+				"reason":       RevokeReasonBindingViolation,
+				"session_uuid": session.UUID,
+			}
+
+			if err := recordAudit(ctx, h.auditRepository, audit.EventSessionsRevoked, &session.UserUUID, data); err != nil {
+				return err
+			}
+
+			return h.outbox.Add(ctx, event.New(event.SessionRevoked, session.UserUUID, data))
+		})
+		if err != nil {
+			return appErr.Wrap(err, appErr.SlugUserSavingError)
+		}
+	}
+
+	return appErr.NewAuthorizationError("Session is bound to another client", appErr.SlugSessionBindingViolated)
 }
 
 func (h *AuthService) UpdateSettings(ctx context.Context, request *UpdateSettingsRequest) (*user.User, error) {
+	u, err := h.userRepository.FindById(ctx, request.UserUUID)
+	if err != nil {
+		return &user.User{}, err
+	}
+
+	settings, err := settingsFromRequest(u.Settings, request)
+	if err != nil {
+		return &user.User{}, err
+	}
+
+	return updateSettings(ctx, h.txManager, h.userRepository, h.outbox, h.auditRepository, request.UserUUID, settings)
+}
+
+// settingsFromRequest applies the requested changes to the current settings.
+func settingsFromRequest(current user.Settings, request *UpdateSettingsRequest) (*user.Settings, error) {
 	cur, err := currency.FromString(request.Currency)
 	if err != nil {
-		return &user.User{}, appErr.NewIncorrectInputError("Invalid currency", "field-currency-invalid")
+		return nil, appErr.NewIncorrectInputError("Invalid currency", appErr.SlugFieldCurrencyInvalid)
 	}
 
 	settings := user.NewSettings(cur)
+	settings.Locale = current.Locale
+
+	if request.Locale != "" {
+		locale, ok := i18n.ParseLocale(request.Locale)
+		if !ok {
+			return nil, appErr.NewIncorrectInputError("Unsupported locale", appErr.SlugFieldLocaleInvalid)
+		}
+		settings.Locale = locale
+	}
+
+	return &settings, nil
+}
 
-	_, err = h.userRepository.FindById(ctx, request.UserUUID)
+// ChangePassword replaces the user password, ends every session and signs the
+// user in again.
+func (h *AuthService) ChangePassword(ctx context.Context, r *ChangePasswordRequest) (*LoginResponse, error) {
+	u, err := h.userRepository.FindById(ctx, r.UserUUID)
 	if err != nil {
-		return &user.User{}, err
+		return &LoginResponse{}, err
+	}
+
+	if !user.CheckPasswordHash(r.Password, u.Hash) {
+		return &LoginResponse{}, appErr.NewIncorrectInputError("Invalid password", appErr.SlugInvalidCredentials)
+	}
+
+	if err = h.changePassword(ctx, u, r.NewPassword); err != nil {
+		return &LoginResponse{}, err
+	}
+
+	return h.createTokens(ctx, u, &tokenParams{
+		Ip:       r.Ip,
+		DeviceId: r.DeviceId,
+		Jkt:      r.Jkt,
+	})
+}
+
+func (h *AuthService) DeleteUser(ctx context.Context, r *DeleteUserRequest) error {
+	u, err := h.userRepository.FindById(ctx, r.UserUUID)
+	if err != nil {
+		return err
+	}
+
+	if !user.CheckPasswordHash(r.Password, u.Hash) {
+		return appErr.NewIncorrectInputError("Invalid password", appErr.SlugInvalidCredentials)
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.userRepository.Delete(ctx, u.UUID); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventUserDeleted, &u.UUID, map[string]interface{}{
+			"email": u.Email,
+		}); err != nil {
+			return err
+		}
+
+		return h.outbox.Add(ctx, event.New(event.UserDeleted, u.UUID, map[string]interface{}{
+			"email": u.Email,
+		}))
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserDeletingError)
 	}
 
-	return h.userRepository.UpdateSettings(ctx, request.UserUUID, &settings)
+	return nil
 }
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"net"
+)
+
+// SessionBinding decides whether a refresh token may be used from the
+// client presenting it.
+type SessionBinding string
+
+const (
+	// BindingNone accepts refresh tokens from anywhere.
+	BindingNone SessionBinding = "none"
+	// BindingIp requires the IP the session was started from.
+	BindingIp SessionBinding = "ip"
+	// BindingSubnet requires the same /24 IPv4 or /64 IPv6 network, it
+	// tolerates address changes within a provider network.
+	BindingSubnet SessionBinding = "subnet"
+	// BindingDevice requires the device id sent at sign in, it suits mobile
+	// clients that change networks all the time.
+	BindingDevice SessionBinding = "device"
+)
+
+const (
+	ipv4SubnetBits = 24
+	ipv6SubnetBits = 64
+)
+
+func ParseSessionBinding(s string) (SessionBinding, error) {
+	switch b := SessionBinding(s); b {
+	case BindingNone, BindingIp, BindingSubnet, BindingDevice:
+		return b, nil
+	case "":
+		return BindingNone, nil
+	default:
+		return BindingNone, fmt.Errorf("unknown session binding %q", s)
+	}
+}
+
+// Allows tells whether the session may be refreshed from ip and deviceId.
+// Sessions started without a device id are not bound to a device.
+func (b SessionBinding) Allows(s *Session, ip, deviceId string) bool {
+	switch b {
+	case BindingIp:
+		return normalizeIp(s.Ip) == normalizeIp(ip)
+	case BindingSubnet:
+		return sameSubnet(s.Ip, ip)
+	case BindingDevice:
+		return s.DeviceId == "" || s.DeviceId == deviceId
+	default:
+		return true
+	}
+}
+
+func sameSubnet(a, b string) bool {
+	ipA := net.ParseIP(normalizeIp(a))
+	ipB := net.ParseIP(normalizeIp(b))
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+
+	if v4 := ipA.To4(); v4 != nil {
+		if ipB.To4() == nil {
+			return false
+		}
+
+		mask := net.CIDRMask(ipv4SubnetBits, 32)
+		return v4.Mask(mask).Equal(ipB.To4().Mask(mask))
+	}
+
+	mask := net.CIDRMask(ipv6SubnetBits, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// normalizeIp drops the port from a remote address.
+func normalizeIp(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app/service"
+)
+
+func TestParseSessionBinding(t *testing.T) {
+	for s, want := range map[string]service.SessionBinding{
+		"":       service.BindingNone,
+		"none":   service.BindingNone,
+		"ip":     service.BindingIp,
+		"subnet": service.BindingSubnet,
+		"device": service.BindingDevice,
+	} {
+		if got, err := service.ParseSessionBinding(s); err != nil || got != want {
+			t.Errorf("ParseSessionBinding(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+
+	if _, err := service.ParseSessionBinding("asn"); err == nil {
+		t.Error("ParseSessionBinding(asn) accepted an unknown binding")
+	}
+}
+
+func TestSessionBindingAllows(t *testing.T) {
+	tests := []struct {
+		binding   service.SessionBinding
+		sessionIp string
+		deviceId  string
+		ip        string
+		device    string
+		allowed   bool
+	}{
+		{service.BindingNone, "192.0.2.1", "", "198.51.100.7", "", true},
+
+		{service.BindingIp, "192.0.2.1:1234", "", "192.0.2.1:5678", "", true},
+		{service.BindingIp, "192.0.2.1", "", "192.0.2.1:5678", "", true},
+		{service.BindingIp, "192.0.2.1:1234", "", "192.0.2.2:1234", "", false},
+		{service.BindingIp, "[2001:db8::1]:1234", "", "2001:db8::1", "", true},
+
+		{service.BindingSubnet, "192.0.2.1:1234", "", "192.0.2.254:1234", "", true},
+		{service.BindingSubnet, "192.0.2.1", "", "192.0.3.1", "", false},
+		{service.BindingSubnet, "2001:db8:0:1::1", "", "[2001:db8:0:1:ffff::2]:443", "", true},
+		{service.BindingSubnet, "2001:db8:0:1::1", "", "2001:db8:0:2::1", "", false},
+		{service.BindingSubnet, "192.0.2.1", "", "::ffff:192.0.2.9", "", true},
+		{service.BindingSubnet, "192.0.2.1", "", "2001:db8::1", "", false},
+		{service.BindingSubnet, "unix", "", "unix", "", true},
+		{service.BindingSubnet, "unix", "", "192.0.2.1", "", false},
+
+		{service.BindingDevice, "192.0.2.1", "phone", "198.51.100.7", "phone", true},
+		{service.BindingDevice, "192.0.2.1", "phone", "192.0.2.1", "laptop", false},
+		{service.BindingDevice, "192.0.2.1", "", "192.0.2.1", "laptop", true},
+	}
+
+	for _, test := range tests {
+		session := &service.Session{Ip: test.sessionIp, DeviceId: test.deviceId}
+		if got := test.binding.Allows(session, test.ip, test.device); got != test.allowed {
+			t.Errorf("%s binding of %s/%q from %s/%q = %v, want %v", test.binding, test.sessionIp, test.deviceId, test.ip, test.device, got, test.allowed)
+		}
+	}
+}
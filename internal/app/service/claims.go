@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+)
+
+// ClaimsEnricher contributes claims to an access token before it is signed.
+type ClaimsEnricher interface {
+	Enrich(ctx context.Context, u *user.User, claims *jwt.AccessClaims) error
+}
+
+type ClaimsEnricherFunc func(ctx context.Context, u *user.User, claims *jwt.AccessClaims) error
+
+func (f ClaimsEnricherFunc) Enrich(ctx context.Context, u *user.User, claims *jwt.AccessClaims) error {
+	return f(ctx, u, claims)
+}
+
+const (
+	ProfileClaimEmail         = "email"
+	ProfileClaimName          = "name"
+	ProfileClaimEmailVerified = "email_verified"
+	ProfileClaimCurrency      = "currency"
+	ProfileClaimLocale        = "locale"
+)
+
+type ProfileClaimsEnricher struct {
+	claims map[string]bool
+}
+
+func NewProfileClaimsEnricher(claims []string) (*ProfileClaimsEnricher, error) {
+	enabled := make(map[string]bool, len(claims))
+	for _, c := range claims {
+		switch c {
+		case ProfileClaimEmail, ProfileClaimName, ProfileClaimEmailVerified, ProfileClaimCurrency, ProfileClaimLocale:
+			enabled[c] = true
+		default:
+			return nil, fmt.Errorf("unknown profile claim %q", c)
+		}
+	}
+
+	return &ProfileClaimsEnricher{claims: enabled}, nil
+}
+
+func (h *ProfileClaimsEnricher) Enrich(ctx context.Context, u *user.User, claims *jwt.AccessClaims) error {
+	if h.claims[ProfileClaimEmail] {
+		claims.Email = u.Email
+	}
+
+	if h.claims[ProfileClaimName] {
+		claims.Name = u.Name
+	}
+
+	if h.claims[ProfileClaimEmailVerified] {
+		verified := u.IsEmailVerified()
+		claims.EmailVerified = &verified
+	}
+
+	if h.claims[ProfileClaimCurrency] {
+		claims.Currency = u.Settings.Currency.String()
+	}
+
+	if h.claims[ProfileClaimLocale] {
+		claims.Locale = string(u.Settings.Locale)
+	}
+
+	return nil
+}
+
+// RoleClaimsEnricher embeds user roles into the token and their permissions
+// as the token scope.
+type RoleClaimsEnricher struct {
+	roleRepository role.RoleRepository
+}
+
+func NewRoleClaimsEnricher(rr role.RoleRepository) *RoleClaimsEnricher {
+	return &RoleClaimsEnricher{roleRepository: rr}
+}
+
+func (h *RoleClaimsEnricher) Enrich(ctx context.Context, u *user.User, claims *jwt.AccessClaims) error {
+	roles, err := h.roleRepository.FindForUser(ctx, u.UUID)
+	if err != nil {
+		return err
+	}
+
+	claims.Roles = role.Names(roles)
+	claims.AddScopes(role.Permissions(roles)...)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/client"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+)
+
+type ClientService struct {
+	clientRepository client.ClientRepository
+	jwtService       *jwt.JWTService
+}
+
+type ClientCredentialsRequest struct {
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+}
+
+type CreateClientRequest struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+type CreatedClient struct {
+	Client *client.Client
+	Secret string
+}
+
+func NewClientService(cr client.ClientRepository, jwt *jwt.JWTService) *ClientService {
+	return &ClientService{
+		clientRepository: cr,
+		jwtService:       jwt,
+	}
+}
+
+// IssueToken implements the client credentials grant. Without requested
+// scopes the token gets every scope allowed to the client.
+func (h *ClientService) IssueToken(ctx context.Context, r *ClientCredentialsRequest) (*jwt.AccessJWT, error) {
+	c, err := h.clientRepository.FindById(ctx, r.ClientId)
+	if err != nil {
+		if appErr.IsNotFound(err) {
+			return &jwt.AccessJWT{}, appErr.NewAuthorizationError("Client not found", appErr.SlugInvalidClient)
+		}
+
+		return &jwt.AccessJWT{}, err
+	}
+
+	if c.IsDisabled() || !client.CheckSecretHash(r.ClientSecret, c.SecretHash) {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError("Invalid client credentials", appErr.SlugInvalidClient)
+	}
+
+	scopes := r.Scopes
+	if len(scopes) == 0 {
+		scopes = c.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !c.Allows(scope) {
+			return &jwt.AccessJWT{}, appErr.NewIncorrectInputError("Scope "+scope+" is not allowed", appErr.SlugInvalidScope)
+		}
+	}
+
+	access, err := h.jwtService.CreateAccess(*jwt.NewServiceClaims(c.ID, scopes))
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugCouldNotAuthorizeClient)
+	}
+
+	return access, nil
+}
+
+func (h *ClientService) CreateClient(ctx context.Context, r *CreateClientRequest) (*CreatedClient, error) {
+	if r.ID == "" {
+		return &CreatedClient{}, appErr.NewIncorrectInputError("Client id must be provided", appErr.SlugFieldClientIdRequired)
+	}
+
+	secret, err := client.GenerateSecret()
+	if err != nil {
+		return &CreatedClient{}, appErr.Wrap(err, appErr.SlugClientSavingError)
+	}
+
+	hash, err := client.HashSecret(secret)
+	if err != nil {
+		return &CreatedClient{}, appErr.Wrap(err, appErr.SlugClientSavingError)
+	}
+
+	c := &client.Client{
+		ID:         r.ID,
+		Name:       r.Name,
+		SecretHash: hash,
+		Scopes:     r.Scopes,
+		CreatedAt:  time.Now(),
+	}
+
+	err = h.clientRepository.Add(ctx, c)
+	if err != nil {
+		return &CreatedClient{}, appErr.Wrap(err, appErr.SlugClientSavingError)
+	}
+
+	return &CreatedClient{Client: c, Secret: secret}, nil
+}
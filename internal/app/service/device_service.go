@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/device"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/internal/app/mail"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ActionNotMe = "not_me"
+
+	notMeLinkTTL     = 72 * time.Hour
+	mailSendTimeout  = 30 * time.Second
+	NotMePath        = "/api/v1/devices/notMe"
+	maxUserAgentSize = 512
+)
+
+// DeviceService remembers the devices users sign in from and warns them by
+// email about sign ins from a new IP or user agent.
+type DeviceService struct {
+	deviceRepository  device.DeviceRepository
+	userRepository    user.UserRepository
+	refreshRepository RefreshJWTRepository
+	apiKeyRepository  apikey.APIKeyRepository
+	jwtService        *jwt.JWTService
+	mailer            mail.Mailer
+	txManager         TxManager
+	outbox            event.OutboxRepository
+	auditRepository   audit.AuditRepository
+	passwordResets    *PasswordResetService
+	logger            *logrus.Logger
+	publicURL         string
+	// pending counts notifications being sent, see Wait.
+	pending sync.WaitGroup
+}
+
+func NewDeviceService(
+	dr device.DeviceRepository,
+	ur user.UserRepository,
+	rfr RefreshJWTRepository,
+	akr apikey.APIKeyRepository,
+	jwt *jwt.JWTService,
+	mailer mail.Mailer,
+	tm TxManager,
+	or event.OutboxRepository,
+	ar audit.AuditRepository,
+	prs *PasswordResetService,
+	logger *logrus.Logger,
+	publicURL string,
+) *DeviceService {
+	return &DeviceService{
+		deviceRepository:  dr,
+		userRepository:    ur,
+		refreshRepository: rfr,
+		apiKeyRepository:  akr,
+		jwtService:        jwt,
+		mailer:            mailer,
+		txManager:         tm,
+		outbox:            or,
+		auditRepository:   ar,
+		passwordResets:    prs,
+		logger:            logger,
+		publicURL:         strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// SessionStarted compares the session device with the known ones. The first
+// device of an account is remembered silently, any later one with an IP or a
+// user agent never seen before triggers a notification.
+func (h *DeviceService) SessionStarted(ctx context.Context, u *user.User, s *StartedSession) {
+	ip := normalizeIp(s.Ip)
+	userAgent := s.UserAgent
+	if len(userAgent) > maxUserAgentSize {
+		userAgent = userAgent[:maxUserAgentSize]
+	}
+
+	logger := h.logger.WithField("user_uuid", u.UUID)
+
+	known, err := h.deviceRepository.FindForUser(ctx, u.UUID)
+	if err != nil {
+		logger.WithError(err).Error("Could not load known devices")
+		return
+	}
+
+	now := time.Now()
+	novelty := device.Compare(known, ip, userAgent)
+	if novelty.Known != nil {
+		if err = h.deviceRepository.Touch(ctx, novelty.Known.UUID, now); err != nil {
+			logger.WithError(err).Error("Could not update known device")
+		}
+		return
+	}
+
+	d := &device.Device{
+		UUID:        uuid.New(),
+		UserUUID:    u.UUID,
+		Ip:          ip,
+		UserAgent:   userAgent,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+	if err = h.deviceRepository.Add(ctx, d); err != nil {
+		logger.WithError(err).Error("Could not save known device")
+		return
+	}
+
+	// a known IP with a known user agent, seen on different devices, is
+	// remembered without a notification
+	if len(known) == 0 || s.SignUp || !novelty.IsNew() {
+		return
+	}
+
+	_ = recordAudit(ctx, h.auditRepository, audit.EventNewDevice, &u.UUID, map[string]interface{}{
+		"session_uuid":   s.UUID,
+		"device_uuid":    d.UUID,
+		"new_ip":         novelty.NewIp,
+		"new_user_agent": novelty.NewUserAgent,
+	})
+
+	claims := jwt.NewActionClaims(ActionNotMe, u.UUID, d.UUID)
+	claims.SessionId = s.UUID
+
+	token, err := h.jwtService.CreateAction(*claims, notMeLinkTTL)
+	if err != nil {
+		logger.WithError(err).Error("Could not create not me link")
+		return
+	}
+
+	// the saved language wins over the one of the device signing in
+	locale := i18n.Resolve(u.Settings.Locale, s.Locale)
+	message, err := newDeviceMessage(u, d, locale, h.publicURL+NotMePath+"?token="+url.QueryEscape(token))
+	if err != nil {
+		logger.WithError(err).Error("Could not render new device notification")
+		return
+	}
+
+	// the session is already issued, the email must not delay the response
+	h.pending.Add(1)
+	go func() {
+		defer h.pending.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := h.mailer.Send(ctx, message); err != nil {
+			logger.WithError(err).Error("Could not send new device notification")
+		}
+	}()
+}
+
+// Wait blocks until the notifications being sent are done, sending is
+// bounded by mailSendTimeout.
+func (h *DeviceService) Wait() {
+	h.pending.Wait()
+}
+
+// NotMe handles the "this wasn't me" link: it ends every session of the
+// user, revokes their API keys, forgets the device, requires a new password
+// and emails a password reset link. The old password can't complete the
+// reset, whoever signed in may know it. The device is forgotten, so the link
+// works once.
+func (h *DeviceService) NotMe(ctx context.Context, token string, locale i18n.Locale) error {
+	claims, err := h.jwtService.ValidateAction(token, ActionNotMe)
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	userUUID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	deviceUUID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	u, err := h.userRepository.FindById(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	known, err := h.deviceRepository.FindForUser(ctx, userUUID)
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	if !hasDevice(known, deviceUUID) {
+		return appErr.NewAuthorizationError("Device is already reported", appErr.SlugInvalidToken)
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.deviceRepository.Delete(ctx, deviceUUID); err != nil {
+			return err
+		}
+
+		if err := h.userRepository.SetPasswordResetRequired(ctx, userUUID, true); err != nil {
+			return err
+		}
+
+		if err := h.apiKeyRepository.RevokeForUser(ctx, userUUID, time.Now()); err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, h.auditRepository, audit.EventDeviceDenied, &userUUID, map[string]interface{}{
+			"session_uuid": claims.SessionId,
+			"device_uuid":  deviceUUID,
+		}); err != nil {
+			return err
+		}
+
+		// refreshing replaces the session uuid, so every session is ended
+		return revokeSessions(ctx, h.refreshRepository, h.outbox, h.auditRepository, userUUID, RevokeReasonNotMe)
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugUserSavingError)
+	}
+
+	h.passwordResets.SendLink(u, locale)
+
+	return nil
+}
+
+func hasDevice(devices []*device.Device, deviceUUID uuid.UUID) bool {
+	for _, d := range devices {
+		if d.UUID == deviceUUID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newDeviceMessage(u *user.User, d *device.Device, locale i18n.Locale, link string) (*mail.Message, error) {
+	subject, text, err := i18n.RenderEmail(locale, i18n.EmailNewDevice, &i18n.NewDeviceData{
+		Name:      u.Name,
+		Time:      d.FirstSeenAt.UTC().Format(time.RFC1123),
+		Ip:        d.Ip,
+		UserAgent: d.UserAgent,
+		Link:      link,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mail.Message{
+		To:      u.Email,
+		Subject: subject,
+		Text:    text,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/google/uuid"
+)
+
+// Session revocation reasons sent in session.revoked events.
+const (
+	RevokeReasonPasswordChanged  = "password_changed"
+	RevokeReasonSessionLimit     = "session_limit"
+	RevokeReasonForcedLogout     = "forced_logout"
+	RevokeReasonUserLocked       = "user_locked"
+	RevokeReasonPasswordReset    = "password_reset_required"
+	RevokeReasonNotMe            = "not_me"
+	RevokeReasonBindingViolation = "binding_violation"
+	RevokeReasonLogout           = "logout"
+	RevokeReasonTokenReused      = "token_reused"
+)
+
+// revokeSessions deletes every refresh token of the user and records a
+// session.revoked domain and audit event, call it inside a transaction.
+func revokeSessions(ctx context.Context, rfr RefreshJWTRepository, outbox event.OutboxRepository, ar audit.AuditRepository, userUUID uuid.UUID, reason string) error {
+	if err := rfr.DeleteForUserUUID(ctx, userUUID); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"reason": reason,
+	}
+
+	if err := recordAudit(ctx, ar, audit.EventSessionsRevoked, &userUUID, data); err != nil {
+		return err
+	}
+
+	return outbox.Add(ctx, event.New(event.SessionRevoked, userUUID, data))
+}
+
+// updateSettings stores new user settings together with a
+// user.settings_changed domain and audit event.
+func updateSettings(ctx context.Context, tm TxManager, ur user.UserRepository, outbox event.OutboxRepository, ar audit.AuditRepository, userUUID uuid.UUID, settings *user.Settings) (*user.User, error) {
+	var u *user.User
+	err := tm.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if u, err = ur.UpdateSettings(ctx, userUUID, settings); err != nil {
+			return err
+		}
+
+		data := map[string]interface{}{
+			"currency": settings.Currency.String(),
+		}
+
+		if settings.Locale != "" {
+			data["locale"] = string(settings.Locale)
+		}
+
+		if err = recordAudit(ctx, ar, audit.EventSettingsChanged, &userUUID, data); err != nil {
+			return err
+		}
+
+		return outbox.Add(ctx, event.New(event.UserSettingsChanged, userUUID, data))
+	})
+	if err != nil {
+		return &user.User{}, err
+	}
+
+	return u, nil
+}
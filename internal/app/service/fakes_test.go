@@ -0,0 +1,276 @@
+package service_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/google/uuid"
+)
+
+// txManager runs the function as is, the fakes are not isolated.
+type txManager struct{}
+
+func (txManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type auditLog struct {
+	mu     sync.Mutex
+	events []*audit.Event
+}
+
+func (s *auditLog) Add(ctx context.Context, e *audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *e
+	s.events = append(s.events, &copied)
+	return nil
+}
+
+func (s *auditLog) Find(ctx context.Context, f *audit.Filter) ([]*audit.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*audit.Event
+	for _, e := range s.events {
+		copied := *e
+		found = append(found, &copied)
+	}
+
+	return found, nil
+}
+
+// webhookStore keeps the webhook subscriptions and deliveries.
+type webhookStore struct {
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]*webhook.Subscription
+	deliveries    []*webhook.Delivery
+}
+
+func newWebhookStore() *webhookStore {
+	return &webhookStore{subscriptions: map[uuid.UUID]*webhook.Subscription{}}
+}
+
+type subscriptions webhookStore
+
+// Get returns the subscription as saved.
+func (s *subscriptions) Get(id uuid.UUID) *webhook.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil
+	}
+
+	copied := *sub
+	return &copied
+}
+
+func (s *subscriptions) Add(ctx context.Context, sub *webhook.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *sub
+	s.subscriptions[sub.UUID] = &copied
+	return nil
+}
+
+func (s *subscriptions) FindById(ctx context.Context, id uuid.UUID) (*webhook.Subscription, error) {
+	if sub := s.Get(id); sub != nil {
+		return sub, nil
+	}
+
+	return &webhook.Subscription{}, appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+}
+
+func (s *subscriptions) FindAll(ctx context.Context) ([]*webhook.Subscription, error) {
+	return s.filter(func(sub *webhook.Subscription) bool { return true }), nil
+}
+
+func (s *subscriptions) FindActiveForEvent(ctx context.Context, eventType string) ([]*webhook.Subscription, error) {
+	return s.filter(func(sub *webhook.Subscription) bool {
+		return !sub.IsDisabled() && sub.Matches(eventType)
+	}), nil
+}
+
+func (s *subscriptions) filter(match func(sub *webhook.Subscription) bool) []*webhook.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*webhook.Subscription
+	for _, sub := range s.subscriptions {
+		if match(sub) {
+			copied := *sub
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.Before(found[j].CreatedAt) })
+	return found
+}
+
+func (s *subscriptions) RecordFailure(ctx context.Context, id uuid.UUID, disableAfter int, at time.Time) error {
+	return s.update(id, func(sub *webhook.Subscription) {
+		sub.FailureCount++
+		if sub.DisabledAt == nil && sub.FailureCount >= disableAfter {
+			sub.DisabledAt = &at
+		}
+		sub.UpdatedAt = at
+	})
+}
+
+func (s *subscriptions) ResetFailures(ctx context.Context, id uuid.UUID) error {
+	return s.update(id, func(sub *webhook.Subscription) { sub.FailureCount = 0 })
+}
+
+func (s *subscriptions) Enable(ctx context.Context, id uuid.UUID) error {
+	return s.update(id, func(sub *webhook.Subscription) {
+		sub.DisabledAt = nil
+		sub.FailureCount = 0
+	})
+}
+
+func (s *subscriptions) Delete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+	}
+
+	delete(s.subscriptions, id)
+	kept := s.deliveries[:0]
+	for _, d := range s.deliveries {
+		if d.SubscriptionUUID != id {
+			kept = append(kept, d)
+		}
+	}
+	s.deliveries = kept
+	return nil
+}
+
+func (s *subscriptions) update(id uuid.UUID, change func(sub *webhook.Subscription)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+	}
+
+	change(sub)
+	return nil
+}
+
+type deliveries webhookStore
+
+// All returns every delivery in the order they were added.
+func (s *deliveries) All() []*webhook.Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]*webhook.Delivery, 0, len(s.deliveries))
+	for _, d := range s.deliveries {
+		copied := *d
+		all = append(all, &copied)
+	}
+
+	return all
+}
+
+// MakeDue moves the next attempt of every delivery to now, as if the
+// backoff or the claim passed.
+func (s *deliveries) MakeDue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.deliveries {
+		d.NextAttemptAt = time.Now()
+	}
+}
+
+func (s *deliveries) Add(ctx context.Context, d *webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.deliveries {
+		if d.ReplayOf == nil && existing.ReplayOf == nil && existing.SubscriptionUUID == d.SubscriptionUUID && existing.EventUUID == d.EventUUID {
+			return nil
+		}
+	}
+
+	copied := *d
+	s.deliveries = append(s.deliveries, &copied)
+	return nil
+}
+
+func (s *deliveries) FindById(ctx context.Context, id uuid.UUID) (*webhook.Delivery, error) {
+	for _, d := range s.All() {
+		if d.UUID == id {
+			return d, nil
+		}
+	}
+
+	return &webhook.Delivery{}, appErr.NewNotFoundError("Webhook delivery not found", appErr.SlugWebhookDeliveryNotFound)
+}
+
+func (s *deliveries) Find(ctx context.Context, f *webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	var found []*webhook.Delivery
+	for _, d := range s.All() {
+		if d.SubscriptionUUID == f.SubscriptionUUID && (f.Status == "" || d.Status == f.Status) {
+			found = append(found, d)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].CreatedAt.After(found[j].CreatedAt) })
+	if f.Limit > 0 && len(found) > f.Limit {
+		found = found[:f.Limit]
+	}
+
+	return found, nil
+}
+
+func (s *deliveries) Claim(ctx context.Context, n int, until time.Time) ([]*webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var claimed []*webhook.Delivery
+	for _, d := range s.deliveries {
+		if len(claimed) == n {
+			break
+		}
+
+		sub, ok := s.subscriptions[d.SubscriptionUUID]
+		if !ok || sub.IsDisabled() || d.Status != webhook.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+
+		d.NextAttemptAt = until
+		copied := *d
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *deliveries) Update(ctx context.Context, d *webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.deliveries {
+		if existing.UUID == d.UUID {
+			copied := *d
+			s.deliveries[i] = &copied
+		}
+	}
+
+	return nil
+}
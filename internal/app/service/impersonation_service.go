@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+const DefaultImpersonationTTL = 10 * time.Minute
+
+type Impersonation struct {
+	UUID      uuid.UUID
+	AdminUUID uuid.UUID
+	UserUUID  uuid.UUID
+	Reason    string
+	StartedAt time.Time
+	ExpiresAt time.Time
+	EndedAt   *time.Time
+}
+
+func (i *Impersonation) IsActive(now time.Time) bool {
+	return i.EndedAt == nil && now.Before(i.ExpiresAt)
+}
+
+type ImpersonationRepository interface {
+	Add(ctx context.Context, impersonation *Impersonation) error
+	FindById(ctx context.Context, uuid uuid.UUID) (*Impersonation, error)
+	End(ctx context.Context, uuid uuid.UUID, endedAt time.Time) error
+}
+
+type ImpersonateRequest struct {
+	AdminUUID uuid.UUID
+	UserUUID  uuid.UUID
+	Reason    string
+}
+
+// ImpersonationService lets support staff act as a customer. Impersonation
+// tokens are short-lived access tokens with an "act" claim naming the admin;
+// no refresh token is issued for them.
+type ImpersonationService struct {
+	authService             *AuthService
+	jwtService              *jwt.JWTService
+	userRepository          user.UserRepository
+	roleRepository          role.RoleRepository
+	impersonationRepository ImpersonationRepository
+	txManager               TxManager
+	auditRepository         audit.AuditRepository
+	ttl                     time.Duration
+}
+
+func NewImpersonationService(
+	as *AuthService,
+	jwt *jwt.JWTService,
+	ur user.UserRepository,
+	rr role.RoleRepository,
+	ir ImpersonationRepository,
+	tm TxManager,
+	ar audit.AuditRepository,
+	ttl time.Duration,
+) *ImpersonationService {
+	if ttl <= 0 {
+		ttl = DefaultImpersonationTTL
+	}
+
+	return &ImpersonationService{
+		authService:             as,
+		jwtService:              jwt,
+		userRepository:          ur,
+		roleRepository:          rr,
+		impersonationRepository: ir,
+		txManager:               tm,
+		auditRepository:         ar,
+		ttl:                     ttl,
+	}
+}
+
+func (h *ImpersonationService) Start(ctx context.Context, r *ImpersonateRequest) (*jwt.AccessJWT, error) {
+	if r.AdminUUID == r.UserUUID {
+		return &jwt.AccessJWT{}, appErr.NewIncorrectInputError("Can not impersonate yourself", appErr.SlugImpersonationNotAllowed)
+	}
+
+	target, err := h.userRepository.FindById(ctx, r.UserUUID)
+	if err != nil {
+		return &jwt.AccessJWT{}, err
+	}
+
+	if target.IsLocked() {
+		return &jwt.AccessJWT{}, appErr.NewIncorrectInputError("User is locked", appErr.SlugUserLocked)
+	}
+
+	roles, err := h.roleRepository.FindForUser(ctx, target.UUID)
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.Wrap(err, appErr.SlugImpersonationError)
+	}
+
+	for _, rl := range roles {
+		if rl.HasPermission(role.PermissionAdmin) {
+			return &jwt.AccessJWT{}, appErr.NewIncorrectInputError("Admins can not be impersonated", appErr.SlugImpersonationNotAllowed)
+		}
+	}
+
+	claims, err := h.authService.AccessClaimsFor(ctx, target)
+	if err != nil {
+		return &jwt.AccessJWT{}, err
+	}
+
+	now := time.Now()
+	impersonation := &Impersonation{
+		UUID:      uuid.New(),
+		AdminUUID: r.AdminUUID,
+		UserUUID:  target.UUID,
+		Reason:    r.Reason,
+		StartedAt: now,
+		ExpiresAt: now.Add(h.ttl),
+	}
+
+	claims.ID = impersonation.UUID.String()
+	claims.Act = &jwt.ActorClaim{Subject: r.AdminUUID.String()}
+
+	access, err := h.jwtService.CreateAccessWithTTL(*claims, h.ttl)
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.Wrap(err, appErr.SlugImpersonationError)
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.impersonationRepository.Add(ctx, impersonation); err != nil {
+			return err
+		}
+
+		return h.auditRepository.Add(ctx, audit.NewEvent(
+			audit.EventImpersonationStarted,
+			&impersonation.AdminUUID,
+			&impersonation.UserUUID,
+			map[string]interface{}{
+				"impersonation_uuid": impersonation.UUID,
+				"reason":             impersonation.Reason,
+				"expires_at":         impersonation.ExpiresAt,
+			},
+		).WithMeta(audit.MetaFrom(ctx)))
+	})
+	if err != nil {
+		return &jwt.AccessJWT{}, appErr.Wrap(err, appErr.SlugImpersonationError)
+	}
+
+	return access, nil
+}
+
+// Stop ends the impersonation the access token was issued for.
+func (h *ImpersonationService) Stop(ctx context.Context, claims *jwt.AccessClaims) error {
+	impersonation, err := h.activeImpersonation(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.impersonationRepository.End(ctx, impersonation.UUID, time.Now()); err != nil {
+			return err
+		}
+
+		return h.auditRepository.Add(ctx, audit.NewEvent(
+			audit.EventImpersonationStopped,
+			&impersonation.AdminUUID,
+			&impersonation.UserUUID,
+			map[string]interface{}{
+				"impersonation_uuid": impersonation.UUID,
+			},
+		).WithMeta(audit.MetaFrom(ctx)))
+	})
+	if err != nil {
+		return appErr.Wrap(err, appErr.SlugImpersonationError)
+	}
+
+	return nil
+}
+
+// Validate checks that an impersonation token has not been stopped.
+func (h *ImpersonationService) Validate(ctx context.Context, claims *jwt.AccessClaims) error {
+	_, err := h.activeImpersonation(ctx, claims)
+	return err
+}
+
+func (h *ImpersonationService) activeImpersonation(ctx context.Context, claims *jwt.AccessClaims) (*Impersonation, error) {
+	if !claims.IsImpersonated() {
+		return &Impersonation{}, appErr.NewIncorrectInputError("Token is not an impersonation token", appErr.SlugImpersonationNotActive)
+	}
+
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return &Impersonation{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	impersonation, err := h.impersonationRepository.FindById(ctx, id)
+	if err != nil {
+		return &Impersonation{}, appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	if impersonation.UserUUID != claims.UserId || !impersonation.IsActive(time.Now()) {
+		return &Impersonation{}, appErr.NewAuthorizationError("Impersonation is not active", appErr.SlugImpersonationNotActive)
+	}
+
+	return impersonation, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/google/uuid"
+)
+
+const DefaultMaxLookupSize = 100
+
+// LookupService resolves many users at once for other wallet services.
+type LookupService struct {
+	userRepository user.UserRepository
+	maxLookupSize  int
+}
+
+type LookupRequest struct {
+	UUIDs  []uuid.UUID
+	Emails []string
+}
+
+type LookupResult struct {
+	ByUUID        map[uuid.UUID]*user.User
+	ByEmail       map[string]*user.User
+	MissingUUIDs  []uuid.UUID
+	MissingEmails []string
+}
+
+func NewLookupService(ur user.UserRepository, maxLookupSize int) *LookupService {
+	if maxLookupSize <= 0 {
+		maxLookupSize = DefaultMaxLookupSize
+	}
+
+	return &LookupService{
+		userRepository: ur,
+		maxLookupSize:  maxLookupSize,
+	}
+}
+
+// Lookup finds the users by uuid and email, repeated ones are looked up and
+// counted against the limit once.
+func (h *LookupService) Lookup(ctx context.Context, r *LookupRequest) (*LookupResult, error) {
+	r = &LookupRequest{UUIDs: uniqueUUIDs(r.UUIDs), Emails: uniqueStrings(r.Emails)}
+	if len(r.UUIDs)+len(r.Emails) > h.maxLookupSize {
+		return &LookupResult{}, appErr.NewIncorrectInputError(
+			fmt.Sprintf("At most %d users can be looked up at once", h.maxLookupSize),
+			appErr.SlugLookupTooManyUsers,
+		)
+	}
+
+	result := &LookupResult{
+		ByUUID:        map[uuid.UUID]*user.User{},
+		ByEmail:       map[string]*user.User{},
+		MissingUUIDs:  []uuid.UUID{},
+		MissingEmails: []string{},
+	}
+
+	if len(r.UUIDs) > 0 {
+		users, err := h.userRepository.FindByIds(ctx, r.UUIDs)
+		if err != nil {
+			return &LookupResult{}, appErr.Wrap(err, appErr.SlugLookupError)
+		}
+
+		for _, u := range users {
+			result.ByUUID[u.UUID] = u
+		}
+
+		for _, id := range r.UUIDs {
+			if _, ok := result.ByUUID[id]; !ok {
+				result.MissingUUIDs = append(result.MissingUUIDs, id)
+			}
+		}
+	}
+
+	if len(r.Emails) > 0 {
+		users, err := h.userRepository.FindByEmails(ctx, r.Emails)
+		if err != nil {
+			return &LookupResult{}, appErr.Wrap(err, appErr.SlugLookupError)
+		}
+
+		for _, u := range users {
+			result.ByEmail[u.Email] = u
+		}
+
+		for _, email := range r.Emails {
+			if _, ok := result.ByEmail[email]; !ok {
+				result.MissingEmails = append(result.MissingEmails, email)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultOutboxRelayInterval = 5 * time.Second
+	DefaultOutboxBatchSize     = 100
+	maxOutboxRetryDelay        = time.Hour
+)
+
+// OutboxRelay publishes outbox events. An event is marked as published only
+// after the publisher accepted it, so delivery is at least once. An event
+// claimed by a relay that stopped is published again once the claim expires.
+type OutboxRelay struct {
+	outbox    event.OutboxRepository
+	publisher event.Publisher
+	logger    *logrus.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+func NewOutboxRelay(or event.OutboxRepository, p event.Publisher, logger *logrus.Logger, interval time.Duration, batchSize int) *OutboxRelay {
+	if interval <= 0 {
+		interval = DefaultOutboxRelayInterval
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultOutboxBatchSize
+	}
+
+	return &OutboxRelay{
+		outbox:    or,
+		publisher: p,
+		logger:    logger,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run relays events until ctx is cancelled.
+func (h *OutboxRelay) Run(ctx context.Context) {
+	runBatches(ctx, "Outbox relay", h.logger, h.interval, h.batchSize, h.RelayBatch)
+}
+
+// RelayBatch publishes one batch of due events and returns its size. The
+// events are claimed first, so no row is locked while the publisher calls
+// the network, and several relays may run concurrently.
+func (h *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	until := time.Now().Add(claimLease)
+	events, err := h.outbox.ClaimPending(ctx, h.batchSize, until)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		if time.Until(until) < claimMargin {
+			h.logger.Warnf("Outbox relay batch is out of time, the rest is retried when the claim expires")
+			break
+		}
+
+		if err := h.publisher.Publish(ctx, e); err != nil {
+			if ctx.Err() != nil {
+				return len(events), ctx.Err()
+			}
+
+			h.logger.Warnf("Event %s %s publishing failed, attempt %d: %v", e.Type, e.UUID, e.Attempts+1, err)
+
+			if err := h.outbox.MarkFailed(ctx, e.UUID, err.Error(), time.Now().Add(backoff(time.Second, maxOutboxRetryDelay, e.Attempts))); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+
+		if err := h.outbox.MarkPublished(ctx, e.UUID); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/internal/app/mail"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ActionPasswordReset = "password_reset"
+
+	passwordResetLinkTTL = 24 * time.Hour
+	PasswordResetPath    = "/api/v1/password/reset"
+)
+
+// PasswordResetService completes forced password resets out of band: the
+// user gets an emailed link and sets the new password with it. A link is
+// bound to the password hash it was issued for, so it stops working once
+// the password is changed.
+type PasswordResetService struct {
+	userRepository user.UserRepository
+	authService    *AuthService
+	jwtService     *jwt.JWTService
+	mailer         mail.Mailer
+	logger         *logrus.Logger
+	publicURL      string
+	// pending counts emails being sent, see Wait.
+	pending sync.WaitGroup
+}
+
+func NewPasswordResetService(
+	ur user.UserRepository,
+	as *AuthService,
+	jwt *jwt.JWTService,
+	mailer mail.Mailer,
+	logger *logrus.Logger,
+	publicURL string,
+) *PasswordResetService {
+	return &PasswordResetService{
+		userRepository: ur,
+		authService:    as,
+		jwtService:     jwt,
+		mailer:         mailer,
+		logger:         logger,
+		publicURL:      strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// SendLink emails a password reset link to the user in the background. The
+// saved language wins over locale.
+func (h *PasswordResetService) SendLink(u *user.User, locale i18n.Locale) {
+	logger := h.logger.WithField("user_uuid", u.UUID)
+
+	claims := jwt.NewActionClaims(ActionPasswordReset, u.UUID, uuid.New())
+	claims.Fingerprint = passwordFingerprint(u.Hash)
+
+	token, err := h.jwtService.CreateAction(*claims, passwordResetLinkTTL)
+	if err != nil {
+		logger.WithError(err).Error("Could not create password reset link")
+		return
+	}
+
+	subject, text, err := i18n.RenderEmail(i18n.Resolve(u.Settings.Locale, locale), i18n.EmailPasswordReset, &i18n.PasswordResetData{
+		Name: u.Name,
+		Link: h.publicURL + PasswordResetPath + "?token=" + url.QueryEscape(token),
+	})
+	if err != nil {
+		logger.WithError(err).Error("Could not render password reset email")
+		return
+	}
+
+	message := &mail.Message{To: u.Email, Subject: subject, Text: text}
+
+	h.pending.Add(1)
+	go func() {
+		defer h.pending.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := h.mailer.Send(ctx, message); err != nil {
+			logger.WithError(err).Error("Could not send password reset email")
+		}
+	}()
+}
+
+// Wait blocks until the emails being sent are done.
+func (h *PasswordResetService) Wait() {
+	h.pending.Wait()
+}
+
+// Reset sets the new password with a reset link token and ends every
+// session of the user.
+func (h *PasswordResetService) Reset(ctx context.Context, token, password string) error {
+	claims, err := h.jwtService.ValidateAction(token, ActionPasswordReset)
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	userUUID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return appErr.NewAuthorizationError(err.Error(), appErr.SlugInvalidToken)
+	}
+
+	u, err := h.userRepository.FindById(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	// the password was changed since the link was sent, e.g. with this link
+	if subtle.ConstantTimeCompare([]byte(claims.Fingerprint), []byte(passwordFingerprint(u.Hash))) != 1 {
+		return appErr.NewAuthorizationError("Password reset link is used", appErr.SlugInvalidToken)
+	}
+
+	// changePassword records the change and revokes the sessions
+	return h.authService.changePassword(ctx, u, password)
+}
+
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
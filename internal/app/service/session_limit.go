@@ -0,0 +1,28 @@
+package service
+
+import "fmt"
+
+// SessionLimitPolicy decides what happens to a sign in when the user already
+// has the maximum number of sessions.
+type SessionLimitPolicy string
+
+const (
+	// SessionLimitEvictLRU ends the least recently used sessions.
+	SessionLimitEvictLRU SessionLimitPolicy = "evict_lru"
+	// SessionLimitReject refuses the new sign in.
+	SessionLimitReject SessionLimitPolicy = "reject"
+	// SessionLimitConfirm refuses the new sign in until the client confirms
+	// that the least recently used sessions may be ended.
+	SessionLimitConfirm SessionLimitPolicy = "confirm"
+)
+
+func ParseSessionLimitPolicy(s string) (SessionLimitPolicy, error) {
+	switch p := SessionLimitPolicy(s); p {
+	case SessionLimitEvictLRU, SessionLimitReject, SessionLimitConfirm:
+		return p, nil
+	case "":
+		return SessionLimitEvictLRU, nil
+	default:
+		return SessionLimitEvictLRU, fmt.Errorf("unknown session limit policy %q", s)
+	}
+}
@@ -0,0 +1,9 @@
+package service
+
+import "context"
+
+// TxManager runs fn in a database transaction, repositories called with the
+// context passed to fn take part in it.
+type TxManager interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultWebhookMaxAttempts  = 8
+	DefaultWebhookDisableAfter = 20
+	DefaultDeliveriesPageSize  = 50
+	MaxDeliveriesPageSize      = 200
+	webhookRetryBaseDelay      = 30 * time.Second
+	webhookRetryMaxDelay       = 6 * time.Hour
+	maxWebhookErrorLength      = 500
+)
+
+// WebhookService manages partner webhook subscriptions. It is an
+// event.Publisher: the outbox relay hands it every event and it records a
+// pending delivery per matching subscription, deliveries are then sent by Run.
+type WebhookService struct {
+	txManager    TxManager
+	subs         webhook.SubscriptionRepository
+	deliveries   webhook.DeliveryRepository
+	sender       webhook.Sender
+	audit        audit.AuditRepository
+	logger       *logrus.Logger
+	maxAttempts  int
+	disableAfter int
+	interval     time.Duration
+	batchSize    int
+}
+
+type WebhookConfig struct {
+	// MaxAttempts is the number of attempts before a delivery is failed.
+	MaxAttempts int
+	// DisableAfter is the number of consecutive failed attempts after which
+	// the subscription is disabled.
+	DisableAfter int
+	Interval     time.Duration
+	BatchSize    int
+}
+
+type CreateWebhookRequest struct {
+	URL        string
+	EventTypes []string
+}
+
+func NewWebhookService(
+	tm TxManager,
+	sr webhook.SubscriptionRepository,
+	dr webhook.DeliveryRepository,
+	sender webhook.Sender,
+	ar audit.AuditRepository,
+	logger *logrus.Logger,
+	config WebhookConfig,
+) *WebhookService {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+
+	if config.DisableAfter <= 0 {
+		config.DisableAfter = DefaultWebhookDisableAfter
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = DefaultOutboxRelayInterval
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOutboxBatchSize
+	}
+
+	return &WebhookService{
+		txManager:    tm,
+		subs:         sr,
+		deliveries:   dr,
+		sender:       sender,
+		audit:        ar,
+		logger:       logger,
+		maxAttempts:  config.MaxAttempts,
+		disableAfter: config.DisableAfter,
+		interval:     config.Interval,
+		batchSize:    config.BatchSize,
+	}
+}
+
+// Publish records a delivery of the event for every active subscription to
+// its type. Repeated publishing of the same event is ignored.
+func (h *WebhookService) Publish(ctx context.Context, e *event.Event) error {
+	subs, err := h.subs.FindActiveForEvent(ctx, string(e.Type))
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(event.NewMessage(e))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, sub := range subs {
+		err = h.deliveries.Add(ctx, &webhook.Delivery{
+			UUID:             uuid.New(),
+			SubscriptionUUID: sub.UUID,
+			EventUUID:        e.UUID,
+			EventType:        string(e.Type),
+			Body:             string(body),
+			Status:           webhook.DeliveryPending,
+			NextAttemptAt:    now,
+			CreatedAt:        now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *WebhookService) Create(ctx context.Context, r *CreateWebhookRequest) (*webhook.Subscription, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return &webhook.Subscription{}, appErr.NewIncorrectInputError("Invalid webhook URL", appErr.SlugFieldURLInvalid)
+	}
+
+	if len(r.EventTypes) == 0 {
+		return &webhook.Subscription{}, appErr.NewIncorrectInputError("Event types required", appErr.SlugFieldEventTypesRequired)
+	}
+
+	for _, t := range r.EventTypes {
+		if !event.IsKnownType(t) {
+			return &webhook.Subscription{}, appErr.NewIncorrectInputError(fmt.Sprintf("Unknown event type %s", t), appErr.SlugFieldEventTypesInvalid)
+		}
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return &webhook.Subscription{}, appErr.Wrap(err, appErr.SlugWebhookSavingError)
+	}
+
+	now := time.Now()
+	sub := &webhook.Subscription{
+		UUID:       uuid.New(),
+		URL:        r.URL,
+		Secret:     secret,
+		EventTypes: r.EventTypes,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.subs.Add(ctx, sub); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, h.audit, audit.EventWebhookCreated, nil, map[string]interface{}{
+			"webhook_uuid": sub.UUID,
+			"url":          sub.URL,
+			"event_types":  sub.EventTypes,
+		})
+	})
+	if err != nil {
+		return &webhook.Subscription{}, appErr.Wrap(err, appErr.SlugWebhookSavingError)
+	}
+
+	return sub, nil
+}
+
+func (h *WebhookService) List(ctx context.Context) ([]*webhook.Subscription, error) {
+	subs, err := h.subs.FindAll(ctx)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.SlugWebhookLoadingError)
+	}
+
+	return subs, nil
+}
+
+func (h *WebhookService) Get(ctx context.Context, subUUID uuid.UUID) (*webhook.Subscription, error) {
+	return h.subs.FindById(ctx, subUUID)
+}
+
+func (h *WebhookService) Delete(ctx context.Context, subUUID uuid.UUID) error {
+	return h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.subs.Delete(ctx, subUUID); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, h.audit, audit.EventWebhookDeleted, nil, map[string]interface{}{
+			"webhook_uuid": subUUID,
+		})
+	})
+}
+
+// Enable re-enables a disabled subscription and resets its failure counter,
+// the deliveries left pending when it was disabled are sent again.
+func (h *WebhookService) Enable(ctx context.Context, subUUID uuid.UUID) error {
+	return h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.subs.Enable(ctx, subUUID); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, h.audit, audit.EventWebhookEnabled, nil, map[string]interface{}{
+			"webhook_uuid": subUUID,
+		})
+	})
+}
+
+func (h *WebhookService) ListDeliveries(ctx context.Context, filter *webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	if _, err := h.subs.FindById(ctx, filter.SubscriptionUUID); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultDeliveriesPageSize
+	}
+
+	if filter.Limit > MaxDeliveriesPageSize {
+		filter.Limit = MaxDeliveriesPageSize
+	}
+
+	deliveries, err := h.deliveries.Find(ctx, filter)
+	if err != nil {
+		return nil, appErr.Wrap(err, appErr.SlugWebhookLoadingError)
+	}
+
+	return deliveries, nil
+}
+
+// Replay schedules a new delivery with the body of an earlier one, the
+// original stays in the log untouched.
+func (h *WebhookService) Replay(ctx context.Context, deliveryUUID uuid.UUID) (*webhook.Delivery, error) {
+	original, err := h.deliveries.FindById(ctx, deliveryUUID)
+	if err != nil {
+		return &webhook.Delivery{}, err
+	}
+
+	now := time.Now()
+	replay := &webhook.Delivery{
+		UUID:             uuid.New(),
+		SubscriptionUUID: original.SubscriptionUUID,
+		EventUUID:        original.EventUUID,
+		EventType:        original.EventType,
+		Body:             original.Body,
+		Status:           webhook.DeliveryPending,
+		ReplayOf:         &original.UUID,
+		NextAttemptAt:    now,
+		CreatedAt:        now,
+	}
+
+	err = h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.deliveries.Add(ctx, replay); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, h.audit, audit.EventWebhookReplayed, nil, map[string]interface{}{
+			"webhook_uuid":  replay.SubscriptionUUID,
+			"delivery_uuid": original.UUID,
+			"replay_uuid":   replay.UUID,
+		})
+	})
+	if err != nil {
+		return &webhook.Delivery{}, appErr.Wrap(err, appErr.SlugWebhookSavingError)
+	}
+
+	return replay, nil
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (h *WebhookService) Run(ctx context.Context) {
+	runBatches(ctx, "Webhook delivery", h.logger, h.interval, h.batchSize, h.DeliverBatch)
+}
+
+// DeliverBatch sends one batch of due deliveries and returns its size. The
+// deliveries are claimed first, so no row is locked while a receiver is
+// called, and every result is recorded in its own transaction.
+func (h *WebhookService) DeliverBatch(ctx context.Context) (int, error) {
+	until := time.Now().Add(claimLease)
+	deliveries, err := h.deliveries.Claim(ctx, h.batchSize, until)
+	if err != nil {
+		return 0, err
+	}
+
+	subs := map[uuid.UUID]*webhook.Subscription{}
+	for _, d := range deliveries {
+		if time.Until(until) < claimMargin {
+			h.logger.Warnf("Webhook delivery batch is out of time, the rest is retried when the claim expires")
+			break
+		}
+
+		sub, ok := subs[d.SubscriptionUUID]
+		if !ok {
+			if sub, err = h.subs.FindById(ctx, d.SubscriptionUUID); err != nil {
+				return len(deliveries), err
+			}
+			subs[d.SubscriptionUUID] = sub
+		}
+
+		// an earlier failure in this batch may have disabled it
+		if sub.IsDisabled() {
+			continue
+		}
+
+		if err = h.deliver(ctx, sub, d); err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (h *WebhookService) deliver(ctx context.Context, sub *webhook.Subscription, d *webhook.Delivery) error {
+	now := time.Now()
+	body := []byte(d.Body)
+
+	code, sendErr := h.sender.Send(ctx, &webhook.Request{
+		URL: sub.URL,
+		Headers: map[string]string{
+			webhook.HeaderId:        d.UUID.String(),
+			webhook.HeaderEvent:     d.EventType,
+			webhook.HeaderTimestamp: strconv.FormatInt(now.Unix(), 10),
+			webhook.HeaderSignature: webhook.Sign(sub.Secret, now, body),
+		},
+		Body: body,
+	})
+
+	// shutting down is not the receiver's failure, the claim expires and the
+	// delivery is sent again
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	d.Attempts++
+	d.ResponseCode = nil
+	if code != 0 {
+		d.ResponseCode = &code
+	}
+
+	if sendErr == nil && code >= 200 && code < 300 {
+		d.Status = webhook.DeliverySucceeded
+		d.DeliveredAt = &now
+		d.LastError = nil
+
+		return h.txManager.InTx(ctx, func(ctx context.Context) error {
+			if err := h.deliveries.Update(ctx, d); err != nil {
+				return err
+			}
+
+			if sub.FailureCount > 0 {
+				if err := h.subs.ResetFailures(ctx, sub.UUID); err != nil {
+					return err
+				}
+				sub.FailureCount = 0
+			}
+
+			return nil
+		})
+	}
+
+	reason := fmt.Sprintf("receiver responded with status %d", code)
+	if sendErr != nil {
+		reason = sendErr.Error()
+	}
+	if len(reason) > maxWebhookErrorLength {
+		reason = reason[:maxWebhookErrorLength]
+	}
+	d.LastError = &reason
+
+	if d.Attempts >= h.maxAttempts {
+		d.Status = webhook.DeliveryFailed
+	} else {
+		d.NextAttemptAt = now.Add(backoff(webhookRetryBaseDelay, webhookRetryMaxDelay, d.Attempts-1))
+	}
+
+	h.logger.Warnf("Webhook %s delivery %s attempt %d failed: %s", sub.UUID, d.UUID, d.Attempts, reason)
+
+	err := h.txManager.InTx(ctx, func(ctx context.Context) error {
+		if err := h.deliveries.Update(ctx, d); err != nil {
+			return err
+		}
+
+		return h.subs.RecordFailure(ctx, sub.UUID, h.disableAfter, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	sub.FailureCount++
+	if sub.FailureCount >= h.disableAfter {
+		sub.DisabledAt = &now
+		h.logger.Warnf("Webhook %s is disabled after %d consecutive failures", sub.UUID, sub.FailureCount)
+	}
+
+	return nil
+}
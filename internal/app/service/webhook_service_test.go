@@ -0,0 +1,377 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/adapters"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// receiver is a partner endpoint answering with the queued statuses, 200
+// once they run out.
+type receiver struct {
+	t        *testing.T
+	server   *httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*receivedRequest
+}
+
+type receivedRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{t: t, statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.requests = append(r.requests, &receivedRequest{Header: req.Header.Clone(), Body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.server.Close)
+
+	return r
+}
+
+func (r *receiver) received() []*receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*receivedRequest(nil), r.requests...)
+}
+
+type webhookFixture struct {
+	service    *service.WebhookService
+	subs       *subscriptions
+	deliveries *deliveries
+}
+
+func newWebhookFixture(config service.WebhookConfig) *webhookFixture {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	store := newWebhookStore()
+	subs, deliveries := (*subscriptions)(store), (*deliveries)(store)
+
+	return &webhookFixture{
+		service:    service.NewWebhookService(txManager{}, subs, deliveries, adapters.NewHttpWebhookSender(), &auditLog{}, logger, config),
+		subs:       subs,
+		deliveries: deliveries,
+	}
+}
+
+func (f *webhookFixture) subscribe(t *testing.T, url string) *webhook.Subscription {
+	sub, err := f.service.Create(context.Background(), &service.CreateWebhookRequest{
+		URL:        url,
+		EventTypes: []string{string(event.UserRegistered)},
+	})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+
+	return sub
+}
+
+func (f *webhookFixture) publish(t *testing.T) *event.Event {
+	e := event.New(event.UserRegistered, uuid.New(), map[string]interface{}{"email": "user@example.com"})
+	if err := f.service.Publish(context.Background(), e); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	return e
+}
+
+func (f *webhookFixture) deliver(t *testing.T) int {
+	n, err := f.service.DeliverBatch(context.Background())
+	if err != nil {
+		t.Fatalf("deliver batch: %v", err)
+	}
+
+	return n
+}
+
+func TestWebhookDeliverySignsRequests(t *testing.T) {
+	r := newReceiver(t)
+	f := newWebhookFixture(service.WebhookConfig{})
+	sub := f.subscribe(t, r.server.URL)
+	e := f.publish(t)
+
+	if n := f.deliver(t); n != 1 {
+		t.Fatalf("delivered %d, want 1", n)
+	}
+
+	requests := r.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.Header.Get(webhook.HeaderEvent); got != string(event.UserRegistered) {
+		t.Errorf("%s = %q", webhook.HeaderEvent, got)
+	}
+
+	d := only(t, f.deliveries)
+	if got := req.Header.Get(webhook.HeaderId); got != d.UUID.String() {
+		t.Errorf("%s = %q, want delivery uuid %s", webhook.HeaderId, got, d.UUID)
+	}
+
+	unix, err := strconv.ParseInt(req.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("%s: %v", webhook.HeaderTimestamp, err)
+	}
+	signature := req.Header.Get(webhook.HeaderSignature)
+	if !webhook.Verify(sub.Secret, time.Unix(unix, 0), req.Body, signature, 5*time.Minute) {
+		t.Errorf("signature %q does not verify", signature)
+	}
+	if webhook.Verify("whsec_other", time.Unix(unix, 0), req.Body, signature, 5*time.Minute) {
+		t.Error("signature verifies with another secret")
+	}
+
+	var message event.Message
+	if err := json.Unmarshal(req.Body, &message); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if message.ID != e.UUID {
+		t.Errorf("message id = %s, want event uuid %s", message.ID, e.UUID)
+	}
+
+	if d.Status != webhook.DeliverySucceeded || d.Attempts != 1 || d.DeliveredAt == nil {
+		t.Errorf("delivery = %s after %d attempts, delivered at %v", d.Status, d.Attempts, d.DeliveredAt)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	f := newWebhookFixture(service.WebhookConfig{MaxAttempts: 3})
+	sub := f.subscribe(t, r.server.URL)
+	f.publish(t)
+
+	start := time.Now()
+	f.deliver(t)
+
+	d := only(t, f.deliveries)
+	if d.Status != webhook.DeliveryPending || d.Attempts != 1 {
+		t.Fatalf("after a 500: %s after %d attempts, want pending after 1", d.Status, d.Attempts)
+	}
+	if d.ResponseCode == nil || *d.ResponseCode != http.StatusInternalServerError || d.LastError == nil {
+		t.Errorf("after a 500: response code %v, last error %v", d.ResponseCode, d.LastError)
+	}
+	if delay := d.NextAttemptAt.Sub(start); delay < 30*time.Second || delay > 31*time.Second {
+		t.Errorf("first retry in %s, want 30s", delay)
+	}
+
+	// not due yet
+	if n := f.deliver(t); n != 0 {
+		t.Fatalf("delivered %d before the retry is due", n)
+	}
+
+	f.deliveries.MakeDue()
+	start = time.Now()
+	f.deliver(t)
+
+	d = only(t, f.deliveries)
+	if d.Attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", d.Attempts)
+	}
+	if delay := d.NextAttemptAt.Sub(start); delay < 60*time.Second || delay > 61*time.Second {
+		t.Errorf("second retry in %s, want 60s", delay)
+	}
+
+	f.deliveries.MakeDue()
+	f.deliver(t)
+
+	d = only(t, f.deliveries)
+	if d.Status != webhook.DeliverySucceeded || d.Attempts != 3 || d.LastError != nil {
+		t.Errorf("after a 200: %s after %d attempts, last error %v", d.Status, d.Attempts, d.LastError)
+	}
+	if got := f.subs.Get(sub.UUID).FailureCount; got != 0 {
+		t.Errorf("failure count = %d after a success, want 0", got)
+	}
+	if got := len(r.received()); got != 3 {
+		t.Errorf("receiver got %d requests, want 3", got)
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	f := newWebhookFixture(service.WebhookConfig{MaxAttempts: 2})
+	f.subscribe(t, r.server.URL)
+	f.publish(t)
+
+	f.deliver(t)
+	f.deliveries.MakeDue()
+	f.deliver(t)
+
+	if d := only(t, f.deliveries); d.Status != webhook.DeliveryFailed || d.Attempts != 2 {
+		t.Errorf("delivery = %s after %d attempts, want failed after 2", d.Status, d.Attempts)
+	}
+
+	f.deliveries.MakeDue()
+	if n := f.deliver(t); n != 0 {
+		t.Errorf("delivered %d failed deliveries", n)
+	}
+}
+
+func TestWebhookDisabledAfterConsecutiveFailures(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	f := newWebhookFixture(service.WebhookConfig{DisableAfter: 3})
+	sub := f.subscribe(t, r.server.URL)
+	for i := 0; i < 4; i++ {
+		f.publish(t)
+	}
+
+	f.deliver(t)
+
+	if got := len(r.received()); got != 3 {
+		t.Errorf("receiver got %d requests, want 3 before the webhook is disabled", got)
+	}
+
+	s := f.subs.Get(sub.UUID)
+	if !s.IsDisabled() || s.FailureCount != 3 {
+		t.Fatalf("webhook disabled %v with %d failures, want disabled with 3", s.IsDisabled(), s.FailureCount)
+	}
+
+	f.deliveries.MakeDue()
+	if n := f.deliver(t); n != 0 {
+		t.Errorf("delivered %d for a disabled webhook", n)
+	}
+
+	if err := f.service.Enable(context.Background(), sub.UUID); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	f.deliveries.MakeDue()
+	if n := f.deliver(t); n != 4 {
+		t.Errorf("delivered %d after enabling, want 4", n)
+	}
+	if got := f.subs.Get(sub.UUID).FailureCount; got != 0 {
+		t.Errorf("failure count = %d after enabling and a success, want 0", got)
+	}
+}
+
+func TestWebhookReplaySendsTheSameBody(t *testing.T) {
+	r := newReceiver(t)
+	f := newWebhookFixture(service.WebhookConfig{})
+	sub := f.subscribe(t, r.server.URL)
+	e := f.publish(t)
+	f.deliver(t)
+
+	original := only(t, f.deliveries)
+	replay, err := f.service.Replay(context.Background(), original.UUID)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	if replay.ReplayOf == nil || *replay.ReplayOf != original.UUID || replay.Status != webhook.DeliveryPending {
+		t.Errorf("replay of %v is %s", replay.ReplayOf, replay.Status)
+	}
+
+	// publishing the event again is deduplicated, replaying is not
+	if err := f.service.Publish(context.Background(), e); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if n := f.deliver(t); n != 1 {
+		t.Fatalf("delivered %d, want the replay only", n)
+	}
+
+	requests := r.received()
+	if len(requests) != 2 {
+		t.Fatalf("receiver got %d requests, want 2", len(requests))
+	}
+
+	first, second := requests[0], requests[1]
+	if string(first.Body) != string(second.Body) {
+		t.Errorf("replayed body differs:\n%s\n%s", first.Body, second.Body)
+	}
+	if got := second.Header.Get(webhook.HeaderId); got != replay.UUID.String() {
+		t.Errorf("replay %s = %q, want %s", webhook.HeaderId, got, replay.UUID)
+	}
+
+	unix, _ := strconv.ParseInt(second.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if !webhook.Verify(sub.Secret, time.Unix(unix, 0), second.Body, second.Header.Get(webhook.HeaderSignature), time.Minute) {
+		t.Error("replay signature does not verify")
+	}
+
+	if d := get(f.deliveries, original.UUID); d.Status != webhook.DeliverySucceeded || d.Attempts != 1 {
+		t.Errorf("original changed to %s after %d attempts", d.Status, d.Attempts)
+	}
+}
+
+// only returns the delivery when there is exactly one.
+func only(t *testing.T, deliveries *deliveries) *webhook.Delivery {
+	t.Helper()
+
+	all := deliveries.All()
+	if len(all) != 1 {
+		t.Fatalf("%d deliveries, want 1", len(all))
+	}
+
+	return all[0]
+}
+
+func get(deliveries *deliveries, id uuid.UUID) *webhook.Delivery {
+	for _, d := range deliveries.All() {
+		if d.UUID == id {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func TestWebhookDeliveriesWaitWhileDisabled(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable)
+	f := newWebhookFixture(service.WebhookConfig{DisableAfter: 1})
+	sub := f.subscribe(t, r.server.URL)
+	f.publish(t)
+
+	f.deliver(t)
+	if !f.subs.Get(sub.UUID).IsDisabled() {
+		t.Fatal("webhook is not disabled")
+	}
+
+	f.publish(t)
+	waiting := f.deliveries.All()
+	if len(waiting) != 1 || waiting[0].Status != webhook.DeliveryPending {
+		t.Fatalf("deliveries %v, want the failed one pending", waiting)
+	}
+
+	if err := f.service.Enable(context.Background(), sub.UUID); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	f.deliveries.MakeDue()
+	f.deliver(t)
+	received := r.received()
+	if len(received) != 2 || received[1].Header.Get(webhook.HeaderId) != waiting[0].UUID.String() {
+		t.Errorf("receiver got %d requests, want the waiting delivery again", len(received))
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runBatches calls batch every interval until ctx is cancelled. Full batches
+// are repeated right away to drain a backlog.
+func runBatches(ctx context.Context, name string, logger *logrus.Logger, interval time.Duration, batchSize int, batch func(ctx context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := batch(ctx)
+			if err != nil {
+				logger.Errorf("%s error %v", name, err)
+				break
+			}
+
+			if n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimLease is how long claimed rows are skipped by other workers. A batch
+// stops sending once less than claimMargin is left, so no row is sent after
+// its claim expired; the rest is picked up again when the claim expires.
+const (
+	claimLease  = 5 * time.Minute
+	claimMargin = time.Minute
+)
+
+// backoff doubles base with every attempt up to max.
+func backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 0; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+
+	return delay
+}
@@ -2,25 +2,43 @@ package user
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/bysoft-wallet/users/internal/app/i18n"
 	"github.com/bysoft-wallet/users/pkg/currency"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	UUID      uuid.UUID
-	Email     string
-	Name      string
-	Hash      string
-	Settings  Settings
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	UUID                  uuid.UUID
+	Email                 string
+	Name                  string
+	Hash                  string
+	Settings              Settings
+	EmailVerifiedAt       *time.Time
+	LockedAt              *time.Time
+	PasswordResetRequired bool
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsLocked() bool {
+	return u.LockedAt != nil
 }
 
 type Settings struct {
 	Currency currency.Currency
+	// Locale is the language of emails and error messages, empty until the
+	// user picks one.
+	Locale i18n.Locale
 }
 
 func DefaultUserSettings() Settings {
@@ -62,8 +80,64 @@ type UserService struct {
 type UserRepository interface {
 	FindById(ctx context.Context, uuid uuid.UUID) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByIds(ctx context.Context, uuids []uuid.UUID) ([]*User, error)
+	FindByEmails(ctx context.Context, emails []string) ([]*User, error)
+	Search(ctx context.Context, filter *Filter) ([]*User, error)
 	Add(ctx context.Context, user *User) error
 	UpdateSettings(ctx context.Context, user_uuid uuid.UUID, settings *Settings) (*User, error)
+	UpdatePassword(ctx context.Context, user_uuid uuid.UUID, hash string) error
+	SetLocked(ctx context.Context, user_uuid uuid.UUID, lockedAt *time.Time) error
+	SetPasswordResetRequired(ctx context.Context, user_uuid uuid.UUID, required bool) error
+	Delete(ctx context.Context, user_uuid uuid.UUID) error
+}
+
+// Filter narrows down user search. Results are ordered from the newest user
+// to the oldest and paginated with a keyset cursor.
+type Filter struct {
+	EmailPrefix   string
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	EmailVerified *bool
+	After         *Cursor
+	Limit         int
+}
+
+type Cursor struct {
+	CreatedAt time.Time
+	UUID      uuid.UUID
+}
+
+func CursorFor(u *User) *Cursor {
+	return &Cursor{CreatedAt: u.CreatedAt, UUID: u.UUID}
+}
+
+func (c *Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.UUID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("invalid cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{CreatedAt: createdAt, UUID: id}, nil
 }
 
 func NewUserService(uRepo UserRepository) *UserService {
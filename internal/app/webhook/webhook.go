@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SecretPrefix makes signing secrets recognizable in configs and scanners.
+const SecretPrefix = "whsec_"
+
+const (
+	HeaderId        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+type Subscription struct {
+	UUID         uuid.UUID
+	URL          string
+	Secret       string
+	EventTypes   []string
+	FailureCount int
+	DisabledAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (s *Subscription) IsDisabled() bool {
+	return s.DisabledAt != nil
+}
+
+func (s *Subscription) Matches(eventType string) bool {
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Delivery struct {
+	UUID             uuid.UUID
+	SubscriptionUUID uuid.UUID
+	EventUUID        uuid.UUID
+	EventType        string
+	// Body is the exact JSON sent to the receiver, so replays are signed over
+	// the same bytes.
+	Body          string
+	Status        DeliveryStatus
+	Attempts      int
+	ResponseCode  *int
+	LastError     *string
+	ReplayOf      *uuid.UUID
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+}
+
+type DeliveryFilter struct {
+	SubscriptionUUID uuid.UUID
+	Status           DeliveryStatus
+	Limit            int
+}
+
+type SubscriptionRepository interface {
+	Add(ctx context.Context, s *Subscription) error
+	FindById(ctx context.Context, uuid uuid.UUID) (*Subscription, error)
+	FindAll(ctx context.Context) ([]*Subscription, error)
+	FindActiveForEvent(ctx context.Context, eventType string) ([]*Subscription, error)
+	// RecordFailure increments the consecutive failure counter and disables
+	// the subscription once it reaches disableAfter.
+	RecordFailure(ctx context.Context, uuid uuid.UUID, disableAfter int, at time.Time) error
+	ResetFailures(ctx context.Context, uuid uuid.UUID) error
+	Enable(ctx context.Context, uuid uuid.UUID) error
+	Delete(ctx context.Context, uuid uuid.UUID) error
+}
+
+type DeliveryRepository interface {
+	// Add skips a delivery of an already delivered event to the subscription,
+	// unless it is a replay.
+	Add(ctx context.Context, d *Delivery) error
+	FindById(ctx context.Context, uuid uuid.UUID) (*Delivery, error)
+	Find(ctx context.Context, filter *DeliveryFilter) ([]*Delivery, error)
+	// Claim moves the next attempt of due pending deliveries of active
+	// subscriptions to until and returns them, so other workers skip them
+	// while they are sent. Deliveries of disabled subscriptions stay pending
+	// until the subscription is enabled or deleted. It is a single statement
+	// and commits on its own outside a transaction.
+	Claim(ctx context.Context, limit int, until time.Time) ([]*Delivery, error)
+	Update(ctx context.Context, d *Delivery) error
+}
+
+type Request struct {
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+type Sender interface {
+	// Send returns the response status code, err is set for transport
+	// failures only.
+	Send(ctx context.Context, r *Request) (int, error)
+}
+
+// GenerateSecret returns a new random signing secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value: HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature, receivers written in Go may use it as is.
+func Verify(secret string, timestamp time.Time, body []byte, signature string, tolerance time.Duration) bool {
+	if tolerance > 0 {
+		age := time.Since(timestamp)
+		if age > tolerance || age < -tolerance {
+			return false
+		}
+	}
+
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,332 @@
+// Package config loads the service configuration from defaults, a YAML file,
+// environment variables and command line flags, in this order of precedence.
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/adapters"
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/ports"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// Every field is described by tags:
+//
+//	env      environment variable, the flag name is derived from it
+//	yaml     key in the config file section
+//	default  value used when no source sets the field
+//	required the field must be set
+//	secret   the value is redacted when the config is printed
+//	help     flag usage
+type Config struct {
+	Http     HttpConfig     `yaml:"http"`
+	Grpc     GrpcConfig     `yaml:"grpc"`
+	Database DatabaseConfig `yaml:"database"`
+	Jwt      JwtConfig      `yaml:"jwt"`
+	Sessions SessionsConfig `yaml:"sessions"`
+	Cookies  CookiesConfig  `yaml:"cookies"`
+	Cors     CorsConfig     `yaml:"cors"`
+	DPoP     DPoPConfig     `yaml:"dpop"`
+	Events   EventsConfig   `yaml:"events"`
+	Mail     MailConfig     `yaml:"mail"`
+	// MaxLookupSize limits the number of users resolved by one batch lookup,
+	// service.DefaultMaxLookupSize is used when not set.
+	MaxLookupSize int `env:"MAX_LOOKUP_SIZE" yaml:"max_lookup_size" help:"max users resolved by one batch lookup"`
+	// ShutdownTimeout bounds draining requests and stopping workers on
+	// SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" default:"30s" help:"max time to shut down gracefully"`
+}
+
+type HttpConfig struct {
+	Port            int           `env:"APP_PORT" yaml:"port" default:"8088" help:"HTTP port"`
+	AccessHeader    string        `env:"ACCESS_TOKEN_HEADER" yaml:"access_header" required:"true" help:"header carrying the access token"`
+	AccessCookie    string        `env:"ACCESS_TOKEN_COOKIE" yaml:"access_cookie" help:"cookie carrying the access token"`
+	TrustedProxies  []string      `env:"TRUSTED_PROXIES" yaml:"trusted_proxies" help:"comma separated proxy IPs or CIDR ranges whose forwarding headers are trusted"`
+	LegacyErrors    bool          `env:"LEGACY_ERRORS" yaml:"legacy_errors" help:"serve legacy error bodies to clients not accepting problem+json"`
+	ValidateOpenAPI bool          `env:"OPENAPI_VALIDATE" yaml:"validate_openapi" help:"log requests and responses not matching the OpenAPI document"`
+	ReadTimeout     time.Duration `env:"HTTP_READ_TIMEOUT" yaml:"read_timeout" default:"15s" help:"max time to read a request"`
+	WriteTimeout    time.Duration `env:"HTTP_WRITE_TIMEOUT" yaml:"write_timeout" default:"65s" help:"max time to write a response, longer than the 60s request timeout"`
+	IdleTimeout     time.Duration `env:"HTTP_IDLE_TIMEOUT" yaml:"idle_timeout" default:"120s" help:"max time a keep-alive connection waits for the next request"`
+}
+
+type GrpcConfig struct {
+	Port int `env:"GRPC_PORT" yaml:"port" default:"9088" help:"gRPC port"`
+}
+
+type DatabaseConfig struct {
+	Host     string `env:"DB_HOST" yaml:"host" required:"true" help:"PostgreSQL host"`
+	Port     int    `env:"DB_PORT" yaml:"port" default:"5432" help:"PostgreSQL port"`
+	Name     string `env:"POSTGRES_DB" yaml:"name" required:"true" help:"database name"`
+	User     string `env:"POSTGRES_USER" yaml:"user" required:"true" help:"database user"`
+	Password string `env:"POSTGRES_PASSWORD" yaml:"password" required:"true" secret:"true" help:"database password"`
+	SslMode  string `env:"DB_SSLMODE" yaml:"sslmode" default:"disable" help:"libpq sslmode"`
+	QueryLog bool   `env:"ENABLE_QUERY_LOG" yaml:"query_log" help:"log every query into logs/query-log.json"`
+}
+
+type JwtConfig struct {
+	Secret string `env:"JWT_SECRET" yaml:"secret" required:"true" secret:"true" help:"token signing secret"`
+	// TTLs are durations like 15m, plain numbers are seconds. The jwt and
+	// service defaults are used when not set.
+	AccessTTL        time.Duration `env:"JWT_ACCESS_TTL" yaml:"access_ttl" help:"access token lifetime"`
+	RefreshTTL       time.Duration `env:"JWT_REFRESH_TTL" yaml:"refresh_ttl" help:"refresh token lifetime"`
+	ImpersonationTTL time.Duration `env:"JWT_IMPERSONATION_TTL" yaml:"impersonation_ttl" help:"impersonation token lifetime"`
+	ProfileClaims    []string      `env:"JWT_PROFILE_CLAIMS" yaml:"profile_claims" help:"comma separated profile claims of access tokens"`
+}
+
+type SessionsConfig struct {
+	Max                      int    `env:"MAX_USER_SESSIONS" yaml:"max" required:"true" help:"max active sessions of a user"`
+	LimitPolicy              string `env:"SESSION_LIMIT_POLICY" yaml:"limit_policy" default:"evict_lru" help:"evict_lru, reject or confirm"`
+	Binding                  string `env:"SESSION_BINDING" yaml:"binding" default:"none" help:"none, ip, subnet or device"`
+	RevokeOnBindingViolation bool   `env:"SESSION_BINDING_REVOKE" yaml:"binding_revoke" help:"end sessions refreshed from another client"`
+}
+
+type CookiesConfig struct {
+	Enabled  bool   `env:"COOKIE_SESSIONS" yaml:"enabled" help:"keep tokens in cookies"`
+	Secure   bool   `env:"COOKIE_SECURE" yaml:"secure" default:"true" help:"set the Secure cookie attribute"`
+	SameSite string `env:"COOKIE_SAMESITE" yaml:"samesite" default:"strict" help:"strict, lax or none"`
+	Domain   string `env:"COOKIE_DOMAIN" yaml:"domain" help:"cookie domain"`
+	Path     string `env:"COOKIE_PATH" yaml:"path" help:"cookie path"`
+}
+
+type CorsConfig struct {
+	AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" yaml:"allowed_origins" help:"comma separated origins, CORS is off when empty"`
+	AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" yaml:"allowed_methods" help:"comma separated methods"`
+	AllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" yaml:"allowed_headers" help:"comma separated headers"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" yaml:"allow_credentials" help:"allow credentialed requests"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" yaml:"max_age" help:"preflight cache lifetime"`
+}
+
+type DPoPConfig struct {
+	ProofMaxAge  time.Duration `env:"DPOP_PROOF_MAX_AGE" yaml:"proof_max_age" help:"max age of DPoP proofs"`
+	RequireNonce bool          `env:"DPOP_REQUIRE_NONCE" yaml:"require_nonce" help:"require server issued DPoP nonces"`
+}
+
+type EventsConfig struct {
+	WebhookURL          string        `env:"EVENTS_WEBHOOK_URL" yaml:"webhook_url" help:"domain events receiver, events are logged when empty"`
+	RelayInterval       time.Duration `env:"OUTBOX_RELAY_INTERVAL" yaml:"relay_interval" help:"outbox polling interval"`
+	BatchSize           int           `env:"OUTBOX_BATCH_SIZE" yaml:"batch_size" help:"outbox events relayed at once"`
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" yaml:"webhook_max_attempts" help:"delivery attempts of one partner webhook event"`
+	WebhookDisableAfter int           `env:"WEBHOOK_DISABLE_AFTER" yaml:"webhook_disable_after" help:"consecutive failures disabling a partner webhook"`
+}
+
+type MailConfig struct {
+	PublicURL    string `env:"PUBLIC_URL" yaml:"public_url" help:"externally reachable base URL used in email links"`
+	SmtpHost     string `env:"SMTP_HOST" yaml:"smtp_host" help:"SMTP host, emails are logged when empty"`
+	SmtpPort     int    `env:"SMTP_PORT" yaml:"smtp_port" default:"587" help:"SMTP port"`
+	SmtpUser     string `env:"SMTP_USER" yaml:"smtp_user" help:"SMTP user"`
+	SmtpPassword string `env:"SMTP_PASSWORD" yaml:"smtp_password" secret:"true" help:"SMTP password"`
+	From         string `env:"MAIL_FROM" yaml:"from" help:"sender address"`
+}
+
+// URL is the pgx connection string of the database.
+func (c DatabaseConfig) URL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {c.SslMode}}.Encode(),
+	}
+
+	return u.String()
+}
+
+// App feeds the application config.
+func (c *Config) App(ctx context.Context, logger *logrus.Logger, pool *pgxpool.Pool) *app.Config {
+	return &app.Config{
+		Ctx:                      ctx,
+		Logger:                   logger,
+		DbPool:                   pool,
+		JwtSecret:                c.Jwt.Secret,
+		JwtAccessTTL:             seconds(c.Jwt.AccessTTL),
+		JwtRefreshTTL:            seconds(c.Jwt.RefreshTTL),
+		JwtImpersonationTTL:      seconds(c.Jwt.ImpersonationTTL),
+		MaxUserSessions:          c.Sessions.Max,
+		SessionLimitPolicy:       c.Sessions.LimitPolicy,
+		JwtProfileClaims:         c.Jwt.ProfileClaims,
+		MaxLookupSize:            c.MaxLookupSize,
+		EventsWebhookURL:         c.Events.WebhookURL,
+		OutboxRelayInterval:      seconds(c.Events.RelayInterval),
+		OutboxBatchSize:          c.Events.BatchSize,
+		WebhookMaxAttempts:       c.Events.WebhookMaxAttempts,
+		WebhookDisableAfter:      c.Events.WebhookDisableAfter,
+		SessionBinding:           c.Sessions.Binding,
+		RevokeOnBindingViolation: c.Sessions.RevokeOnBindingViolation,
+		DPoPProofMaxAge:          seconds(c.DPoP.ProofMaxAge),
+		DPoPRequireNonce:         c.DPoP.RequireNonce,
+		PublicURL:                c.Mail.PublicURL,
+		Smtp: adapters.SmtpConfig{
+			Host:     c.Mail.SmtpHost,
+			Port:     strconv.Itoa(c.Mail.SmtpPort),
+			User:     c.Mail.SmtpUser,
+			Password: c.Mail.SmtpPassword,
+			From:     c.Mail.From,
+		},
+	}
+}
+
+// HttpServer feeds the HTTP server config, the SameSite mode and the trusted
+// proxies are checked by Validate.
+func (c *Config) HttpServer() ports.HttpConfig {
+	sameSite, _ := ports.ParseSameSite(c.Cookies.SameSite)
+	proxies, _ := ports.ParseTrustedProxies(c.Http.TrustedProxies)
+
+	return ports.HttpConfig{
+		Port:         strconv.Itoa(c.Http.Port),
+		AccessHeader: c.Http.AccessHeader,
+		AccessCookie: c.Http.AccessCookie,
+		Cookies: ports.CookieConfig{
+			Enabled:  c.Cookies.Enabled,
+			Secure:   c.Cookies.Secure,
+			SameSite: sameSite,
+			Domain:   c.Cookies.Domain,
+			Path:     c.Cookies.Path,
+		},
+		Cors: ports.CorsConfig{
+			AllowedOrigins:   c.Cors.AllowedOrigins,
+			AllowedMethods:   c.Cors.AllowedMethods,
+			AllowedHeaders:   c.Cors.AllowedHeaders,
+			AllowCredentials: c.Cors.AllowCredentials,
+			MaxAge:           int(c.Cors.MaxAge / time.Second),
+		},
+		TrustedProxies:  proxies,
+		LegacyErrors:    c.Http.LegacyErrors,
+		ValidateOpenAPI: c.Http.ValidateOpenAPI,
+		ReadTimeout:     c.Http.ReadTimeout,
+		WriteTimeout:    c.Http.WriteTimeout,
+		IdleTimeout:     c.Http.IdleTimeout,
+	}
+}
+
+// seconds converts an optional duration for app.Config, nil keeps the
+// default.
+func seconds(d time.Duration) *int {
+	if d == 0 {
+		return nil
+	}
+
+	s := int(d / time.Second)
+	return &s
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// validate checks values the loader can't check field by field.
+func (c *Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for name, port := range map[string]int{"APP_PORT": c.Http.Port, "GRPC_PORT": c.Grpc.Port, "DB_PORT": c.Database.Port, "SMTP_PORT": c.Mail.SmtpPort} {
+		if port < 1 || port > 65535 {
+			add("%s: port %d is out of range", name, port)
+		}
+	}
+
+	if !contains(sslModes, c.Database.SslMode) {
+		add("DB_SSLMODE: unknown sslmode %q, expected one of %s", c.Database.SslMode, strings.Join(sslModes, ", "))
+	}
+
+	for name, d := range map[string]time.Duration{
+		"JWT_ACCESS_TTL":        c.Jwt.AccessTTL,
+		"JWT_REFRESH_TTL":       c.Jwt.RefreshTTL,
+		"JWT_IMPERSONATION_TTL": c.Jwt.ImpersonationTTL,
+		"CORS_MAX_AGE":          c.Cors.MaxAge,
+		"DPOP_PROOF_MAX_AGE":    c.DPoP.ProofMaxAge,
+		"OUTBOX_RELAY_INTERVAL": c.Events.RelayInterval,
+		"HTTP_READ_TIMEOUT":     c.Http.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":    c.Http.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":     c.Http.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":      c.ShutdownTimeout,
+	} {
+		if d != 0 && d < time.Second {
+			add("%s: %s is shorter than a second", name, d)
+		}
+	}
+
+	if c.Http.WriteTimeout != 0 && c.Http.WriteTimeout <= ports.RequestTimeout {
+		add("HTTP_WRITE_TIMEOUT: %s must be longer than the %s request timeout", c.Http.WriteTimeout, ports.RequestTimeout)
+	}
+
+	if c.Jwt.AccessTTL != 0 && c.Jwt.RefreshTTL != 0 && c.Jwt.RefreshTTL <= c.Jwt.AccessTTL {
+		add("JWT_REFRESH_TTL: %s must be longer than JWT_ACCESS_TTL %s", c.Jwt.RefreshTTL, c.Jwt.AccessTTL)
+	}
+
+	for name, n := range map[string]int{
+		"MAX_LOOKUP_SIZE":       c.MaxLookupSize,
+		"OUTBOX_BATCH_SIZE":     c.Events.BatchSize,
+		"WEBHOOK_MAX_ATTEMPTS":  c.Events.WebhookMaxAttempts,
+		"WEBHOOK_DISABLE_AFTER": c.Events.WebhookDisableAfter,
+	} {
+		if n < 0 {
+			add("%s: %d is negative", name, n)
+		}
+	}
+
+	if c.Sessions.Max < 0 {
+		add("MAX_USER_SESSIONS: %d is negative", c.Sessions.Max)
+	}
+
+	if _, err := service.ParseSessionLimitPolicy(c.Sessions.LimitPolicy); err != nil {
+		add("SESSION_LIMIT_POLICY: %v", err)
+	}
+
+	if _, err := service.ParseSessionBinding(c.Sessions.Binding); err != nil {
+		add("SESSION_BINDING: %v", err)
+	}
+
+	if _, err := service.NewProfileClaimsEnricher(c.Jwt.ProfileClaims); err != nil {
+		add("JWT_PROFILE_CLAIMS: %v", err)
+	}
+
+	if _, err := ports.ParseTrustedProxies(c.Http.TrustedProxies); err != nil {
+		add("TRUSTED_PROXIES: %v", err)
+	}
+
+	sameSite, err := ports.ParseSameSite(c.Cookies.SameSite)
+	if err != nil {
+		add("COOKIE_SAMESITE: %v", err)
+	} else if sameSite == http.SameSiteNoneMode && !c.Cookies.Secure {
+		add("COOKIE_SAMESITE: none requires COOKIE_SECURE")
+	}
+
+	for name, u := range map[string]string{"PUBLIC_URL": c.Mail.PublicURL, "EVENTS_WEBHOOK_URL": c.Events.WebhookURL} {
+		if u == "" {
+			continue
+		}
+
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			add("%s: %q is not an absolute http(s) URL", name, u)
+		}
+	}
+
+	if c.Mail.SmtpHost != "" && c.Mail.From == "" {
+		add("MAIL_FROM: must be set when SMTP_HOST is")
+	}
+
+	// maps are iterated in random order
+	sort.Strings(problems)
+	return problems
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
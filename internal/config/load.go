@@ -0,0 +1,323 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file when the -config flag is not given.
+const FileEnv = "CONFIG_FILE"
+
+const redacted = "******"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Error lists every configuration problem found.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+type field struct {
+	value reflect.Value
+	// path is the section and key in the config file, e.g. jwt.access_ttl.
+	path     string
+	env      string
+	def      string
+	help     string
+	required bool
+	secret   bool
+	set      bool
+}
+
+// flag is the command line flag of the field, JWT_ACCESS_TTL becomes
+// -jwt-access-ttl.
+func (f *field) flag() string {
+	return strings.ReplaceAll(strings.ToLower(f.env), "_", "-")
+}
+
+func fields(v reflect.Value, prefix string) []*field {
+	var list []*field
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		path := prefix + sf.Tag.Get("yaml")
+
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			list = append(list, fields(v.Field(i), path+".")...)
+			continue
+		}
+
+		list = append(list, &field{
+			value:    v.Field(i),
+			path:     path,
+			env:      sf.Tag.Get("env"),
+			def:      sf.Tag.Get("default"),
+			help:     sf.Tag.Get("help"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+		})
+	}
+
+	return list
+}
+
+// Load registers a flag for every setting on flags, parses args and loads
+// the config. Later sources override earlier ones: defaults, the YAML file
+// given with -config or CONFIG_FILE, environment variables, flags. Empty
+// environment variables are ignored. Every problem is reported at once in
+// an *Error.
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	config := &Config{}
+	list := fields(reflect.ValueOf(config).Elem(), "")
+
+	file := flags.String("config", os.Getenv(FileEnv), "YAML config file ("+FileEnv+")")
+	values := make(map[string]*field, len(list))
+	for _, f := range list {
+		flags.String(f.flag(), "", fmt.Sprintf("%s (%s)", f.help, f.env))
+		values[f.flag()] = f
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	report := func(source string, err error) {
+		problems = append(problems, fmt.Sprintf("%s: %v", source, err))
+	}
+
+	for _, f := range list {
+		if f.def != "" {
+			if err := f.parse(f.def); err != nil {
+				panic(fmt.Sprintf("config: default of %s: %v", f.path, err))
+			}
+		}
+	}
+
+	if *file != "" {
+		if err := loadFile(*file, list, report); err != nil {
+			report(*file, err)
+		}
+	}
+
+	for _, f := range list {
+		if v := os.Getenv(f.env); v != "" {
+			if err := f.parse(v); err != nil {
+				report(f.env, err)
+			}
+			f.set = true
+		}
+	}
+
+	flags.Visit(func(fl *flag.Flag) {
+		f, ok := values[fl.Name]
+		if !ok {
+			return
+		}
+
+		if err := f.parse(fl.Value.String()); err != nil {
+			report("-"+fl.Name, err)
+		}
+		f.set = true
+	})
+
+	for _, f := range list {
+		if f.required && !f.set {
+			problems = append(problems, fmt.Sprintf("%s: must be provided (%s in the config file, -%s)", f.env, f.path, f.flag()))
+		}
+	}
+
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	return config, nil
+}
+
+func loadFile(name string, list []*field, report func(string, error)) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	byPath := make(map[string]*field, len(list))
+	for _, f := range list {
+		byPath[f.path] = f
+	}
+
+	var walk func(node *yaml.Node, prefix string)
+	walk = func(node *yaml.Node, prefix string) {
+		if node.Kind != yaml.MappingNode {
+			report(name, fmt.Errorf("line %d: %s must be a mapping", node.Line, strings.TrimSuffix(prefix, ".")))
+			return
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			path := prefix + key.Value
+			source := fmt.Sprintf("%s:%d %s", name, key.Line, path)
+
+			f, ok := byPath[path]
+			switch {
+			case !ok && value.Kind == yaml.MappingNode:
+				walk(value, path+".")
+			case !ok:
+				report(source, fmt.Errorf("unknown setting"))
+			case value.Tag == "!!null":
+			case value.Kind == yaml.SequenceNode && f.value.Kind() == reflect.Slice:
+				items := make([]string, 0, len(value.Content))
+				for _, item := range value.Content {
+					items = append(items, item.Value)
+				}
+				f.value.Set(reflect.ValueOf(items))
+				f.set = true
+			case value.Kind != yaml.ScalarNode:
+				report(source, fmt.Errorf("must be a single value"))
+			default:
+				if err := f.parse(value.Value); err != nil {
+					report(source, err)
+				}
+				f.set = true
+			}
+		}
+	}
+	walk(doc.Content[0], "")
+
+	return nil
+}
+
+// parse sets the field from a string. Durations are Go durations like 15m
+// or plain numbers of seconds, lists are comma separated.
+func (f *field) parse(s string) error {
+	s = strings.TrimSpace(s)
+
+	if f.value.Type() == durationType {
+		if n, err := strconv.Atoi(s); err == nil {
+			f.value.SetInt(int64(time.Duration(n) * time.Second))
+			return nil
+		}
+
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected e.g. 15m or a number of seconds", s)
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		f.value.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		panic("config: unsupported type of " + f.path)
+	}
+
+	return nil
+}
+
+// display formats the value for printing, secrets are redacted.
+func (f *field) display() interface{} {
+	switch {
+	case f.secret && f.value.String() != "":
+		return redacted
+	case f.value.Type() == durationType:
+		return time.Duration(f.value.Int()).String()
+	case f.value.Kind() == reflect.Slice:
+		list := f.value.Interface().([]string)
+		if list == nil {
+			list = []string{}
+		}
+		return list
+	default:
+		return f.value.Interface()
+	}
+}
+
+// Redacted returns the effective settings by config file path with secrets
+// redacted, for logging.
+func (c *Config) Redacted() map[string]interface{} {
+	settings := map[string]interface{}{}
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "") {
+		settings[f.path] = f.display()
+	}
+
+	return settings
+}
+
+// Print writes the effective config as a config file with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := map[string]*yaml.Node{}
+
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "") {
+		parent, key := root, f.path
+		if section, name, ok := strings.Cut(f.path, "."); ok {
+			parent, key = sections[section], name
+			if parent == nil {
+				parent = &yaml.Node{Kind: yaml.MappingNode}
+				sections[section] = parent
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, parent)
+			}
+		}
+
+		var value yaml.Node
+		if err := value.Encode(f.display()); err != nil {
+			return err
+		}
+		if value.Kind == yaml.SequenceNode {
+			value.Style = yaml.FlowStyle
+		}
+
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &value)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
@@ -0,0 +1,293 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+func (h *HttpServer) registerAdminRoutes(r chi.Router) {
+	r.Use(h.Require(role.PermissionAdmin), h.RefuseImpersonation, h.RefuseAPIKey)
+
+	r.Get("/users", h.adminListUsers)
+	r.Get("/users/{uuid}", h.adminGetUser)
+	r.Post("/users/{uuid}/logout", h.adminForceLogout)
+	r.Post("/users/{uuid}/lock", h.adminLockUser)
+	r.Post("/users/{uuid}/unlock", h.adminUnlockUser)
+	r.Post("/users/{uuid}/resetPassword", h.adminForcePasswordReset)
+	r.Put("/users/{uuid}/settings", h.adminUpdateSettings)
+	r.Post("/users/{uuid}/impersonate", h.adminImpersonate)
+
+	r.Get("/audit", h.adminAudit)
+	r.Route("/webhooks", h.registerWebhookRoutes)
+}
+
+type AdminUserResponse struct {
+	UUID                  uuid.UUID       `json:"uuid"`
+	Email                 string          `json:"email"`
+	Name                  string          `json:"name"`
+	Settings              SettingsPayload `json:"settings"`
+	EmailVerified         bool            `json:"email_verified"`
+	Locked                bool            `json:"locked"`
+	LockedAt              *time.Time      `json:"locked_at"`
+	PasswordResetRequired bool            `json:"password_reset_required"`
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+}
+
+type AdminUsersResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type SessionResponse struct {
+	UUID      uuid.UUID `json:"uuid"`
+	Ip        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AdminUserDetailsResponse struct {
+	User        AdminUserResponse `json:"user"`
+	Roles       []string          `json:"roles"`
+	Permissions []string          `json:"permissions"`
+	Sessions    []SessionResponse `json:"sessions"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+func (e *AdminUsersResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (e *AdminUserResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (e *AdminUserDetailsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (e *StatusResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func newAdminUserResponse(u *user.User) AdminUserResponse {
+	return AdminUserResponse{
+		UUID:                  u.UUID,
+		Email:                 u.Email,
+		Name:                  u.Name,
+		Settings:              settingsPayload(u.Settings),
+		EmailVerified:         u.IsEmailVerified(),
+		Locked:                u.IsLocked(),
+		LockedAt:              u.LockedAt,
+		PasswordResetRequired: u.PasswordResetRequired,
+		CreatedAt:             u.CreatedAt,
+		UpdatedAt:             u.UpdatedAt,
+	}
+}
+
+func (h *HttpServer) adminListUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := userFilterFromQuery(r)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	page, err := h.app.AdminService.ListUsers(r.Context(), filter)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &AdminUsersResponse{Users: make([]AdminUserResponse, 0, len(page.Users))}
+	for _, u := range page.Users {
+		response.Users = append(response.Users, newAdminUserResponse(u))
+	}
+
+	if page.Next != nil {
+		response.NextCursor = page.Next.Encode()
+	}
+
+	render.Render(w, r, response)
+}
+
+func userFilterFromQuery(r *http.Request) (*user.Filter, error) {
+	query := r.URL.Query()
+	filter := &user.Filter{
+		EmailPrefix: query.Get("email"),
+	}
+
+	var err error
+	if v := query.Get("created_from"); v != "" {
+		if filter.CreatedFrom, err = parseQueryTime(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("created_to"); v != "" {
+		if filter.CreatedTo, err = parseQueryTime(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.EmailVerified = &verified
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		if filter.After, err = user.DecodeCursor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseQueryTime accepts either an RFC 3339 timestamp or a plain date.
+func parseQueryTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+func (h *HttpServer) adminGetUser(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugUserNotFound, err, w, r)
+		return
+	}
+
+	details, err := h.app.AdminService.GetUser(r.Context(), userUUID)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &AdminUserDetailsResponse{
+		User:        newAdminUserResponse(details.User),
+		Roles:       role.Names(details.Roles),
+		Permissions: role.Permissions(details.Roles),
+		Sessions:    make([]SessionResponse, 0, len(details.Sessions)),
+	}
+
+	for _, s := range details.Sessions {
+		response.Sessions = append(response.Sessions, SessionResponse{
+			UUID:      s.UUID,
+			Ip:        s.Ip,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+		})
+	}
+
+	render.Render(w, r, response)
+}
+
+func (h *HttpServer) adminForceLogout(w http.ResponseWriter, r *http.Request) {
+	h.adminUserAction(h.app.AdminService.ForceLogout, w, r)
+}
+
+func (h *HttpServer) adminLockUser(w http.ResponseWriter, r *http.Request) {
+	h.adminUserAction(h.app.AdminService.Lock, w, r)
+}
+
+func (h *HttpServer) adminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	h.adminUserAction(h.app.AdminService.Unlock, w, r)
+}
+
+func (h *HttpServer) adminForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	h.adminUserAction(h.app.AdminService.ForcePasswordReset, w, r)
+}
+
+func (h *HttpServer) adminUserAction(
+	action func(ctx context.Context, userUUID uuid.UUID) error,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugUserNotFound, err, w, r)
+		return
+	}
+
+	if err = action(r.Context(), userUUID); err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
+
+func (h *HttpServer) adminUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugUserNotFound, err, w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var payload SettingsPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(payload)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	u, err := h.app.AdminService.UpdateSettings(r.Context(), &service.UpdateSettingsRequest{
+		UserUUID: userUUID,
+		Currency: payload.Currency,
+		Locale:   payload.Locale,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := newAdminUserResponse(u)
+	render.Render(w, r, &response)
+}
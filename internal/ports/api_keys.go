@@ -0,0 +1,172 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+func (h *HttpServer) registerAPIKeyRoutes(r chi.Router) {
+	r.Use(h.Require(role.PermissionProfileWrite), h.RefuseImpersonation, h.RefuseAPIKey)
+
+	r.Get("/", h.listAPIKeys)
+	r.Post("/", h.createAPIKey)
+	r.Delete("/{uuid}", h.revokeAPIKey)
+}
+
+// RefuseAPIKey rejects requests authenticated with an API key, so a leaked
+// key can not be used to manage credentials or administer users. It must be
+// mounted after Require.
+func (h *HttpServer) RefuseAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access, ok := h.access(w, r)
+		if !ok {
+			return
+		}
+
+		if _, ok := access.Claims.Extra[service.APIKeyClaim]; ok {
+			h.Forbidden(apperrors.SlugAPIKeyForbidden, errors.New("not allowed with an API key"), w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	UUID       uuid.UUID  `json:"uuid"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Key is the secret itself, returned only once on creation.
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+func (e *APIKeyResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 201)
+	return nil
+}
+
+func (e *APIKeysResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func newAPIKeyResponse(k *apikey.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		UUID:       k.UUID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+func (h *HttpServer) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.app.APIKeyService.List(r.Context(), access.Claims.UserId)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &APIKeysResponse{Keys: make([]APIKeyResponse, 0, len(keys))}
+	for _, k := range keys {
+		response.Keys = append(response.Keys, newAPIKeyResponse(k))
+	}
+
+	render.Render(w, r, response)
+}
+
+func (h *HttpServer) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request CreateAPIKeyRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	created, err := h.app.APIKeyService.Create(r.Context(), &service.CreateAPIKeyRequest{
+		UserUUID:  access.Claims.UserId,
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := newAPIKeyResponse(created.Key)
+	response.Key = created.Secret
+
+	render.Render(w, r, &response)
+}
+
+func (h *HttpServer) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	keyUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugAPIKeyNotFound, err, w, r)
+		return
+	}
+
+	err = h.app.APIKeyService.Revoke(r.Context(), access.Claims.UserId, keyUUID)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
@@ -0,0 +1,108 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+)
+
+// signUp registers the user and returns the uuid and the token pair.
+func (c *contract) signUp(email, password string) (userUUID string, pair map[string]interface{}) {
+	c.t.Helper()
+
+	pair = c.json(http.MethodPost, "/api/v1/signUp", "", &SignUpRequest{Email: email, Password: password, Name: email}, http.StatusOK)
+	return str(c.json(http.MethodGet, "/api/v1/me", str(pair, "access"), nil, http.StatusOK), "uuid"), pair
+}
+
+// admin registers an admin and returns their access token.
+func (c *contract) admin(email string) string {
+	c.t.Helper()
+
+	ctx := context.Background()
+	c.signUp(email, "password")
+	u, err := c.store.Repositories().Users.FindByEmail(ctx, email)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err = c.store.Repositories().Roles.AssignToUser(ctx, u.UUID, role.RoleAdmin); err != nil {
+		c.t.Fatal(err)
+	}
+
+	access, _ := c.signIn(email, "password", "contract-test", "192.0.2.1")
+	return access
+}
+
+func (c *contract) apiKey(access string) string {
+	c.t.Helper()
+
+	return str(c.json(http.MethodPost, "/api/v1/apiKeys/", access, &CreateAPIKeyRequest{Name: "ci"}, http.StatusCreated), "key")
+}
+
+func TestAPIKeysAreRevokedWithThePassword(t *testing.T) {
+	c := newContract(t)
+	annUUID, pair := c.signUp("ann@example.com", "password")
+	access := str(pair, "access")
+
+	key := c.apiKey(access)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusOK)
+	c.json(http.MethodPut, "/api/v1/password", access, &ChangePasswordRequest{Password: "password", NewPassword: "password2"}, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusUnauthorized)
+
+	access, _ = c.signIn("ann@example.com", "password2", "contract-test", "192.0.2.1")
+	key = c.apiKey(access)
+	admin := c.admin("bob@example.com")
+	c.json(http.MethodPost, "/api/v1/admin/users/"+annUUID+"/resetPassword", admin, nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusUnauthorized)
+
+	reset := c.linkToken(service.PasswordResetPath)
+	c.form(service.PasswordResetPath, url.Values{"token": {reset}, "new_password": {"password3"}}, http.StatusOK)
+	access, _ = c.signIn("ann@example.com", "password3", "contract-test", "192.0.2.1")
+	key = c.apiKey(access)
+	c.signIn("ann@example.com", "password3", "another-browser", "198.51.100.7")
+	c.form(service.NotMePath, url.Values{"token": {c.linkToken(service.NotMePath)}}, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusUnauthorized)
+}
+
+func TestAPIKeysAreRefusedWhileThePasswordMustBeReset(t *testing.T) {
+	c := newContract(t)
+	_, pair := c.signUp("ann@example.com", "password")
+	key := c.apiKey(str(pair, "access"))
+
+	ann, err := c.store.Repositories().Users.FindByEmail(context.Background(), "ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.store.Repositories().Users.SetPasswordResetRequired(context.Background(), ann.UUID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusUnauthorized)
+}
+
+func TestAPIKeyActsAsItsOwnerUntilRevoked(t *testing.T) {
+	c := newContract(t)
+	annUUID, pair := c.signUp("ann@example.com", "password")
+	access := str(pair, "access")
+	_, other := c.signUp("bob@example.com", "password")
+
+	created := c.json(http.MethodPost, "/api/v1/apiKeys/", access, &CreateAPIKeyRequest{Name: "ci"}, http.StatusCreated)
+	key := str(created, "key")
+	if got := str(c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusOK), "uuid"); got != annUUID {
+		t.Errorf("API key acts as %s, want %s", got, annUUID)
+	}
+
+	// a leaked key can not manage credentials
+	c.json(http.MethodPost, "/api/v1/apiKeys/", key, &CreateAPIKeyRequest{Name: "another"}, http.StatusForbidden)
+	c.json(http.MethodPut, "/api/v1/password", key, &ChangePasswordRequest{Password: "password", NewPassword: "password2"}, http.StatusForbidden)
+
+	keys := "/api/v1/apiKeys/" + str(created, "uuid")
+	c.json(http.MethodDelete, keys, str(other, "access"), nil, http.StatusNotFound)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusOK)
+
+	c.json(http.MethodDelete, keys, access, nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/me", key, nil, http.StatusUnauthorized)
+}
@@ -0,0 +1,206 @@
+package ports
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength bounds the user agent stored with audit events.
+const maxUserAgentLength = 512
+
+// AuditMeta puts the request metadata recorded with audit events into the
+// context. It must be mounted after middleware.RequestID and RealIP.
+func (h *HttpServer) AuditMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithMeta(r.Context(), audit.Meta{
+			Ip:        clientIp(r),
+			UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+			RequestId: middleware.GetReqID(r.Context()),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIp returns the request IP without the port.
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// auditActor is the user acting with the access token: the admin for
+// impersonation tokens.
+func auditActor(claims *jwt.AccessClaims) uuid.UUID {
+	if claims.IsImpersonated() {
+		if admin, err := uuid.Parse(claims.Act.Subject); err == nil {
+			return admin
+		}
+	}
+
+	return claims.UserId
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+
+	return s
+}
+
+type AuditEventResponse struct {
+	UUID      uuid.UUID              `json:"uuid"`
+	Type      string                 `json:"type"`
+	ActorUUID *uuid.UUID             `json:"actor_uuid"`
+	UserUUID  *uuid.UUID             `json:"user_uuid"`
+	Data      map[string]interface{} `json:"data"`
+	Ip        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	RequestId string                 `json:"request_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type AuditEventsResponse struct {
+	Events     []AuditEventResponse `json:"events"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func (e *AuditEventsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func newAuditEventsResponse(page *service.AuditPage) *AuditEventsResponse {
+	response := &AuditEventsResponse{Events: make([]AuditEventResponse, 0, len(page.Events))}
+	for _, e := range page.Events {
+		response.Events = append(response.Events, AuditEventResponse{
+			UUID:      e.UUID,
+			Type:      string(e.Type),
+			ActorUUID: e.ActorUUID,
+			UserUUID:  e.UserUUID,
+			Data:      e.Data,
+			Ip:        e.Ip,
+			UserAgent: e.UserAgent,
+			RequestId: e.RequestId,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	if page.Next != nil {
+		response.NextCursor = page.Next.Encode()
+	}
+
+	return response
+}
+
+// activity lists the audit events of the current user.
+func (h *HttpServer) activity(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	page, err := h.app.AuditService.ListForUser(r.Context(), access.Claims.UserId, filter.After, filter.Limit)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, newAuditEventsResponse(page))
+}
+
+func (h *HttpServer) adminAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	page, err := h.app.AuditService.Search(r.Context(), filter)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, newAuditEventsResponse(page))
+}
+
+func auditFilterFromQuery(r *http.Request) (*audit.Filter, error) {
+	query := r.URL.Query()
+	filter := &audit.Filter{
+		Ip: query.Get("ip"),
+	}
+
+	var err error
+	if v := query.Get("user"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserUUID = &id
+	}
+
+	if v := query.Get("actor"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.ActorUUID = &id
+	}
+
+	if v := query.Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseQueryTime(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseQueryTime(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		if filter.After, err = user.DecodeCursor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,191 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/pkg/dpop"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+const bearerScheme = "Bearer"
+
+type accessContextKey struct{}
+
+var errNotAuthenticated = apperrors.NewAuthorizationError("Token not presented", apperrors.SlugInvalidToken)
+
+// AccessFrom returns the access token stored by Authenticate.
+func AccessFrom(ctx context.Context) (*jwt.AccessJWT, bool) {
+	access, ok := ctx.Value(accessContextKey{}).(*jwt.AccessJWT)
+	return access, ok
+}
+
+// ClaimsFrom returns the claims of the access token stored by Authenticate.
+func ClaimsFrom(ctx context.Context) (*jwt.AccessClaims, bool) {
+	access, ok := AccessFrom(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	return &access.Claims, true
+}
+
+// UserUUIDFrom returns the user the request is made for, service tokens
+// have none.
+func UserUUIDFrom(ctx context.Context) (uuid.UUID, bool) {
+	claims, ok := ClaimsFrom(ctx)
+	if !ok || claims.IsService() {
+		return uuid.Nil, false
+	}
+
+	return claims.UserId, true
+}
+
+// Authenticate validates the access token taken from the configured header,
+// the Authorization header or the access cookie and stores it in the request
+// context. Requests without a valid token are rejected.
+func (h *HttpServer) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := AccessFrom(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		access, fromCookie, err := h.authenticate(w, r)
+		if err != nil {
+			h.Unauthorised(unauthorisedSlug(err), err, w, r)
+			return
+		}
+
+		// browsers attach cookies to cross site requests, mutations
+		// authenticated by a cookie must prove they come from our client
+		if fromCookie && !isSafeMethod(r.Method) {
+			if err = checkCsrf(r); err != nil {
+				h.Forbidden(apperrors.SlugCsrfTokenInvalid, err, w, r)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), accessContextKey{}, access)
+		if !access.Claims.IsService() {
+			ctx = audit.WithActor(ctx, auditActor(&access.Claims))
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Require authenticates a user request and rejects it unless the access
+// token scope grants every given permission.
+func (h *HttpServer) Require(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return h.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := ClaimsFrom(r.Context())
+			if claims.IsService() {
+				h.Forbidden(apperrors.SlugUserTokenRequired, errors.New("service tokens are not accepted"), w, r)
+				return
+			}
+
+			for _, permission := range permissions {
+				if !claims.HasScope(permission) {
+					h.Forbidden(apperrors.SlugPermissionDenied, fmt.Errorf("permission %s required", permission), w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// RequireService authenticates a machine client request and rejects it
+// unless the service token grants every given scope.
+func (h *HttpServer) RequireService(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return h.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := ClaimsFrom(r.Context())
+			if !claims.IsService() {
+				h.Forbidden(apperrors.SlugServiceTokenRequired, errors.New("only service tokens are accepted"), w, r)
+				return
+			}
+
+			for _, scope := range scopes {
+				if !claims.HasScope(scope) {
+					h.Forbidden(apperrors.SlugPermissionDenied, fmt.Errorf("scope %s required", scope), w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// access returns the authenticated access token, a handler mounted without
+// the authentication middleware fails closed with 401.
+func (h *HttpServer) access(w http.ResponseWriter, r *http.Request) (*jwt.AccessJWT, bool) {
+	access, ok := AccessFrom(r.Context())
+	if !ok {
+		h.Unauthorised(apperrors.SlugInvalidToken, errNotAuthenticated, w, r)
+	}
+
+	return access, ok
+}
+
+// unauthorisedSlug keeps the slug of an authorization error, e.g. a client
+// told to use a DPoP nonce must be able to tell it from an invalid token.
+func unauthorisedSlug(err error) string {
+	if appError, ok := apperrors.As(err); ok && appError.ErrorType() == apperrors.ErrorTypeAuthorization {
+		return appError.Slug()
+	}
+
+	return apperrors.SlugInvalidToken
+}
+
+func (h *HttpServer) authenticate(w http.ResponseWriter, r *http.Request) (*jwt.AccessJWT, bool, error) {
+	scheme, token, fromCookie := h.tokenFromRequest(r)
+	if token == "" {
+		return &jwt.AccessJWT{}, false, errNotAuthenticated
+	}
+
+	access, err := h.app.AccessService.ValidateAccess(r.Context(), token)
+	if err != nil {
+		return &jwt.AccessJWT{}, false, err
+	}
+
+	if err = h.checkAccessProof(w, r, scheme, token, access); err != nil {
+		return &jwt.AccessJWT{}, false, err
+	}
+
+	return access, fromCookie, nil
+}
+
+// tokenFromRequest looks for the access token in the configured header, the
+// Authorization header and the access cookie, in that order.
+func (h *HttpServer) tokenFromRequest(r *http.Request) (scheme, token string, fromCookie bool) {
+	if h.config.AccessHeader != "" {
+		if value := r.Header.Get(h.config.AccessHeader); value != "" {
+			return bearerScheme, strings.TrimPrefix(value, bearerScheme+" "), false
+		}
+	}
+
+	if parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2); len(parts) == 2 {
+		if parts[0] == bearerScheme || parts[0] == dpop.Scheme {
+			return parts[0], parts[1], false
+		}
+	}
+
+	if h.config.AccessCookie != "" {
+		if cookie, err := r.Cookie(h.config.AccessCookie); err == nil {
+			return bearerScheme, cookie.Value, true
+		}
+	}
+
+	return "", "", false
+}
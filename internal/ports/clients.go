@@ -0,0 +1,183 @@
+package ports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app/client"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const grantTypeClientCredentials = "client_credentials"
+
+func (h *HttpServer) registerInternalRoutes(r chi.Router) {
+	r.With(h.RequireService(client.ScopeTokensIntrospect)).Post("/introspect", h.introspect)
+	r.With(h.RequireService(client.ScopeUsersRead)).Post("/users/lookup", h.lookupUsers)
+}
+
+type ClientTokenRequest struct {
+	GrantType    string `json:"grant_type" validate:"required"`
+	ClientId     string `json:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret" validate:"required"`
+	Scope        string `json:"scope"`
+}
+
+type ClientTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+func (e *ClientTokenResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "no-store")
+	render.Status(r, 200)
+	return nil
+}
+
+// token implements the OAuth 2.0 token endpoint for the client credentials
+// grant. Credentials are accepted in a form or JSON body or with HTTP Basic.
+func (h *HttpServer) token(w http.ResponseWriter, r *http.Request) {
+	var request ClientTokenRequest
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+
+		request = ClientTokenRequest{
+			GrantType:    r.PostForm.Get("grant_type"),
+			ClientId:     r.PostForm.Get("client_id"),
+			ClientSecret: r.PostForm.Get("client_secret"),
+			Scope:        r.PostForm.Get("scope"),
+		}
+	} else {
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+
+		if err := json.Unmarshal(body, &request); err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+	}
+
+	if id, secret, ok := r.BasicAuth(); ok {
+		request.ClientId = id
+		request.ClientSecret = secret
+	}
+
+	err := h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	if request.GrantType != grantTypeClientCredentials {
+		h.BadRequest(apperrors.SlugUnsupportedGrantType, fmt.Errorf("grant type %s is not supported", request.GrantType), w, r)
+		return
+	}
+
+	access, err := h.app.ClientService.IssueToken(r.Context(), &service.ClientCredentialsRequest{
+		ClientId:     request.ClientId,
+		ClientSecret: request.ClientSecret,
+		Scopes:       strings.Fields(request.Scope),
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &ClientTokenResponse{
+		AccessToken: access.Token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(access.Claims.ExpiresAt.Time).Seconds()),
+		Scope:       access.Claims.Scope,
+	})
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type IntrospectResponse struct {
+	Active    bool       `json:"active"`
+	UserUUID  *uuid.UUID `json:"user_uuid,omitempty"`
+	ClientId  string     `json:"client_id,omitempty"`
+	Scope     string     `json:"scope,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	ActorUUID string     `json:"actor_uuid,omitempty"`
+	ExpiresAt *time.Time `json:"exp,omitempty"`
+	// Cnf is set for DPoP bound tokens, the resource server must check the
+	// proof key.
+	Cnf *jwt.Confirmation `json:"cnf,omitempty"`
+}
+
+func (e *IntrospectResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+// introspect lets internal services validate a user credential (RFC 7662).
+func (h *HttpServer) introspect(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request IntrospectRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	access, err := h.app.AccessService.ValidateAccess(r.Context(), request.Token)
+	if err != nil {
+		render.Render(w, r, &IntrospectResponse{Active: false})
+		return
+	}
+
+	response := &IntrospectResponse{
+		Active:   true,
+		ClientId: access.Claims.ClientId,
+		Scope:    access.Claims.Scope,
+		Roles:    access.Claims.Roles,
+		Cnf:      access.Claims.Cnf,
+	}
+
+	if !access.Claims.IsService() {
+		response.UserUUID = &access.Claims.UserId
+	}
+
+	if access.Claims.IsImpersonated() {
+		response.ActorUUID = access.Claims.Act.Subject
+	}
+
+	if access.Claims.ExpiresAt != nil {
+		response.ExpiresAt = &access.Claims.ExpiresAt.Time
+	}
+
+	render.Render(w, r, response)
+}
@@ -0,0 +1,61 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/service"
+)
+
+// clientToken requests a client credentials token with the form.
+func (c *contract) clientToken(form string, status int) ClientTokenResponse {
+	c.t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/token", strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token ClientTokenResponse
+	if w := c.serve(r, status); status == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	return token
+}
+
+func TestClientTokensCarryTheGrantedScopes(t *testing.T) {
+	c := newContract(t)
+	_, pair := c.signUp("ann@example.com", "password")
+
+	created, err := c.h.app.ClientService.CreateClient(context.Background(), &service.CreateClientRequest{
+		ID:     "billing",
+		Name:   "Billing",
+		Scopes: []string{client.ScopeUsersRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := "grant_type=client_credentials&client_id=billing&client_secret=" + created.Secret
+	c.clientToken("grant_type=client_credentials&client_id=billing&client_secret=wrong", http.StatusUnauthorized)
+	c.clientToken("grant_type=password&client_id=billing&client_secret="+created.Secret, http.StatusBadRequest)
+	c.clientToken(credentials+"&scope="+client.ScopeTokensIntrospect, http.StatusBadRequest)
+
+	token := c.clientToken(credentials, http.StatusOK)
+	if token.Scope != client.ScopeUsersRead {
+		t.Errorf("scope %q, want %q", token.Scope, client.ScopeUsersRead)
+	}
+
+	c.json(http.MethodPost, "/api/v1/internal/users/lookup", token.AccessToken, &LookupUsersRequest{Emails: []string{"ann@example.com"}}, http.StatusOK)
+	c.json(http.MethodPost, "/api/v1/internal/introspect", token.AccessToken, &IntrospectRequest{Token: str(pair, "access")}, http.StatusForbidden)
+
+	// service and user tokens are not interchangeable
+	c.json(http.MethodGet, "/api/v1/me", token.AccessToken, nil, http.StatusForbidden)
+	c.json(http.MethodPost, "/api/v1/internal/users/lookup", str(pair, "access"), &LookupUsersRequest{Emails: []string{"ann@example.com"}}, http.StatusForbidden)
+}
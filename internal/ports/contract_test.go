@@ -0,0 +1,370 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/pkg/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const contractPublicURL = "https://users.example.com"
+
+var linkPattern = regexp.MustCompile(`https://\S+`)
+
+// contract serves requests through the router and checks every response
+// against the OpenAPI document.
+type contract struct {
+	t       *testing.T
+	h       *HttpServer
+	store   *memoryStore
+	covered map[string]bool
+}
+
+// newTestApplication runs the application on in-memory repositories.
+func newTestApplication(t *testing.T) (*app.Application, *memoryStore) {
+	store := newMemoryStore()
+	return newTestApplicationWith(t, store, store.Repositories(), app.Config{}), store
+}
+
+// newTestApplicationWith runs the application with the config on the
+// repositories, which save to the store unless replaced.
+func newTestApplicationWith(t *testing.T, store *memoryStore, repositories *app.Repositories, config app.Config) *app.Application {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	accessTTL, refreshTTL := 900, 3600
+	config.Ctx = context.Background()
+	config.Logger = logger
+	config.JwtSecret = "contract-test-secret"
+	config.JwtAccessTTL = &accessTTL
+	config.JwtRefreshTTL = &refreshTTL
+	config.PublicURL = contractPublicURL
+	config.Mailer = store.Mailer()
+
+	application, err := app.NewApplicationWith(&config, repositories)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return application
+}
+
+func newContract(t *testing.T) *contract {
+	store := newMemoryStore()
+	return newContractWith(t, store, store.Repositories(), app.Config{})
+}
+
+func newContractWith(t *testing.T, store *memoryStore, repositories *app.Repositories, config app.Config) *contract {
+	return &contract{
+		t:       t,
+		h:       NewHttpServer(newTestApplicationWith(t, store, repositories, config), HttpConfig{}),
+		store:   store,
+		covered: map[string]bool{},
+	}
+}
+
+// serve runs the request, expects the status and validates the response.
+func (c *contract) serve(r *http.Request, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	if r.Header.Get("User-Agent") == "" {
+		r.Header.Set("User-Agent", "contract-test")
+	}
+
+	w := httptest.NewRecorder()
+	c.h.router.ServeHTTP(w, r)
+
+	name := r.Method + " " + r.URL.Path
+	if w.Code != status {
+		c.t.Fatalf("%s: status %d, want %d: %s", name, w.Code, status, w.Body.String())
+	}
+
+	rctx := chi.NewRouteContext()
+	if !c.h.router.Match(rctx, r.Method, r.URL.Path) {
+		c.t.Fatalf("%s: no route", name)
+	}
+
+	route := r.Method + " " + rctx.RoutePattern()
+	c.covered[route] = true
+
+	op, ok := c.h.openAPIDoc.Operation(r.Method, rctx.RoutePattern())
+	if !ok {
+		c.t.Errorf("%s is not described", route)
+		return w
+	}
+
+	response, ok := op.Responses[strconv.Itoa(w.Code)]
+	if !ok && w.Code >= http.StatusBadRequest {
+		response, ok = op.Responses["default"]
+	}
+	if !ok {
+		c.t.Errorf("%s: status %d is not documented", route, w.Code)
+		return w
+	}
+
+	if len(response.Content) == 0 {
+		return w
+	}
+
+	contentType := w.Header().Get("Content-Type")
+	for documented, media := range response.Content {
+		if !strings.HasPrefix(contentType, documented) {
+			continue
+		}
+
+		if documented != openapi.ContentTypeHTML {
+			for _, p := range c.h.openAPIDoc.ValidateJSON(media.Schema, w.Body.Bytes()) {
+				c.t.Errorf("%s: %d response does not match the document: %s", route, w.Code, p)
+			}
+		}
+		return w
+	}
+
+	c.t.Errorf("%s: content type %q of a %d response is not documented", route, contentType, w.Code)
+	return w
+}
+
+// json sends body as JSON with the access token and decodes the response.
+func (c *contract) json(method, path, access string, body interface{}, status int) map[string]interface{} {
+	c.t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	r := httptest.NewRequest(method, path, reader)
+	r.Header.Set("Content-Type", "application/json")
+	if access != "" {
+		r.Header.Set("Authorization", "Bearer "+access)
+	}
+
+	w := c.serve(r, status)
+
+	decoded := map[string]interface{}{}
+	if strings.Contains(w.Header().Get("Content-Type"), "json") && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+
+	return decoded
+}
+
+func (c *contract) form(path string, values url.Values, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.serve(r, status)
+}
+
+func (c *contract) page(path string, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	return c.serve(httptest.NewRequest(http.MethodGet, path, nil), status)
+}
+
+// signIn returns the token pair of the user signing in from the device.
+func (c *contract) signIn(email, password, userAgent, ip string) (access, refresh string) {
+	c.t.Helper()
+
+	b, _ := json.Marshal(&LoginRequest{Email: email, Password: password})
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/signIn", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", userAgent)
+	r.RemoteAddr = ip + ":1234"
+
+	var pair TokenPairResponse
+	if err := json.Unmarshal(c.serve(r, http.StatusOK).Body.Bytes(), &pair); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return pair.Access, pair.Refresh
+}
+
+// linkToken returns the token of the last emailed link to the path.
+func (c *contract) linkToken(path string) string {
+	c.t.Helper()
+
+	c.h.app.DeviceService.Wait()
+	c.h.app.PasswordResetService.Wait()
+
+	messages := c.store.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		for _, link := range linkPattern.FindAllString(messages[i].Text, -1) {
+			u, err := url.Parse(link)
+			if err == nil && u.Path == path {
+				return u.Query().Get("token")
+			}
+		}
+	}
+
+	c.t.Fatalf("no link to %s in %d emails", path, len(messages))
+	return ""
+}
+
+func str(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func TestRoutesMatchTheOpenAPIDocument(t *testing.T) {
+	c := newContract(t)
+
+	missing, unregistered := c.h.routeDrift(c.h.router)
+	for _, route := range missing {
+		t.Errorf("route %s is not described", route)
+	}
+	for _, route := range unregistered {
+		t.Errorf("described route %s is not registered", route)
+	}
+}
+
+// TestContract calls every described route and validates the responses
+// against the OpenAPI document.
+func TestContract(t *testing.T) {
+	c := newContract(t)
+	ctx := context.Background()
+
+	c.json(http.MethodGet, "/api/v1/health", "", nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/errors", "", nil, http.StatusOK)
+	c.json(http.MethodGet, OpenAPIPath, "", nil, http.StatusOK)
+
+	// profile
+	pair := c.json(http.MethodPost, "/api/v1/signUp", "", &SignUpRequest{Email: "ann@example.com", Password: "password", Name: "Ann"}, http.StatusOK)
+	access := str(pair, "access")
+	c.json(http.MethodPost, "/api/v1/signIn", "", &LoginRequest{Email: "ann@example.com", Password: "wrong"}, http.StatusBadRequest)
+	c.json(http.MethodPost, "/api/v1/signUp", "", &SignUpRequest{Email: "ann@example.com", Password: "password", Name: "Ann"}, http.StatusBadRequest)
+	c.json(http.MethodGet, "/api/v1/me", "", nil, http.StatusUnauthorized)
+
+	me := c.json(http.MethodGet, "/api/v1/me", access, nil, http.StatusOK)
+	annUUID := str(me, "uuid")
+	c.json(http.MethodPut, "/api/v1/settings", access, &SettingsPayload{Currency: "USD", Locale: "en"}, http.StatusOK)
+	c.json(http.MethodPut, "/api/v1/settings", access, &SettingsPayload{}, http.StatusBadRequest)
+	c.json(http.MethodGet, "/api/v1/activity", access, nil, http.StatusOK)
+
+	key := c.json(http.MethodPost, "/api/v1/apiKeys/", access, &CreateAPIKeyRequest{Name: "ci"}, http.StatusCreated)
+	c.json(http.MethodGet, "/api/v1/apiKeys/", access, nil, http.StatusOK)
+	c.json(http.MethodDelete, "/api/v1/apiKeys/"+str(key, "uuid"), access, nil, http.StatusOK)
+	c.json(http.MethodDelete, "/api/v1/apiKeys/"+str(key, "uuid"), access, nil, http.StatusNotFound)
+
+	pair = c.json(http.MethodPost, "/api/v1/refresh", "", &RefreshRequest{Refresh: str(pair, "refresh")}, http.StatusOK)
+	pair = c.json(http.MethodPut, "/api/v1/password", str(pair, "access"), &ChangePasswordRequest{Password: "password", NewPassword: "password2"}, http.StatusOK)
+	c.json(http.MethodPost, "/api/v1/logout", "", &RefreshRequest{Refresh: str(pair, "refresh")}, http.StatusOK)
+
+	// a sign in from a new device is reported and the password reset
+	c.signIn("ann@example.com", "password2", "another-browser", "198.51.100.7")
+	notMe := c.linkToken(service.NotMePath)
+	c.page(service.NotMePath+"?token="+url.QueryEscape(notMe), http.StatusOK)
+	c.form(service.NotMePath, url.Values{"token": {notMe}}, http.StatusOK)
+	c.json(http.MethodPost, "/api/v1/signIn", "", &LoginRequest{Email: "ann@example.com", Password: "password2"}, http.StatusUnauthorized)
+
+	reset := c.linkToken(service.PasswordResetPath)
+	c.page(service.PasswordResetPath+"?token="+url.QueryEscape(reset), http.StatusOK)
+	c.form(service.PasswordResetPath, url.Values{"token": {reset}, "new_password": {"password3"}}, http.StatusOK)
+	access, _ = c.signIn("ann@example.com", "password3", "contract-test", "192.0.2.1")
+
+	// admin
+	c.json(http.MethodPost, "/api/v1/signUp", "", &SignUpRequest{Email: "bob@example.com", Password: "password", Name: "Bob"}, http.StatusOK)
+	bob, err := c.store.Repositories().Users.FindByEmail(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.store.Repositories().Roles.AssignToUser(ctx, bob.UUID, role.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	c.json(http.MethodGet, "/api/v1/admin/users", access, nil, http.StatusForbidden)
+	admin, _ := c.signIn("bob@example.com", "password", "contract-test", "192.0.2.1")
+
+	users := "/api/v1/admin/users/" + annUUID
+	c.json(http.MethodGet, "/api/v1/admin/users?email=ann", admin, nil, http.StatusOK)
+	c.json(http.MethodGet, users, admin, nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/admin/users/"+uuid.NewString(), admin, nil, http.StatusNotFound)
+	c.json(http.MethodPut, users+"/settings", admin, &SettingsPayload{Currency: "EUR"}, http.StatusOK)
+	c.json(http.MethodPost, users+"/lock", admin, nil, http.StatusOK)
+	c.json(http.MethodPost, users+"/unlock", admin, nil, http.StatusOK)
+
+	impersonation := c.json(http.MethodPost, users+"/impersonate", admin, &ImpersonateRequest{Reason: "support ticket"}, http.StatusOK)
+	c.json(http.MethodPut, "/api/v1/password", str(impersonation, "access"), &ChangePasswordRequest{Password: "password3", NewPassword: "password4"}, http.StatusForbidden)
+	c.json(http.MethodPost, "/api/v1/impersonation/stop", str(impersonation, "access"), nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/admin/audit?user="+annUUID, admin, nil, http.StatusOK)
+
+	// webhooks get the events relayed after they are created
+	webhook := c.json(http.MethodPost, "/api/v1/admin/webhooks/", admin, &CreateWebhookRequest{URL: "https://partner.example.com/hook", EventTypes: []string{string(event.UserRegistered)}}, http.StatusCreated)
+	webhooks := "/api/v1/admin/webhooks/" + str(webhook, "uuid")
+	if _, err = c.h.app.OutboxRelay.RelayBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.json(http.MethodGet, "/api/v1/admin/webhooks/", admin, nil, http.StatusOK)
+	c.json(http.MethodGet, webhooks, admin, nil, http.StatusOK)
+	c.json(http.MethodPost, webhooks+"/enable", admin, nil, http.StatusOK)
+
+	deliveries := c.json(http.MethodGet, webhooks+"/deliveries", admin, nil, http.StatusOK)
+	list, _ := deliveries["deliveries"].([]interface{})
+	if len(list) == 0 {
+		t.Fatal("no webhook deliveries")
+	}
+	delivery, _ := list[0].(map[string]interface{})
+	c.json(http.MethodPost, "/api/v1/admin/webhooks/deliveries/"+str(delivery, "uuid")+"/replay", admin, nil, http.StatusAccepted)
+	c.json(http.MethodDelete, webhooks, admin, nil, http.StatusOK)
+
+	// internal services
+	created, err := c.h.app.ClientService.CreateClient(ctx, &service.CreateClientRequest{
+		ID:     "billing",
+		Name:   "Billing",
+		Scopes: []string{client.ScopeTokensIntrospect, client.ScopeUsersRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenRequest := httptest.NewRequest(http.MethodPost, "/api/v1/token", strings.NewReader(url.Values{"grant_type": {grantTypeClientCredentials}}.Encode()))
+	tokenRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenRequest.SetBasicAuth("billing", created.Secret)
+	var clientToken ClientTokenResponse
+	if err = json.Unmarshal(c.serve(tokenRequest, http.StatusOK).Body.Bytes(), &clientToken); err != nil {
+		t.Fatal(err)
+	}
+
+	introspection := c.json(http.MethodPost, "/api/v1/internal/introspect", clientToken.AccessToken, &IntrospectRequest{Token: access}, http.StatusOK)
+	if introspection["active"] != true {
+		t.Errorf("introspection = %v, want an active token", introspection)
+	}
+	c.json(http.MethodPost, "/api/v1/internal/users/lookup", clientToken.AccessToken, &LookupUsersRequest{Emails: []string{"ann@example.com", "nobody@example.com"}}, http.StatusOK)
+	c.json(http.MethodPost, "/api/v1/internal/users/lookup", access, &LookupUsersRequest{}, http.StatusForbidden)
+
+	// ends the sessions of the user, last
+	c.json(http.MethodPost, users+"/logout", admin, nil, http.StatusOK)
+	c.json(http.MethodPost, users+"/resetPassword", admin, nil, http.StatusOK)
+	c.json(http.MethodDelete, "/api/v1/me", admin, &DeleteMeRequest{Password: "password"}, http.StatusOK)
+
+	for _, route := range c.h.openAPIRoutes() {
+		if !c.covered[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is not covered", route.Method, route.Path)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package ports
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/go-chi/render"
+)
+
+const (
+	// SessionModeHeader selects the cookie session mode for a request:
+	// "X-Session-Mode: cookie".
+	SessionModeHeader = "X-Session-Mode"
+	sessionModeCookie = "cookie"
+
+	// CsrfHeader must repeat the CSRF cookie on cookie authenticated
+	// mutations.
+	CsrfHeader = "X-CSRF-Token"
+
+	DefaultAccessCookie = "access_token"
+	refreshCookie       = "refresh_token"
+	csrfCookie          = "csrf_token"
+	DefaultCookiePath   = "/api/v1"
+
+	csrfTokenSize = 32
+)
+
+var (
+	errCsrf          = errors.New("csrf token missing or invalid")
+	errRefreshCookie = errors.New("refresh cookie missing")
+)
+
+type CookieConfig struct {
+	// Enabled allows clients to select the cookie session mode.
+	Enabled  bool
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+	// Path scopes the session cookies, it is the API base path as seen by
+	// the browser.
+	Path string
+}
+
+// ParseSameSite converts a COOKIE_SAMESITE value, strict is the default.
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return http.SameSiteDefaultMode, errors.New("unknown SameSite mode " + s)
+	}
+}
+
+type CookieSessionResponse struct {
+	CsrfToken string `json:"csrf_token"`
+}
+
+func (e *CookieSessionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (h *HttpServer) cookieMode(r *http.Request) bool {
+	return h.config.Cookies.Enabled && r.Header.Get(SessionModeHeader) == sessionModeCookie
+}
+
+// respondWithTokens returns the tokens in the body or, in the cookie mode,
+// sets them as HttpOnly cookies and returns the CSRF token instead.
+func (h *HttpServer) respondWithTokens(w http.ResponseWriter, r *http.Request, tokens *service.LoginResponse) {
+	if !h.cookieMode(r) {
+		render.Render(w, r, &TokenPairResponse{
+			Access:  tokens.Access.Token,
+			Refresh: tokens.Refresh.Token,
+		})
+		return
+	}
+
+	csrf, err := newCsrfToken()
+	if err != nil {
+		h.InternalError(apperrors.SlugInternalServerError, err, w, r)
+		return
+	}
+
+	var accessExpires, refreshExpires time.Time
+	if tokens.Access.Claims.ExpiresAt != nil {
+		accessExpires = tokens.Access.Claims.ExpiresAt.Time
+	}
+
+	if tokens.Refresh.Claims.ExpiresAt != nil {
+		refreshExpires = tokens.Refresh.Claims.ExpiresAt.Time
+	}
+
+	http.SetCookie(w, h.cookie(h.config.AccessCookie, tokens.Access.Token, accessExpires, true))
+	for _, path := range h.refreshCookiePaths() {
+		c := h.cookie(refreshCookie, tokens.Refresh.Token, refreshExpires, true)
+		c.Path = path
+		http.SetCookie(w, c)
+	}
+	// the CSRF cookie is read by the client script and sent back in the header
+	http.SetCookie(w, h.cookie(csrfCookie, csrf, refreshExpires, false))
+
+	render.Render(w, r, &CookieSessionResponse{CsrfToken: csrf})
+}
+
+func (h *HttpServer) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{h.config.AccessCookie, refreshCookie, csrfCookie} {
+		c := h.cookie(name, "", time.Time{}, name != csrfCookie)
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+
+	for _, path := range h.refreshCookiePaths() {
+		c := h.cookie(refreshCookie, "", time.Time{}, true)
+		c.Path = path
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+}
+
+// refreshCookiePaths limit the refresh cookie to the endpoints reading it,
+// it is not sent with every API request like the access cookie.
+func (h *HttpServer) refreshCookiePaths() []string {
+	base := strings.TrimSuffix(h.config.Cookies.Path, "/")
+	return []string{base + "/refresh", base + "/logout"}
+}
+
+func (h *HttpServer) cookie(name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     h.config.Cookies.Path,
+		Domain:   h.config.Cookies.Domain,
+		Expires:  expires,
+		Secure:   h.config.Cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: h.config.Cookies.SameSite,
+	}
+}
+
+// refreshFromCookie returns the refresh token cookie of a cookie mode
+// request, the request must pass the CSRF check.
+func (h *HttpServer) refreshFromCookie(r *http.Request) (string, error) {
+	c, err := r.Cookie(refreshCookie)
+	if err != nil || c.Value == "" {
+		return "", errRefreshCookie
+	}
+
+	if err = checkCsrf(r); err != nil {
+		return "", err
+	}
+
+	return c.Value, nil
+}
+
+// checkCsrf compares the CSRF header with the CSRF cookie (double submit).
+// A cross site page can make the browser send the cookie but can't read it.
+func checkCsrf(r *http.Request) error {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return errCsrf
+	}
+
+	header := r.Header.Get(CsrfHeader)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(c.Value)) != 1 {
+		return errCsrf
+	}
+
+	return nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func newCsrfToken() (string, error) {
+	b := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,159 @@
+package ports
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+)
+
+// browser keeps the cookies of a cookie session client.
+type browser struct {
+	t   *testing.T
+	h   *HttpServer
+	jar *cookiejar.Jar
+}
+
+func newBrowser(t *testing.T) *browser {
+	application, _ := newTestApplication(t)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &browser{
+		t:   t,
+		h:   NewHttpServer(application, HttpConfig{Cookies: CookieConfig{Enabled: true}}),
+		jar: jar,
+	}
+}
+
+func (b *browser) url(path string) *url.URL {
+	u, err := url.Parse("http://example.com" + path)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	return u
+}
+
+func (b *browser) cookie(path, name string) string {
+	for _, c := range b.jar.Cookies(b.url(path)) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+// post sends the cookies the browser would send to the path, with the
+// CSRF header when csrf is set.
+func (b *browser) post(path string, body interface{}, csrf bool) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	r.Header.Set(SessionModeHeader, sessionModeCookie)
+	for _, c := range b.jar.Cookies(b.url(path)) {
+		r.AddCookie(c)
+	}
+	if csrf {
+		r.Header.Set(CsrfHeader, b.cookie(path, csrfCookie))
+	}
+
+	w := httptest.NewRecorder()
+	b.h.router.ServeHTTP(w, r)
+	b.jar.SetCookies(b.url(path), w.Result().Cookies())
+	return w
+}
+
+func problemSlug(t *testing.T, w *httptest.ResponseRecorder) string {
+	var problem struct {
+		Slug string `json:"slug"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+
+	return problem.Slug
+}
+
+func TestRefreshCookieIsScopedToRefreshAndLogout(t *testing.T) {
+	b := newBrowser(t)
+
+	w := b.post("/api/v1/signUp", &SignUpRequest{Email: "ann@example.com", Password: "password", Name: "Ann"}, false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sign up: %d %s", w.Code, w.Body.String())
+	}
+
+	paths := map[string]bool{}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == refreshCookie {
+			paths[c.Path] = true
+			if !c.HttpOnly {
+				t.Errorf("refresh cookie for %s is not HttpOnly", c.Path)
+			}
+		}
+	}
+	if len(paths) != 2 || !paths["/api/v1/refresh"] || !paths["/api/v1/logout"] {
+		t.Errorf("refresh cookie paths = %v, want /api/v1/refresh and /api/v1/logout", paths)
+	}
+
+	for _, path := range []string{"/api/v1/me", "/api/v1/settings", "/api/v1/admin/users"} {
+		if b.cookie(path, refreshCookie) != "" {
+			t.Errorf("refresh cookie is sent to %s", path)
+		}
+		if b.cookie(path, DefaultAccessCookie) == "" {
+			t.Errorf("access cookie is not sent to %s", path)
+		}
+	}
+
+	if w = b.post("/api/v1/refresh", nil, true); w.Code != http.StatusOK {
+		t.Fatalf("refresh: %d %s", w.Code, w.Body.String())
+	}
+
+	if w = b.post("/api/v1/logout", nil, true); w.Code != http.StatusOK {
+		t.Fatalf("logout: %d %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/v1/refresh", "/api/v1/logout"} {
+		if b.cookie(path, refreshCookie) != "" {
+			t.Errorf("refresh cookie for %s is not cleared on logout", path)
+		}
+	}
+}
+
+func TestRefreshCookieErrors(t *testing.T) {
+	b := newBrowser(t)
+	if w := b.post("/api/v1/signUp", &SignUpRequest{Email: "ann@example.com", Password: "password", Name: "Ann"}, false); w.Code != http.StatusOK {
+		t.Fatalf("sign up: %d %s", w.Code, w.Body.String())
+	}
+
+	w := b.post("/api/v1/refresh", nil, false)
+	if w.Code != http.StatusForbidden || problemSlug(t, w) != apperrors.SlugCsrfTokenInvalid {
+		t.Errorf("without the CSRF header: %d %s", w.Code, w.Body.String())
+	}
+
+	// the session cookies expired or were cleared, only the CSRF cookie is left
+	csrf := b.cookie("/api/v1/refresh", csrfCookie)
+	for _, path := range []string{"/api/v1/refresh", "/api/v1/logout"} {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.Header.Set(SessionModeHeader, sessionModeCookie)
+		r.Header.Set(CsrfHeader, csrf)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrf})
+
+		w = httptest.NewRecorder()
+		b.h.router.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || problemSlug(t, w) != apperrors.SlugInvalidToken {
+			t.Errorf("%s without the refresh cookie: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ports
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bysoft-wallet/users/pkg/dpop"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+const DefaultCorsMaxAge = 300
+
+var defaultCorsMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+type CorsConfig struct {
+	// AllowedOrigins enables CORS when not empty, an origin may contain one
+	// wildcard, e.g. https://*.bysoft.ru.
+	AllowedOrigins []string
+	// AllowedMethods and AllowedHeaders replace the defaults when set.
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, browsers may cache a preflight.
+	MaxAge int
+}
+
+// corsHandler answers preflight requests for every route and adds CORS
+// headers to allowed cross origin requests, it returns nil when CORS is not
+// configured.
+func (h *HttpServer) corsHandler() func(http.Handler) http.Handler {
+	config := h.config.Cors
+	if len(config.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCorsMethods
+	}
+
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = h.defaultCorsHeaders()
+	}
+
+	maxAge := config.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultCorsMaxAge
+	}
+
+	return cors.Handler(cors.Options{
+		AllowedOrigins:   config.AllowedOrigins,
+		AllowedMethods:   methods,
+		AllowedHeaders:   headers,
+		ExposedHeaders:   []string{dpop.NonceHeader, middleware.RequestIDHeader},
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           maxAge,
+	})
+}
+
+// defaultCorsHeaders are the request headers the API reads.
+func (h *HttpServer) defaultCorsHeaders() []string {
+	headers := []string{
+		"Accept",
+		"Authorization",
+		"Content-Type",
+		CsrfHeader,
+		SessionModeHeader,
+		DeviceIdHeader,
+		dpop.Header,
+		middleware.RequestIDHeader,
+	}
+
+	if h.config.AccessHeader != "" {
+		headers = append(headers, h.config.AccessHeader)
+	}
+
+	return headers
+}
+
+// SplitList parses a comma separated configuration value.
+func SplitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
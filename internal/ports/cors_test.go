@@ -0,0 +1,148 @@
+package ports
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bysoft-wallet/users/pkg/dpop"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const allowedOrigin = "https://app.bysoft.ru"
+
+func newCorsServer(t *testing.T, config CorsConfig) *HttpServer {
+	application, _ := newTestApplication(t)
+	return NewHttpServer(application, HttpConfig{Cors: config})
+}
+
+func corsRequest(h *HttpServer, method, path, origin string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, r)
+	return w
+}
+
+func hasToken(list, token string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestCorsAllowsOriginWithCredentials(t *testing.T) {
+	h := newCorsServer(t, CorsConfig{AllowedOrigins: []string{"https://*.bysoft.ru"}, AllowCredentials: true})
+
+	w := corsRequest(h, http.MethodGet, "/api/v1/health", allowedOrigin, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != allowedOrigin {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+	if got := w.Header().Values("Vary"); !hasToken(strings.Join(got, ","), "Origin") {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+
+	exposed := w.Header().Get("Access-Control-Expose-Headers")
+	for _, header := range []string{dpop.NonceHeader, middleware.RequestIDHeader} {
+		if !hasToken(exposed, header) {
+			t.Errorf("Access-Control-Expose-Headers = %q, want %s", exposed, header)
+		}
+	}
+}
+
+func TestCorsIgnoresDisallowedOrigin(t *testing.T) {
+	h := newCorsServer(t, CorsConfig{AllowedOrigins: []string{"https://*.bysoft.ru"}, AllowCredentials: true})
+
+	for _, origin := range []string{"https://evil.example.com", "https://bysoft.ru.evil.example.com", "http://app.bysoft.ru"} {
+		w := corsRequest(h, http.MethodGet, "/api/v1/health", origin, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d", origin, w.Code)
+		}
+
+		for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials"} {
+			if got := w.Header().Get(header); got != "" {
+				t.Errorf("%s: %s = %q, want none", origin, header, got)
+			}
+		}
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	h := newCorsServer(t, CorsConfig{AllowedOrigins: []string{allowedOrigin}, AllowCredentials: true, MaxAge: 600})
+	preflight := http.Header{
+		"Access-Control-Request-Method":  {http.MethodPut},
+		"Access-Control-Request-Headers": {"authorization,dpop,x-csrf-token"},
+	}
+
+	// the route only accepts GET and DELETE, preflights are answered anyway
+	w := corsRequest(h, http.MethodOptions, "/api/v1/me", allowedOrigin, preflight)
+	if w.Code != http.StatusOK && w.Code != http.StatusNoContent {
+		t.Fatalf("status %d", w.Code)
+	}
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != allowedOrigin {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); !hasToken(got, http.MethodPut) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want PUT", got)
+	}
+
+	allowed := w.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{"Authorization", dpop.Header, CsrfHeader} {
+		if !hasToken(allowed, header) {
+			t.Errorf("Access-Control-Allow-Headers = %q, want %s", allowed, header)
+		}
+	}
+
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+
+	// a preflight from another origin is not allowed
+	w = corsRequest(h, http.MethodOptions, "/api/v1/me", "https://evil.example.com", preflight)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin: Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestCorsPreflightDefaultMaxAge(t *testing.T) {
+	h := newCorsServer(t, CorsConfig{AllowedOrigins: []string{allowedOrigin}})
+
+	w := corsRequest(h, http.MethodOptions, "/api/v1/signIn", allowedOrigin, http.Header{"Access-Control-Request-Method": {http.MethodPost}})
+	if got := w.Header().Get("Access-Control-Max-Age"); got != strconv.Itoa(DefaultCorsMaxAge) {
+		t.Errorf("Access-Control-Max-Age = %q, want %d", got, DefaultCorsMaxAge)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q without AllowCredentials", got)
+	}
+}
+
+func TestCorsDisabled(t *testing.T) {
+	h := newCorsServer(t, CorsConfig{})
+
+	w := corsRequest(h, http.MethodGet, "/api/v1/health", allowedOrigin, nil)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q without allowed origins", got)
+	}
+}
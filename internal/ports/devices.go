@@ -0,0 +1,82 @@
+package ports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type NotMeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// notMePage is opened from the new device email. It only asks to confirm,
+// mail scanners and link prefetchers open links without acting on them.
+func (h *HttpServer) notMePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.renderErrorPage(apperrors.NewIncorrectInputError("token is required", apperrors.SlugFieldTokenRequired), w, r)
+		return
+	}
+
+	h.renderPage(w, r, http.StatusOK, i18n.PageNotMe, &i18n.PageData{Action: service.NotMePath, Token: token})
+}
+
+// notMe reports the new device, the token is the only credential so the
+// route is public. Forms posted by notMePage get a page back.
+func (h *HttpServer) notMe(w http.ResponseWriter, r *http.Request) {
+	var request NotMeRequest
+	if isForm(r) {
+		request.Token = r.PostFormValue("token")
+	} else {
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &request); err != nil {
+				h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+				return
+			}
+		}
+	}
+
+	// clients posting the email link as is
+	if request.Token == "" {
+		request.Token = r.URL.Query().Get("token")
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		if isForm(r) {
+			h.renderErrorPage(apperrors.NewIncorrectInputError("token is required", apperrors.SlugFieldTokenRequired), w, r)
+			return
+		}
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	if err := h.app.DeviceService.NotMe(r.Context(), request.Token, acceptLanguage(r)); err != nil {
+		if isForm(r) {
+			h.renderErrorPage(err, w, r)
+			return
+		}
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	if isForm(r) {
+		h.renderPage(w, r, http.StatusOK, i18n.PageNotMeDone, &i18n.PageData{})
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
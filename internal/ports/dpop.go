@@ -0,0 +1,93 @@
+package ports
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/pkg/dpop"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+)
+
+// dpopThumbprint verifies the optional DPoP proof of a token request and
+// returns the thumbprint of its key, empty when no proof is sent.
+func (h *HttpServer) dpopThumbprint(w http.ResponseWriter, r *http.Request) (string, error) {
+	if r.Header.Get(dpop.Header) == "" {
+		return "", nil
+	}
+
+	proof, err := h.verifyDPoP(w, r, "")
+	if err != nil {
+		return "", err
+	}
+
+	return proof.Thumbprint, nil
+}
+
+// checkAccessProof makes sure a DPoP bound access token is presented with
+// the DPoP scheme and a proof made with its key.
+func (h *HttpServer) checkAccessProof(w http.ResponseWriter, r *http.Request, scheme, token string, access *jwt.AccessJWT) error {
+	jkt := access.Claims.Thumbprint()
+	if jkt == "" {
+		if scheme == dpop.Scheme {
+			return apperrors.NewAuthorizationError("Token is not DPoP bound", apperrors.SlugInvalidToken)
+		}
+
+		return nil
+	}
+
+	if scheme != dpop.Scheme {
+		return apperrors.NewAuthorizationError("DPoP bound token sent as bearer token", apperrors.SlugInvalidToken)
+	}
+
+	proof, err := h.verifyDPoP(w, r, token)
+	if err != nil {
+		return err
+	}
+
+	if proof.Thumbprint != jkt {
+		return apperrors.NewAuthorizationError("DPoP proof key does not match the token", apperrors.SlugInvalidDPoPProof)
+	}
+
+	return nil
+}
+
+func (h *HttpServer) verifyDPoP(w http.ResponseWriter, r *http.Request, accessToken string) (*dpop.Proof, error) {
+	verifier := h.app.DPoPVerifier
+	if verifier.RequiresNonce() {
+		w.Header().Set(dpop.NonceHeader, verifier.Nonce())
+	}
+
+	proof, err := verifier.Verify(r.Header.Get(dpop.Header), r.Method, h.requestURL(r), accessToken)
+	if err != nil {
+		if errors.Is(err, dpop.ErrUseNonce) {
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			return nil, apperrors.NewAuthorizationError(err.Error(), apperrors.SlugUseDPoPNonce)
+		}
+
+		return nil, apperrors.NewAuthorizationError(err.Error(), apperrors.SlugInvalidDPoPProof)
+	}
+
+	return proof, nil
+}
+
+// requestURL is the URL the client called, proofs are made for it. Behind a
+// proxy that rewrites paths PUBLIC_URL must be set, X-Forwarded-Proto is only
+// read from trusted proxies.
+func (h *HttpServer) requestURL(r *http.Request) string {
+	if h.app.PublicURL != "" {
+		return strings.TrimRight(h.app.PublicURL, "/") + r.URL.Path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" && isProxied(r) {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host + r.URL.Path
+}
@@ -0,0 +1,208 @@
+package ports
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/pkg/dpop"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// dpopKey is the key a client binds its tokens to.
+type dpopKey struct {
+	t   *testing.T
+	key *ecdsa.PrivateKey
+}
+
+func newDPoPKey(t *testing.T) *dpopKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &dpopKey{t: t, key: key}
+}
+
+// proof signs a proof for the request to the path, for the access token when
+// it is not empty.
+func (k *dpopKey) proof(method, path, access, nonce string) string {
+	claims := dpop.ProofClaims{
+		Method: method,
+		URL:    contractPublicURL + path,
+		Nonce:  nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       uuid.NewString(),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if access != "" {
+		claims.AccessTokenHash = dpop.AccessTokenHash(access)
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	t.Header["typ"] = "dpop+jwt"
+	t.Header["jwk"] = &dpop.JWK{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(k.key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(k.key.Y.FillBytes(make([]byte, 32))),
+	}
+
+	proof, err := t.SignedString(k.key)
+	if err != nil {
+		k.t.Fatal(err)
+	}
+
+	return proof
+}
+
+// dpopRequest sends body with the proof and the access token in the
+// authorization scheme when they are not empty.
+func (c *contract) dpopRequest(method, path, scheme, access, proof string, body interface{}, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	r := httptest.NewRequest(method, path, bytes.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	if access != "" {
+		r.Header.Set("Authorization", scheme+" "+access)
+	}
+	if proof != "" {
+		r.Header.Set(dpop.Header, proof)
+	}
+
+	return c.serve(r, status)
+}
+
+func (c *contract) dpopSignUp(key *dpopKey, email string) TokenPairResponse {
+	c.t.Helper()
+
+	w := c.dpopRequest(http.MethodPost, "/api/v1/signUp", "", "", key.proof(http.MethodPost, "/api/v1/signUp", "", ""), &SignUpRequest{Email: email, Password: "password", Name: email}, http.StatusOK)
+
+	var pair TokenPairResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &pair); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return pair
+}
+
+func expectSlug(t *testing.T, name string, w *httptest.ResponseRecorder, slug string) {
+	t.Helper()
+
+	if got := problemSlug(t, w); got != slug {
+		t.Errorf("%s: slug %s, want %s", name, got, slug)
+	}
+}
+
+func TestDPoPBoundAccessToken(t *testing.T) {
+	c := newContract(t)
+	key := newDPoPKey(t)
+	pair := c.dpopSignUp(key, "ann@example.com")
+
+	me := "/api/v1/me"
+	w := c.dpopRequest(http.MethodGet, me, "Bearer", pair.Access, "", nil, http.StatusUnauthorized)
+	expectSlug(t, "bearer", w, apperrors.SlugInvalidToken)
+
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, "", nil, http.StatusUnauthorized)
+	expectSlug(t, "no proof", w, apperrors.SlugInvalidDPoPProof)
+
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, newDPoPKey(t).proof(http.MethodGet, me, pair.Access, ""), nil, http.StatusUnauthorized)
+	expectSlug(t, "cnf.jkt mismatch", w, apperrors.SlugInvalidDPoPProof)
+
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, key.proof(http.MethodGet, me, "", ""), nil, http.StatusUnauthorized)
+	expectSlug(t, "ath missing", w, apperrors.SlugInvalidDPoPProof)
+
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, key.proof(http.MethodPost, me, pair.Access, ""), nil, http.StatusUnauthorized)
+	expectSlug(t, "htm mismatch", w, apperrors.SlugInvalidDPoPProof)
+
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, key.proof(http.MethodGet, "/api/v1/activity", pair.Access, ""), nil, http.StatusUnauthorized)
+	expectSlug(t, "htu mismatch", w, apperrors.SlugInvalidDPoPProof)
+
+	proof := key.proof(http.MethodGet, me, pair.Access, "")
+	c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, proof, nil, http.StatusOK)
+	w = c.dpopRequest(http.MethodGet, me, dpop.Scheme, pair.Access, proof, nil, http.StatusUnauthorized)
+	expectSlug(t, "replay", w, apperrors.SlugInvalidDPoPProof)
+}
+
+func TestDPoPBoundRefreshToken(t *testing.T) {
+	c := newContract(t)
+	key := newDPoPKey(t)
+	pair := c.dpopSignUp(key, "ann@example.com")
+
+	refresh := "/api/v1/refresh"
+	body := &RefreshRequest{Refresh: pair.Refresh}
+	w := c.dpopRequest(http.MethodPost, refresh, "", "", "", body, http.StatusUnauthorized)
+	expectSlug(t, "no proof", w, apperrors.SlugInvalidDPoPProof)
+
+	w = c.dpopRequest(http.MethodPost, refresh, "", "", newDPoPKey(t).proof(http.MethodPost, refresh, "", ""), body, http.StatusUnauthorized)
+	expectSlug(t, "cnf.jkt mismatch", w, apperrors.SlugInvalidDPoPProof)
+
+	c.dpopRequest(http.MethodPost, refresh, "", "", key.proof(http.MethodPost, refresh, "", ""), body, http.StatusOK)
+}
+
+func TestDPoPNonce(t *testing.T) {
+	store := newMemoryStore()
+	c := newContractWith(t, store, store.Repositories(), app.Config{DPoPRequireNonce: true})
+	key := newDPoPKey(t)
+
+	signUp := "/api/v1/signUp"
+	body := &SignUpRequest{Email: "ann@example.com", Password: "password", Name: "Ann"}
+	w := c.dpopRequest(http.MethodPost, signUp, "", "", key.proof(http.MethodPost, signUp, "", ""), body, http.StatusUnauthorized)
+	expectSlug(t, "no nonce", w, apperrors.SlugUseDPoPNonce)
+	if got := w.Header().Get("WWW-Authenticate"); got != `DPoP error="use_dpop_nonce"` {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+
+	nonce := w.Header().Get(dpop.NonceHeader)
+	if nonce == "" {
+		t.Fatal("no nonce is issued")
+	}
+
+	w = c.dpopRequest(http.MethodPost, signUp, "", "", key.proof(http.MethodPost, signUp, "", "not a nonce"), body, http.StatusUnauthorized)
+	expectSlug(t, "wrong nonce", w, apperrors.SlugUseDPoPNonce)
+
+	w = c.dpopRequest(http.MethodPost, signUp, "", "", key.proof(http.MethodPost, signUp, "", nonce), body, http.StatusOK)
+	if w.Header().Get(dpop.NonceHeader) == "" {
+		t.Error("no next nonce is issued")
+	}
+}
+
+func TestRequestURLTrustsForwardedProtoOfProxies(t *testing.T) {
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	h := &HttpServer{app: &app.Application{}, config: HttpConfig{TrustedProxies: proxies}}
+
+	for remoteAddr, want := range map[string]string{
+		"198.51.100.7:1234": "http://users.example.com/api/v1/me",
+		"10.0.0.2:1234":     "https://users.example.com/api/v1/me",
+	} {
+		var got string
+		handler := h.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = h.requestURL(r)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "http://users.example.com/api/v1/me", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != want {
+			t.Errorf("request from %s: URL %s, want %s", remoteAddr, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package ports
+
+import (
+	"net/http"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/go-chi/render"
+)
+
+type ErrorDefinitionResponse struct {
+	Slug        string `json:"slug"`
+	Type        string `json:"type"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+	// Message is what clients may show to users, in the request language.
+	Message string `json:"message"`
+}
+
+type ErrorCatalogResponse struct {
+	Errors []ErrorDefinitionResponse `json:"errors"`
+}
+
+func (e *ErrorCatalogResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (h *HttpServer) errorCatalog(w http.ResponseWriter, r *http.Request) {
+	l := locale(r)
+	w.Header().Set("Content-Language", string(l))
+
+	catalog := apperrors.Catalog()
+	response := ErrorCatalogResponse{Errors: make([]ErrorDefinitionResponse, 0, len(catalog))}
+	for _, d := range catalog {
+		message, _ := i18n.Message(l, d.Slug)
+		response.Errors = append(response.Errors, ErrorDefinitionResponse{
+			Slug:        d.Slug,
+			Type:        d.Type.String(),
+			Status:      d.Status(),
+			Description: d.Description,
+			Message:     message,
+		})
+	}
+
+	render.Render(w, r, &response)
+}
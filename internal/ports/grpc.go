@@ -0,0 +1,533 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/client"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	usersv1 "github.com/bysoft-wallet/users/pkg/api/users/v1"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const DEFAULT_GRPC_PORT = "9088"
+
+const (
+	grpcMethodPrefix   = "/users.v1.UsersService/"
+	grpcRequestIdKey   = "x-request-id"
+	grpcDeviceIdKey    = "x-device-id"
+	grpcErrorDomain    = "users.bysoft.ru"
+	grpcAuthorization  = "authorization"
+	grpcBearerPrefix   = "Bearer "
+	grpcPolicyPublic   = "public"
+	grpcPolicyUser     = "user"
+	grpcPolicyService  = "service"
+	grpcPolicyAnyToken = "any"
+)
+
+// grpcPolicies tells which credentials every method accepts.
+var grpcPolicies = map[string]string{
+	grpcMethodPrefix + "SignIn":         grpcPolicyPublic,
+	grpcMethodPrefix + "SignUp":         grpcPolicyPublic,
+	grpcMethodPrefix + "Refresh":        grpcPolicyPublic,
+	grpcMethodPrefix + "GetUser":        grpcPolicyAnyToken,
+	grpcMethodPrefix + "UpdateSettings": grpcPolicyUser,
+	grpcMethodPrefix + "ValidateAccess": grpcPolicyService,
+	grpcMethodPrefix + "LookupUsers":    grpcPolicyService,
+}
+
+type GrpcServer struct {
+	usersv1.UnimplementedUsersServiceServer
+	app       *app.Application
+	validator *validator.Validate
+	server    *grpc.Server
+	// port is the listening port, DEFAULT_GRPC_PORT when empty.
+	port string
+}
+
+func NewGrpcServer(app *app.Application, port string) *GrpcServer {
+	h := &GrpcServer{
+		app:       app,
+		validator: validator.New(),
+		port:      port,
+	}
+
+	h.server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		h.requestIdInterceptor,
+		h.loggingInterceptor,
+		h.localeInterceptor,
+		h.recoveryInterceptor,
+		h.errorInterceptor,
+		h.authInterceptor,
+	))
+	usersv1.RegisterUsersServiceServer(h.server, h)
+
+	return h
+}
+
+func (h *GrpcServer) Start() error {
+	port := DEFAULT_GRPC_PORT
+	if h.port != "" {
+		port = h.port
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return err
+	}
+
+	return h.server.Serve(listener)
+}
+
+// Shutdown stops accepting calls and waits for the running ones, they are
+// cancelled when ctx is done.
+func (h *GrpcServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		h.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		h.server.Stop()
+		return ctx.Err()
+	}
+}
+
+func (h *GrpcServer) requestIdInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	requestId := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcRequestIdKey); len(values) > 0 {
+			requestId = values[0]
+		}
+	}
+
+	if requestId == "" {
+		requestId = uuid.New().String()
+	}
+
+	userAgent := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			userAgent = truncate(values[0], maxUserAgentLength)
+		}
+	}
+
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, requestId)
+	ctx = audit.WithMeta(ctx, audit.Meta{
+		Ip:        grpcPeerIp(ctx),
+		UserAgent: userAgent,
+		RequestId: requestId,
+	})
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIdKey, requestId))
+
+	return handler(ctx, req)
+}
+
+func (h *GrpcServer) loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	h.app.Logger.WithFields(logrus.Fields{
+		"method":     info.FullMethod,
+		"code":       status.Code(err).String(),
+		"duration":   time.Since(start).String(),
+		"request_id": middleware.GetReqID(ctx),
+		"peer":       grpcPeerIp(ctx),
+	}).Info("gRPC request")
+
+	return resp, err
+}
+
+func (h *GrpcServer) recoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			h.app.Logger.Errorf("gRPC panic in %s: %v", info.FullMethod, p)
+			err = grpcStatus(codes.Internal, apperrors.SlugInternalServerError)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// errorInterceptor maps AppError types to gRPC codes the way
+// HttpServer.RespondWithAppError maps them to HTTP statuses.
+func (h *GrpcServer) errorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return resp, err
+	}
+
+	h.app.Logger.Debug(map[string]string{
+		"error-type": "gRPC Request Error",
+		"method":     info.FullMethod,
+		"error":      err.Error(),
+	})
+
+	appError, ok := apperrors.As(err)
+	if !ok {
+		return resp, grpcStatus(codes.Internal, apperrors.SlugInternalServerError)
+	}
+
+	switch appError.ErrorType() {
+	case apperrors.ErrorTypeAuthorization:
+		return resp, grpcStatus(codes.Unauthenticated, appError.Slug())
+	case apperrors.ErrorTypeForbidden:
+		return resp, grpcStatus(codes.PermissionDenied, appError.Slug())
+	case apperrors.ErrorTypeIncorrectInput:
+		return resp, grpcStatus(codes.InvalidArgument, appError.Slug())
+	case apperrors.ErrorNotFound:
+		return resp, grpcStatus(codes.NotFound, appError.Slug())
+	case apperrors.ErrorTypeConflict:
+		return resp, grpcStatus(codes.AlreadyExists, appError.Slug())
+	case apperrors.ErrorTypeRateLimited:
+		return resp, grpcStatus(codes.ResourceExhausted, appError.Slug())
+	case apperrors.ErrorTypeUnavailable:
+		return resp, grpcStatus(codes.Unavailable, appError.Slug())
+	default:
+		return resp, grpcStatus(codes.Internal, appError.Slug())
+	}
+}
+
+func (h *GrpcServer) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	policy, ok := grpcPolicies[info.FullMethod]
+	if !ok {
+		return nil, grpcStatus(codes.Unimplemented, apperrors.SlugMethodNotFound)
+	}
+
+	if policy == grpcPolicyPublic {
+		return handler(ctx, req)
+	}
+
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcAuthorization); len(values) > 0 {
+			token = strings.TrimPrefix(values[0], grpcBearerPrefix)
+		}
+	}
+
+	if token == "" {
+		return nil, grpcStatus(codes.Unauthenticated, apperrors.SlugInvalidToken)
+	}
+
+	access, err := h.app.AccessService.ValidateAccess(ctx, token)
+	if err != nil {
+		return nil, grpcStatus(codes.Unauthenticated, apperrors.SlugInvalidToken)
+	}
+
+	// DPoP proofs are bound to HTTP requests, bound tokens are only
+	// accepted by the HTTP API
+	if access.Claims.Thumbprint() != "" {
+		return nil, grpcStatus(codes.Unauthenticated, apperrors.SlugDPoPNotSupported)
+	}
+
+	if policy == grpcPolicyUser && access.Claims.IsService() {
+		return nil, grpcStatus(codes.PermissionDenied, apperrors.SlugUserTokenRequired)
+	}
+
+	if policy == grpcPolicyService && !access.Claims.IsService() {
+		return nil, grpcStatus(codes.PermissionDenied, apperrors.SlugServiceTokenRequired)
+	}
+
+	ctx = context.WithValue(ctx, accessContextKey{}, access)
+	if !access.Claims.IsService() {
+		ctx = audit.WithActor(ctx, auditActor(&access.Claims))
+	}
+
+	return handler(ctx, req)
+}
+
+func grpcStatus(code codes.Code, slug string) error {
+	st := status.New(code, slug)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: slug, Domain: grpcErrorDomain}); err == nil {
+		st = detailed
+	}
+
+	return st.Err()
+}
+
+func grpcAccess(ctx context.Context) *jwt.AccessJWT {
+	access, _ := ctx.Value(accessContextKey{}).(*jwt.AccessJWT)
+	return access
+}
+
+func grpcRequireScope(access *jwt.AccessJWT, scope string) error {
+	if !access.Claims.HasScope(scope) {
+		return grpcStatus(codes.PermissionDenied, apperrors.SlugPermissionDenied)
+	}
+
+	return nil
+}
+
+func grpcPeerIp(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+func grpcDeviceId(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcDeviceIdKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+func (h *GrpcServer) validate(s interface{}) error {
+	err := h.validator.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	if errs, ok := err.(validator.ValidationErrors); ok && len(errs) > 0 {
+		return grpcStatus(codes.InvalidArgument, validationSlug(errs[0]))
+	}
+
+	return grpcStatus(codes.InvalidArgument, apperrors.SlugInvalidInput)
+}
+
+func grpcUser(u *user.User) *usersv1.User {
+	return &usersv1.User{
+		Uuid:  u.UUID.String(),
+		Email: u.Email,
+		Name:  u.Name,
+		Settings: &usersv1.Settings{
+			Currency: u.Settings.Currency.String(),
+			Locale:   string(u.Settings.Locale),
+		},
+	}
+}
+
+func grpcTokenPair(tokens *service.LoginResponse) *usersv1.TokenPair {
+	return &usersv1.TokenPair{
+		Access:  tokens.Access.Token,
+		Refresh: tokens.Refresh.Token,
+	}
+}
+
+func (h *GrpcServer) SignIn(ctx context.Context, req *usersv1.SignInRequest) (*usersv1.TokenPair, error) {
+	tokens, err := h.app.AuthService.SignIn(ctx, &service.SignInRequest{
+		Email:         req.GetEmail(),
+		Password:      req.GetPassword(),
+		Ip:            grpcPeerIp(ctx),
+		UserAgent:     audit.MetaFrom(ctx).UserAgent,
+		DeviceId:      grpcDeviceId(ctx),
+		Locale:        grpcAcceptLanguage(ctx),
+		EvictSessions: req.GetEvictSessions(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcTokenPair(tokens), nil
+}
+
+func (h *GrpcServer) SignUp(ctx context.Context, req *usersv1.SignUpRequest) (*usersv1.TokenPair, error) {
+	request := SignUpRequest{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+		Name:     req.GetName(),
+	}
+	if err := h.validate(request); err != nil {
+		return nil, err
+	}
+
+	tokens, err := h.app.AuthService.SignUp(ctx, &service.SignUpRequest{
+		Email:     request.Email,
+		Password:  request.Password,
+		Name:      request.Name,
+		Ip:        grpcPeerIp(ctx),
+		UserAgent: audit.MetaFrom(ctx).UserAgent,
+		DeviceId:  grpcDeviceId(ctx),
+		Locale:    grpcAcceptLanguage(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcTokenPair(tokens), nil
+}
+
+func (h *GrpcServer) Refresh(ctx context.Context, req *usersv1.RefreshRequest) (*usersv1.TokenPair, error) {
+	if err := h.validate(RefreshRequest{Refresh: req.GetRefresh()}); err != nil {
+		return nil, err
+	}
+
+	tokens, err := h.app.AuthService.Refresh(ctx, &service.RefreshRequest{
+		Token:    req.GetRefresh(),
+		Ip:       grpcPeerIp(ctx),
+		DeviceId: grpcDeviceId(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcTokenPair(tokens), nil
+}
+
+func (h *GrpcServer) GetUser(ctx context.Context, req *usersv1.GetUserRequest) (*usersv1.User, error) {
+	access := grpcAccess(ctx)
+
+	userUUID := access.Claims.UserId
+	if access.Claims.IsService() {
+		if err := grpcRequireScope(access, client.ScopeUsersRead); err != nil {
+			return nil, err
+		}
+
+		id, err := uuid.Parse(req.GetUuid())
+		if err != nil {
+			return nil, grpcStatus(codes.InvalidArgument, apperrors.SlugFieldUUIDInvalid)
+		}
+		userUUID = id
+	} else {
+		if err := grpcRequireScope(access, role.PermissionProfileRead); err != nil {
+			return nil, err
+		}
+
+		if req.GetUuid() != "" && req.GetUuid() != userUUID.String() {
+			return nil, grpcStatus(codes.PermissionDenied, apperrors.SlugPermissionDenied)
+		}
+	}
+
+	u, err := h.app.AuthService.GetUser(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcUser(u), nil
+}
+
+func (h *GrpcServer) UpdateSettings(ctx context.Context, req *usersv1.UpdateSettingsRequest) (*usersv1.User, error) {
+	access := grpcAccess(ctx)
+	if err := grpcRequireScope(access, role.PermissionProfileWrite); err != nil {
+		return nil, err
+	}
+
+	if err := h.validate(SettingsPayload{Currency: req.GetCurrency()}); err != nil {
+		return nil, err
+	}
+
+	u, err := h.app.AuthService.UpdateSettings(ctx, &service.UpdateSettingsRequest{
+		UserUUID: access.Claims.UserId,
+		Currency: req.GetCurrency(),
+		Locale:   req.GetLocale(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcUser(u), nil
+}
+
+func (h *GrpcServer) ValidateAccess(ctx context.Context, req *usersv1.ValidateAccessRequest) (*usersv1.ValidateAccessResponse, error) {
+	if err := grpcRequireScope(grpcAccess(ctx), client.ScopeTokensIntrospect); err != nil {
+		return nil, err
+	}
+
+	access, err := h.app.AccessService.ValidateAccess(ctx, req.GetToken())
+	if err != nil {
+		return &usersv1.ValidateAccessResponse{Active: false}, nil
+	}
+
+	response := &usersv1.ValidateAccessResponse{
+		Active:   true,
+		ClientId: access.Claims.ClientId,
+		Scope:    access.Claims.Scope,
+		Roles:    access.Claims.Roles,
+		Jkt:      access.Claims.Thumbprint(),
+	}
+
+	if !access.Claims.IsService() {
+		response.UserUuid = access.Claims.UserId.String()
+	}
+
+	if access.Claims.IsImpersonated() {
+		response.ActorUuid = access.Claims.Act.Subject
+	}
+
+	if access.Claims.ExpiresAt != nil {
+		response.ExpiresAt = timestamppb.New(access.Claims.ExpiresAt.Time)
+	}
+
+	return response, nil
+}
+
+func (h *GrpcServer) LookupUsers(ctx context.Context, req *usersv1.LookupUsersRequest) (*usersv1.LookupUsersResponse, error) {
+	if err := grpcRequireScope(grpcAccess(ctx), client.ScopeUsersRead); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.GetUuids()))
+	for _, v := range req.GetUuids() {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, grpcStatus(codes.InvalidArgument, apperrors.SlugFieldUUIDInvalid)
+		}
+		ids = append(ids, id)
+	}
+
+	result, err := h.app.LookupService.Lookup(ctx, &service.LookupRequest{
+		UUIDs:  ids,
+		Emails: req.GetEmails(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &usersv1.LookupUsersResponse{
+		Users:         map[string]*usersv1.User{},
+		Emails:        map[string]*usersv1.User{},
+		MissingUuids:  make([]string, 0, len(result.MissingUUIDs)),
+		MissingEmails: result.MissingEmails,
+	}
+
+	for id, u := range result.ByUUID {
+		response.Users[id.String()] = grpcUser(u)
+	}
+
+	for email, u := range result.ByEmail {
+		response.Emails[email] = grpcUser(u)
+	}
+
+	for _, id := range result.MissingUUIDs {
+		response.MissingUuids = append(response.MissingUuids, id.String())
+	}
+
+	return response, nil
+}
@@ -1,18 +1,22 @@
 package ports
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/bysoft-wallet/users/internal/app"
 	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/role"
 	"github.com/bysoft-wallet/users/internal/app/service"
-	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/pkg/openapi"
 	chilogger "github.com/chi-middleware/logrus-logger"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -23,28 +27,89 @@ import (
 )
 
 type HttpServer struct {
-	app          *app.Application
-	accessHeader string
-	validator    *validator.Validate
-	logger       *logrus.Logger
+	app       *app.Application
+	config    HttpConfig
+	validator *validator.Validate
+	logger    *logrus.Logger
+	router    *chi.Mux
+	// openAPIDoc describes the routes of router.
+	openAPIDoc *openapi.Document
+	server     *http.Server
+}
+
+type HttpConfig struct {
+	// Port is the listening port, DEFAULT_PORT when empty.
+	Port string
+	// AccessHeader is a header carrying the raw access token, e.g. X-API-Token.
+	AccessHeader string
+	// AccessCookie is a cookie carrying the access token, it is not read
+	// when empty.
+	AccessCookie string
+	Cookies      CookieConfig
+	Cors         CorsConfig
+	// TrustedProxies are the peers whose X-Forwarded-For, X-Real-IP and
+	// X-Forwarded-Proto headers are trusted, see RealIP.
+	TrustedProxies []*net.IPNet
+	// LegacyErrors serves {"slug": ...} error bodies instead of problem+json
+	// to clients that don't accept application/problem+json.
+	LegacyErrors bool
+	// ValidateOpenAPI checks requests and responses against the OpenAPI
+	// document and logs mismatches.
+	ValidateOpenAPI bool
+	// ReadTimeout, WriteTimeout and IdleTimeout configure the http.Server,
+	// the Default* values are used when not set. WriteTimeout must be longer
+	// than RequestTimeout for handlers to respond.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
 }
 
 const DEFAULT_PORT = "8088"
 
-func NewHttpServer(app *app.Application, accessHeader string) *HttpServer {
+const (
+	// RequestTimeout cancels the context of a request.
+	RequestTimeout      = 60 * time.Second
+	DefaultReadTimeout  = 15 * time.Second
+	DefaultWriteTimeout = RequestTimeout + 5*time.Second
+	DefaultIdleTimeout  = 120 * time.Second
+)
+
+// DeviceIdHeader carries a client generated device id, sessions are bound to
+// it with the device session binding.
+const DeviceIdHeader = "X-Device-Id"
+
+func NewHttpServer(app *app.Application, config HttpConfig) *HttpServer {
 	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonFieldName)
 
-	return &HttpServer{
-		app:          app,
-		accessHeader: accessHeader,
-		validator:    validate,
+	if config.Cookies.Enabled && config.AccessCookie == "" {
+		config.AccessCookie = DefaultAccessCookie
 	}
-}
 
-func (h *HttpServer) Start() {
-	port := DEFAULT_PORT
-	if os.Getenv("APP_PORT") != "" {
-		port = os.Getenv("APP_PORT")
+	if config.Cookies.Path == "" {
+		config.Cookies.Path = DefaultCookiePath
+	}
+
+	if config.Port == "" {
+		config.Port = DEFAULT_PORT
+	}
+
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout = DefaultReadTimeout
+	}
+
+	if config.WriteTimeout == 0 {
+		config.WriteTimeout = DefaultWriteTimeout
+	}
+
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+
+	h := &HttpServer{
+		app:       app,
+		config:    config,
+		validator: validate,
 	}
 
 	r := chi.NewRouter()
@@ -52,17 +117,55 @@ func (h *HttpServer) Start() {
 	h.registerMiddlewares(r)
 	h.registerRoutes(r)
 
-	http.ListenAndServe(fmt.Sprintf(":%s", port), r)
+	h.router = r
+	h.openAPIDoc = h.buildOpenAPI(r)
+
+	h.server = &http.Server{
+		Addr:         fmt.Sprintf(":%s", config.Port),
+		Handler:      r,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+		ErrorLog:     log.New(app.Logger.WriterLevel(logrus.WarnLevel), "", 0),
+	}
+
+	return h
+}
+
+// Start serves HTTP until Shutdown is called, it returns nil then.
+func (h *HttpServer) Start() error {
+	if err := h.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for the requests in flight
+// until ctx is done.
+func (h *HttpServer) Shutdown(ctx context.Context) error {
+	return h.server.Shutdown(ctx)
 }
 
 func (h *HttpServer) registerMiddlewares(r *chi.Mux) {
+	// mux middlewares run before routing, so preflight requests are
+	// answered for every route
+	if cors := h.corsHandler(); cors != nil {
+		r.Use(cors)
+	}
+
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(h.RealIP)
+	r.Use(h.AuditMeta)
 	r.Use(middleware.Logger)
 	r.Use(chilogger.Logger("router", h.app.Logger))
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(middleware.Timeout(RequestTimeout))
 	r.Use(render.SetContentType(render.ContentTypeJSON))
+
+	if h.config.ValidateOpenAPI {
+		r.Use(h.ValidateContract)
+	}
 }
 
 func (h *HttpServer) registerRoutes(r *chi.Mux) {
@@ -71,24 +174,61 @@ func (h *HttpServer) registerRoutes(r *chi.Mux) {
 			render.JSON(w, r, map[string]string{"status": "ok"})
 		})
 
+		r.Get("/openapi.json", h.openAPI)
+
+		r.Get("/errors", h.errorCatalog)
+
 		r.Post("/signIn", h.signIn)
 		r.Post("/signUp", h.signUp)
 		r.Post("/refresh", h.refresh)
+		r.Post("/logout", h.logout)
+		r.Get("/devices/notMe", h.notMePage)
+		r.Post("/devices/notMe", h.notMe)
+		r.Get("/password/reset", h.passwordResetPage)
+		r.Post("/password/reset", h.passwordReset)
+
+		r.With(h.Require(role.PermissionProfileRead)).Get("/me", h.me)
+		r.With(h.Require(role.PermissionProfileRead)).Get("/activity", h.activity)
+		r.With(h.Require(role.PermissionProfileWrite)).Put("/settings", h.updateSettings)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.Require(role.PermissionProfileWrite), h.RefuseImpersonation, h.RefuseAPIKey)
+			r.Put("/password", h.changePassword)
+			r.Delete("/me", h.deleteMe)
+		})
+
+		r.With(h.Require()).Post("/impersonation/stop", h.stopImpersonation)
+
+		r.Route("/apiKeys", h.registerAPIKeyRoutes)
 
-		r.Get("/me", h.me)
-		r.Put("/settings", h.updateSettings)
+		r.Post("/token", h.token)
+		r.Route("/internal", h.registerInternalRoutes)
+
+		r.Route("/admin", h.registerAdminRoutes)
 	})
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,gte=5"`
+	// EvictSessions confirms ending the oldest sessions when the session
+	// limit is reached.
+	EvictSessions bool `json:"evict_sessions,omitempty"`
 }
 
 type RefreshRequest struct {
 	Refresh string `json:"refresh" validate:"required"`
 }
 
+type ChangePasswordRequest struct {
+	Password    string `json:"password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,gte=5"`
+}
+
+type DeleteMeRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
 type SignUpRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,gte=5"`
@@ -109,6 +249,14 @@ type UserResponse struct {
 
 type SettingsPayload struct {
 	Currency string `json:"currency" validate:"required"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+func settingsPayload(s user.Settings) SettingsPayload {
+	return SettingsPayload{
+		Currency: s.Currency.String(),
+		Locale:   string(s.Locale),
+	}
 }
 
 func (e *TokenPairResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -125,20 +273,31 @@ func (h *HttpServer) signIn(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body) // response body is []byte
 	if err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
 	var request LoginRequest
 	if err := json.Unmarshal(body, &request); err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	jkt, err := h.dpopThumbprint(w, r)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
 		return
 	}
 
 	serviceRequest := &service.SignInRequest{
-		Email:    request.Email,
-		Password: request.Password,
-		Ip:       r.RemoteAddr,
+		Email:         request.Email,
+		Password:      request.Password,
+		Ip:            r.RemoteAddr,
+		UserAgent:     r.UserAgent(),
+		DeviceId:      r.Header.Get(DeviceIdHeader),
+		Locale:        acceptLanguage(r),
+		Jkt:           jkt,
+		EvictSessions: request.EvictSessions,
 	}
 
 	tokens, err := h.app.AuthService.SignIn(r.Context(), serviceRequest)
@@ -147,61 +306,76 @@ func (h *HttpServer) signIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.Render(w, r, &TokenPairResponse{
-		Access:  tokens.Access.Token,
-		Refresh: tokens.Refresh.Token,
-	})
+	h.respondWithTokens(w, r, tokens)
 }
 
 func (h *HttpServer) RespondValidationError(errs []validator.FieldError, w http.ResponseWriter, r *http.Request) {
-	for _, err := range errs {
-		fmt.Println(err.Namespace())
-		fmt.Println(err.Field())
-		fmt.Println(err.StructNamespace())
-		fmt.Println(err.StructField())
-		fmt.Println(err.Tag())
-		fmt.Println(err.ActualTag())
-		fmt.Println(err.Kind())
-		fmt.Println(err.Type())
-		fmt.Println(err.Value())
-		fmt.Println(err.Param())
-	}
-
-	err := errs[0]
-
-	slug := "invalid-input"
-	if err.Field() == "Password" && err.Tag() == "gte" {
-		slug = "field-password-invalid-length"
-	} else if err.Field() == "Email" && err.Tag() == "email" {
-		slug = "field-email-invalid"
-	} else if err.Field() == "Name" && err.Tag() == "gte" {
-		slug = "field-name-invalid-length"
-	} else if err.Field() == "Name" && err.Tag() == "required" {
-		slug = "field-name-required"
-	} else if err.Field() == "Password" && err.Tag() == "required" {
-		slug = "field-password-required"
-	} else if err.Field() == "Email" && err.Tag() == "required" {
-		slug = "field-email-required"
-	} else if err.Field() == "Refresh" && err.Tag() == "required" {
-		slug = "invalid-token"
-	} else if err.Field() == "Currency" && err.Tag() == "required" {
-		slug = "field-currency-required"
-	}
-
-	h.BadRequest(slug, err, w, r)
+	// the first field slug stays the problem slug for clients that only
+	// read it
+	p := newProblem(r, http.StatusBadRequest, validationSlug(errs[0]), "Request validation failed")
+	p.Errors = fieldProblems(errs)
+
+	h.app.Logger.Debug(map[string]string{
+		"error-type": "HTTP Request Error",
+		"slug":       p.Slug,
+		"error":      validator.ValidationErrors(errs).Error(),
+	})
+
+	h.respondWithProblem(w, r, p)
+}
+
+func validationSlug(err validator.FieldError) string {
+	slug := apperrors.SlugInvalidInput
+	if err.StructField() == "Password" && err.Tag() == "gte" {
+		slug = apperrors.SlugFieldPasswordInvalidLength
+	} else if err.StructField() == "Email" && err.Tag() == "email" {
+		slug = apperrors.SlugFieldEmailInvalid
+	} else if err.StructField() == "Name" && err.Tag() == "gte" {
+		slug = apperrors.SlugFieldNameInvalidLength
+	} else if err.StructField() == "Name" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldNameRequired
+	} else if err.StructField() == "Password" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldPasswordRequired
+	} else if err.StructField() == "Email" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldEmailRequired
+	} else if err.StructField() == "Refresh" && err.Tag() == "required" {
+		slug = apperrors.SlugInvalidToken
+	} else if err.StructField() == "Currency" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldCurrencyRequired
+	} else if err.StructField() == "NewPassword" && err.Tag() == "gte" {
+		slug = apperrors.SlugFieldNewPasswordInvalidLength
+	} else if err.StructField() == "NewPassword" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldNewPasswordRequired
+	} else if err.StructField() == "Reason" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldReasonRequired
+	} else if err.StructField() == "Name" && err.Tag() == "max" {
+		slug = apperrors.SlugFieldNameInvalidLength
+	} else if err.StructField() == "GrantType" && err.Tag() == "required" {
+		slug = apperrors.SlugUnsupportedGrantType
+	} else if (err.StructField() == "ClientId" || err.StructField() == "ClientSecret") && err.Tag() == "required" {
+		slug = apperrors.SlugInvalidClient
+	} else if err.StructField() == "Token" && err.Tag() == "required" {
+		slug = apperrors.SlugInvalidToken
+	} else if err.StructField() == "URL" && (err.Tag() == "required" || err.Tag() == "url") {
+		slug = apperrors.SlugFieldURLInvalid
+	} else if err.StructField() == "EventTypes" && err.Tag() == "required" {
+		slug = apperrors.SlugFieldEventTypesRequired
+	}
+
+	return slug
 }
 
 func (h *HttpServer) signUp(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body) // response body is []byte
 	if err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
 	var request SignUpRequest
 	if err := json.Unmarshal(body, &request); err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
@@ -211,11 +385,21 @@ func (h *HttpServer) signUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	jkt, err := h.dpopThumbprint(w, r)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
 	serviceRequest := &service.SignUpRequest{
-		Email:    request.Email,
-		Password: request.Password,
-		Name:     request.Name,
-		Ip:       r.RemoteAddr,
+		Email:     request.Email,
+		Password:  request.Password,
+		Name:      request.Name,
+		Ip:        r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		DeviceId:  r.Header.Get(DeviceIdHeader),
+		Locale:    acceptLanguage(r),
+		Jkt:       jkt,
 	}
 
 	tokens, err := h.app.AuthService.SignUp(r.Context(), serviceRequest)
@@ -224,29 +408,25 @@ func (h *HttpServer) signUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.Render(w, r, &TokenPairResponse{
-		Access:  tokens.Access.Token,
-		Refresh: tokens.Refresh.Token,
-	})
+	h.respondWithTokens(w, r, tokens)
 }
 
 func (h *HttpServer) updateSettings(w http.ResponseWriter, r *http.Request) {
-	access, err := h.getAccessFromHeader(w, r)
-	if err != nil {
-		h.Unauthorised("invalid-token", err, w, r)
+	access, ok := h.access(w, r)
+	if !ok {
 		return
 	}
 
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body) // response body is []byte
 	if err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
 	var payload SettingsPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
@@ -258,6 +438,7 @@ func (h *HttpServer) updateSettings(w http.ResponseWriter, r *http.Request) {
 
 	serviceRequest := service.UpdateSettingsRequest{
 		Currency: payload.Currency,
+		Locale:   payload.Locale,
 		UserUUID: access.Claims.UserId,
 	}
 
@@ -268,19 +449,16 @@ func (h *HttpServer) updateSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.Render(w, r, &UserResponse{
-		UUID:  user.UUID,
-		Email: user.Email,
-		Name:  user.Name,
-		Settings: SettingsPayload{
-			Currency: user.Settings.Currency.String(),
-		},
+		UUID:     user.UUID,
+		Email:    user.Email,
+		Name:     user.Name,
+		Settings: settingsPayload(user.Settings),
 	})
 }
 
 func (h *HttpServer) me(w http.ResponseWriter, r *http.Request) {
-	access, err := h.getAccessFromHeader(w, r)
-	if err != nil {
-		h.Unauthorised("invalid-token", err, w, r)
+	access, ok := h.access(w, r)
+	if !ok {
 		return
 	}
 
@@ -291,26 +469,29 @@ func (h *HttpServer) me(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.Render(w, r, &UserResponse{
-		UUID:  user.UUID,
-		Email: user.Email,
-		Name:  user.Name,
-		Settings: SettingsPayload{
-			Currency: user.Settings.Currency.String(),
-		},
+		UUID:     user.UUID,
+		Email:    user.Email,
+		Name:     user.Name,
+		Settings: settingsPayload(user.Settings),
 	})
 }
 
-func (h *HttpServer) refresh(w http.ResponseWriter, r *http.Request) {
+func (h *HttpServer) changePassword(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
 	defer r.Body.Close()
-	body, err := ioutil.ReadAll(r.Body) // response body is []byte
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
-	var request RefreshRequest
+	var request ChangePasswordRequest
 	if err := json.Unmarshal(body, &request); err != nil {
-		h.BadRequest("invalid-input", err, w, r)
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
 		return
 	}
 
@@ -320,33 +501,142 @@ func (h *HttpServer) refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokens, err := h.app.AuthService.Refresh(r.Context(), request.Refresh, r.RemoteAddr)
+	tokens, err := h.app.AuthService.ChangePassword(r.Context(), &service.ChangePasswordRequest{
+		UserUUID:    access.Claims.UserId,
+		Password:    request.Password,
+		NewPassword: request.NewPassword,
+		Ip:          r.RemoteAddr,
+		DeviceId:    r.Header.Get(DeviceIdHeader),
+		Jkt:         access.Claims.Thumbprint(),
+	})
 	if err != nil {
 		h.RespondWithAppError(err, w, r)
 		return
 	}
 
-	render.Render(w, r, &TokenPairResponse{
-		Access:  tokens.Access.Token,
-		Refresh: tokens.Refresh.Token,
+	h.respondWithTokens(w, r, tokens)
+}
+
+func (h *HttpServer) deleteMe(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request DeleteMeRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	err = h.app.AuthService.DeleteUser(r.Context(), &service.DeleteUserRequest{
+		UserUUID: access.Claims.UserId,
+		Password: request.Password,
 	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
+
+// refreshToken reads the refresh token from the cookie in the cookie mode
+// and from the request body otherwise.
+func (h *HttpServer) refreshToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if h.cookieMode(r) {
+		token, err := h.refreshFromCookie(r)
+		if errors.Is(err, errRefreshCookie) {
+			h.Unauthorised(apperrors.SlugInvalidToken, err, w, r)
+			return "", false
+		}
+		if err != nil {
+			h.Forbidden(apperrors.SlugCsrfTokenInvalid, err, w, r)
+			return "", false
+		}
+
+		return token, true
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body) // response body is []byte
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return "", false
+	}
+
+	var request RefreshRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return "", false
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return "", false
+	}
+
+	return request.Refresh, true
 }
 
-func (h *HttpServer) getAccessFromHeader(w http.ResponseWriter, r *http.Request) (*jwt.AccessJWT, error) {
-	reqToken := r.Header.Get("Authorization")
-	splitToken := strings.Split(reqToken, "Bearer ")
-	if len(splitToken)< 2{
-		return &jwt.AccessJWT{}, apperrors.NewAuthorizationError("Token not presented", "invalid-token")
+func (h *HttpServer) refresh(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.refreshToken(w, r)
+	if !ok {
+		return
 	}
-	
-	tokenHeader := splitToken[1]
 
-	access, err := h.app.JWTService.ValidateAccess(tokenHeader)
+	jkt, err := h.dpopThumbprint(w, r)
 	if err != nil {
-		return &jwt.AccessJWT{}, err
+		h.RespondWithAppError(err, w, r)
+		return
 	}
 
-	return access, nil
+	tokens, err := h.app.AuthService.Refresh(r.Context(), &service.RefreshRequest{
+		Token:    token,
+		Ip:       r.RemoteAddr,
+		DeviceId: r.Header.Get(DeviceIdHeader),
+		Jkt:      jkt,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	h.respondWithTokens(w, r, tokens)
+}
+
+// logout ends the session of the refresh token and clears the session
+// cookies.
+func (h *HttpServer) logout(w http.ResponseWriter, r *http.Request) {
+	token, ok := h.refreshToken(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.app.AuthService.Logout(r.Context(), token); err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	if h.config.Cookies.Enabled {
+		h.clearSessionCookies(w)
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
 }
 
 func (h *HttpServer) InternalError(slug string, err error, w http.ResponseWriter, r *http.Request) {
@@ -358,6 +648,10 @@ func (h *HttpServer) Unauthorised(slug string, err error, w http.ResponseWriter,
 	h.httpRespondWithError(err, slug, w, r, "Unauthorised", http.StatusUnauthorized)
 }
 
+func (h *HttpServer) Forbidden(slug string, err error, w http.ResponseWriter, r *http.Request) {
+	h.httpRespondWithError(err, slug, w, r, "Forbidden", http.StatusForbidden)
+}
+
 func (h *HttpServer) BadRequest(slug string, err error, w http.ResponseWriter, r *http.Request) {
 	h.httpRespondWithError(err, slug, w, r, "Bad request", http.StatusBadRequest)
 }
@@ -366,45 +660,68 @@ func (h *HttpServer) NotFound(slug string, err error, w http.ResponseWriter, r *
 	h.httpRespondWithError(err, slug, w, r, "Not found", http.StatusNotFound)
 }
 
+func (h *HttpServer) Conflict(slug string, err error, w http.ResponseWriter, r *http.Request) {
+	h.httpRespondWithError(err, slug, w, r, "Conflict", http.StatusConflict)
+}
+
+func (h *HttpServer) TooManyRequests(slug string, err error, w http.ResponseWriter, r *http.Request) {
+	h.httpRespondWithError(err, slug, w, r, "Too many requests", http.StatusTooManyRequests)
+}
+
+func (h *HttpServer) Unavailable(slug string, err error, w http.ResponseWriter, r *http.Request) {
+	h.httpRespondWithError(err, slug, w, r, "Service unavailable", http.StatusServiceUnavailable)
+}
+
 func (h *HttpServer) RespondWithAppError(err error, w http.ResponseWriter, r *http.Request) {
-	appError, ok := err.(apperrors.AppError)
+	appError, ok := apperrors.As(err)
 	if !ok {
-		h.InternalError("internal-server-error", err, w, r)
+		h.InternalError(apperrors.SlugInternalServerError, err, w, r)
 		return
 	}
 
 	switch appError.ErrorType() {
 	case apperrors.ErrorTypeAuthorization:
 		h.Unauthorised(appError.Slug(), appError, w, r)
+	case apperrors.ErrorTypeForbidden:
+		h.Forbidden(appError.Slug(), appError, w, r)
 	case apperrors.ErrorTypeIncorrectInput:
 		h.BadRequest(appError.Slug(), appError, w, r)
 	case apperrors.ErrorNotFound:
 		h.NotFound(appError.Slug(), appError, w, r)
+	case apperrors.ErrorTypeConflict:
+		h.Conflict(appError.Slug(), appError, w, r)
+	case apperrors.ErrorTypeRateLimited:
+		h.TooManyRequests(appError.Slug(), appError, w, r)
+	case apperrors.ErrorTypeUnavailable:
+		h.Unavailable(appError.Slug(), appError, w, r)
 	default:
 		h.InternalError(appError.Slug(), appError, w, r)
 	}
 }
 
 func (h *HttpServer) httpRespondWithError(err error, slug string, w http.ResponseWriter, r *http.Request, logMSg string, status int) {
+	if !apperrors.IsRegistered(slug) {
+		h.app.Logger.Warnf("Error slug %s is not registered", slug)
+	}
+
 	h.app.Logger.Debug(map[string]string{
 		"error-type": "HTTP Request Error",
 		"slug":       slug,
 		"error":      err.Error(),
 	})
 
-	resp := ErrorResponse{slug, status}
-
-	if err := render.Render(w, r, resp); err != nil {
-		panic(err)
+	// server and authorization errors may wrap internal errors, clients only
+	// get the generic message for them
+	detail := err.Error()
+	if status >= http.StatusInternalServerError || errors.Is(err, apperrors.Authorization) {
+		detail = logMSg
 	}
-}
 
-type ErrorResponse struct {
-	Slug       string `json:"slug"`
-	httpStatus int
+	h.respondWithProblem(w, r, newProblem(r, status, slug, detail))
 }
 
-func (e ErrorResponse) Render(w http.ResponseWriter, r *http.Request) error {
-	render.Status(r, e.httpStatus)
-	return nil
+// ErrorResponse is the legacy error body.
+type ErrorResponse struct {
+	Slug    string `json:"slug"`
+	Message string `json:"message,omitempty"`
 }
@@ -0,0 +1,107 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const grpcAcceptLanguageKey = "accept-language"
+
+// acceptLanguage returns the Accept-Language locale of the request or an
+// empty one when no supported language is asked for.
+func acceptLanguage(r *http.Request) i18n.Locale {
+	locale, _ := i18n.FromAcceptLanguage(r.Header.Get("Accept-Language"))
+	return locale
+}
+
+// locale picks the language of messages: the one asked for in
+// Accept-Language, then the one saved by the authenticated user.
+func locale(r *http.Request) i18n.Locale {
+	saved := i18n.Locale("")
+	if access, ok := AccessFrom(r.Context()); ok {
+		saved = i18n.Locale(access.Claims.Locale)
+	}
+
+	return i18n.Resolve(acceptLanguage(r), saved)
+}
+
+// localize replaces problem details with translated messages, details of
+// unknown slugs are kept.
+func localize(l i18n.Locale, p *ProblemResponse) {
+	if m, ok := i18n.Message(l, p.Slug); ok {
+		p.Detail = m
+	}
+
+	for i := range p.Errors {
+		// the generic slug says less than the failed rule
+		if p.Errors[i].Slug == apperrors.SlugInvalidInput {
+			continue
+		}
+
+		if m, ok := i18n.Message(l, p.Errors[i].Slug); ok {
+			p.Errors[i].Detail = m
+		}
+	}
+}
+
+// grpcAcceptLanguage is acceptLanguage for the accept-language metadata.
+func grpcAcceptLanguage(ctx context.Context) i18n.Locale {
+	var locale i18n.Locale
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcAcceptLanguageKey); len(values) > 0 {
+			locale, _ = i18n.FromAcceptLanguage(values[0])
+		}
+	}
+
+	return locale
+}
+
+// localeInterceptor adds a LocalizedMessage detail to errors carrying a slug,
+// the status message stays the slug.
+func (h *GrpcServer) localeInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return resp, err
+	}
+
+	slug, ok := grpcSlug(st)
+	if !ok {
+		return resp, err
+	}
+
+	l := i18n.Resolve(grpcAcceptLanguage(ctx))
+	m, ok := i18n.Message(l, slug)
+	if !ok {
+		h.app.Logger.Warnf("Error slug %s is not registered", slug)
+		return resp, err
+	}
+
+	if localized, err := st.WithDetails(&errdetails.LocalizedMessage{Locale: string(l), Message: m}); err == nil {
+		st = localized
+	}
+
+	return resp, st.Err()
+}
+
+// grpcSlug returns the slug of statuses made by grpcStatus.
+func grpcSlug(st *status.Status) (string, bool) {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == grpcErrorDomain {
+			return info.Reason, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,109 @@
+package ports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+type ImpersonationResponse struct {
+	Access    string    `json:"access"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e *ImpersonationResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+// RefuseImpersonation rejects requests made with an impersonation token. It
+// must be mounted after Require.
+func (h *HttpServer) RefuseImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		access, ok := h.access(w, r)
+		if !ok {
+			return
+		}
+
+		if access.Claims.IsImpersonated() {
+			h.Forbidden(apperrors.SlugImpersonationForbidden, apperrors.NewForbiddenError("Not allowed under impersonation", apperrors.SlugImpersonationForbidden), w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *HttpServer) adminImpersonate(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugUserNotFound, err, w, r)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request ImpersonateRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	token, err := h.app.ImpersonationService.Start(r.Context(), &service.ImpersonateRequest{
+		AdminUUID: access.Claims.UserId,
+		UserUUID:  userUUID,
+		Reason:    request.Reason,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &ImpersonationResponse{
+		Access:    token.Token,
+		ExpiresAt: token.Claims.ExpiresAt.Time,
+	})
+}
+
+func (h *HttpServer) stopImpersonation(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.access(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.app.ImpersonationService.Stop(r.Context(), &access.Claims)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
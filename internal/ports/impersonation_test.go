@@ -0,0 +1,120 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/google/uuid"
+)
+
+type inTxKey struct{}
+
+// markingTx marks the context of the function, so repositories can tell
+// whether they write in a transaction.
+type markingTx struct{}
+
+func (markingTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, inTxKey{}, true))
+}
+
+func inTx(ctx context.Context) bool {
+	in, _ := ctx.Value(inTxKey{}).(bool)
+	return in
+}
+
+// txImpersonations fails the test when an impersonation is written outside
+// a transaction.
+type txImpersonations struct {
+	service.ImpersonationRepository
+	t *testing.T
+}
+
+func (s txImpersonations) Add(ctx context.Context, i *service.Impersonation) error {
+	if !inTx(ctx) {
+		s.t.Error("impersonation is added outside a transaction")
+	}
+
+	return s.ImpersonationRepository.Add(ctx, i)
+}
+
+func (s txImpersonations) End(ctx context.Context, id uuid.UUID, endedAt time.Time) error {
+	if !inTx(ctx) {
+		s.t.Error("impersonation is ended outside a transaction")
+	}
+
+	return s.ImpersonationRepository.End(ctx, id, endedAt)
+}
+
+// txAudit fails the test when an impersonation is audited outside a
+// transaction.
+type txAudit struct {
+	audit.AuditRepository
+	t *testing.T
+}
+
+func (s txAudit) Add(ctx context.Context, e *audit.Event) error {
+	impersonation := e.Type == audit.EventImpersonationStarted || e.Type == audit.EventImpersonationStopped
+	if impersonation && !inTx(ctx) {
+		s.t.Errorf("%s is audited outside a transaction", e.Type)
+	}
+
+	return s.AuditRepository.Add(ctx, e)
+}
+
+func TestImpersonationIsRecordedWithItsAuditEvent(t *testing.T) {
+	store := newMemoryStore()
+	repositories := store.Repositories()
+	repositories.Tx = markingTx{}
+	repositories.Impersonations = txImpersonations{repositories.Impersonations, t}
+	repositories.Audit = txAudit{repositories.Audit, t}
+	c := newContractWith(t, store, repositories, app.Config{})
+
+	annUUID, _ := c.signUp("ann@example.com", "password")
+	admin := c.admin("bob@example.com")
+
+	access := str(c.json(http.MethodPost, "/api/v1/admin/users/"+annUUID+"/impersonate", admin, &ImpersonateRequest{Reason: "ticket 42"}, http.StatusOK), "access")
+	c.json(http.MethodPost, "/api/v1/impersonation/stop", access, nil, http.StatusOK)
+
+	if n := len(c.auditEvents(audit.EventImpersonationStarted)); n != 1 {
+		t.Errorf("%d impersonation starts recorded, want 1", n)
+	}
+	if n := len(c.auditEvents(audit.EventImpersonationStopped)); n != 1 {
+		t.Errorf("%d impersonation stops recorded, want 1", n)
+	}
+}
+
+func TestImpersonationActsAsTheUserUntilStopped(t *testing.T) {
+	c := newContract(t)
+	annUUID, pair := c.signUp("ann@example.com", "password")
+	admin := c.admin("bob@example.com")
+	adminUUID := str(c.json(http.MethodGet, "/api/v1/me", admin, nil, http.StatusOK), "uuid")
+
+	impersonate := func(userUUID, access string, status int) string {
+		t.Helper()
+		return str(c.json(http.MethodPost, "/api/v1/admin/users/"+userUUID+"/impersonate", access, &ImpersonateRequest{Reason: "ticket 42"}, status), "access")
+	}
+
+	impersonate(annUUID, str(pair, "access"), http.StatusForbidden)
+	impersonate(adminUUID, admin, http.StatusBadRequest)
+	carolAdmin := c.admin("carol@example.com")
+	impersonate(str(c.json(http.MethodGet, "/api/v1/me", carolAdmin, nil, http.StatusOK), "uuid"), admin, http.StatusBadRequest)
+
+	access := impersonate(annUUID, admin, http.StatusOK)
+	if got := str(c.json(http.MethodGet, "/api/v1/me", access, nil, http.StatusOK), "uuid"); got != annUUID {
+		t.Errorf("impersonation acts as %s, want %s", got, annUUID)
+	}
+
+	// credentials stay with the user
+	c.json(http.MethodPut, "/api/v1/password", access, &ChangePasswordRequest{Password: "password", NewPassword: "password2"}, http.StatusForbidden)
+	c.json(http.MethodPost, "/api/v1/apiKeys/", access, &CreateAPIKeyRequest{Name: "ci"}, http.StatusForbidden)
+	c.json(http.MethodGet, "/api/v1/admin/users", access, nil, http.StatusForbidden)
+
+	c.json(http.MethodPost, "/api/v1/impersonation/stop", access, nil, http.StatusOK)
+	c.json(http.MethodGet, "/api/v1/me", access, nil, http.StatusUnauthorized)
+	c.json(http.MethodPost, "/api/v1/impersonation/stop", admin, nil, http.StatusBadRequest)
+}
@@ -0,0 +1,94 @@
+package ports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type LookupUsersRequest struct {
+	UUIDs  []uuid.UUID `json:"uuids"`
+	Emails []string    `json:"emails"`
+}
+
+type LookupMissing struct {
+	UUIDs  []uuid.UUID `json:"uuids"`
+	Emails []string    `json:"emails"`
+}
+
+// LookupUsersResponse maps every requested uuid and email to the user, or to
+// null when it was not found.
+type LookupUsersResponse struct {
+	Users   map[string]*UserResponse `json:"users"`
+	Emails  map[string]*UserResponse `json:"emails"`
+	Missing LookupMissing            `json:"missing"`
+}
+
+func (e *LookupUsersResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func newUserResponse(u *user.User) *UserResponse {
+	return &UserResponse{
+		UUID:     u.UUID,
+		Email:    u.Email,
+		Name:     u.Name,
+		Settings: settingsPayload(u.Settings),
+	}
+}
+
+func (h *HttpServer) lookupUsers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request LookupUsersRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	result, err := h.app.LookupService.Lookup(r.Context(), &service.LookupRequest{
+		UUIDs:  request.UUIDs,
+		Emails: request.Emails,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &LookupUsersResponse{
+		Users:  map[string]*UserResponse{},
+		Emails: map[string]*UserResponse{},
+		Missing: LookupMissing{
+			UUIDs:  result.MissingUUIDs,
+			Emails: result.MissingEmails,
+		},
+	}
+
+	for _, id := range request.UUIDs {
+		response.Users[id.String()] = nil
+		if u, ok := result.ByUUID[id]; ok {
+			response.Users[id.String()] = newUserResponse(u)
+		}
+	}
+
+	for _, email := range request.Emails {
+		response.Emails[email] = nil
+		if u, ok := result.ByEmail[email]; ok {
+			response.Emails[email] = newUserResponse(u)
+		}
+	}
+
+	render.Render(w, r, response)
+}
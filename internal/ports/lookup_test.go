@@ -0,0 +1,45 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/google/uuid"
+)
+
+func TestLookupCountsRepeatedUsersOnce(t *testing.T) {
+	store := newMemoryStore()
+	c := newContractWith(t, store, store.Repositories(), app.Config{MaxLookupSize: 2})
+	annUUID, _ := c.signUp("ann@example.com", "password")
+
+	created, err := c.h.app.ClientService.CreateClient(context.Background(), &service.CreateClientRequest{
+		ID:     "billing",
+		Name:   "Billing",
+		Scopes: []string{client.ScopeUsersRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := c.clientToken("grant_type=client_credentials&client_id=billing&client_secret="+created.Secret, http.StatusOK).AccessToken
+
+	ann, nobody := uuid.MustParse(annUUID), uuid.New()
+	found := c.json(http.MethodPost, "/api/v1/internal/users/lookup", token, &LookupUsersRequest{
+		UUIDs: []uuid.UUID{ann, nobody, ann, nobody},
+	}, http.StatusOK)
+
+	missing, _ := found["missing"].(map[string]interface{})
+	if uuids, _ := missing["uuids"].([]interface{}); len(uuids) != 1 || uuids[0] != nobody.String() {
+		t.Errorf("missing uuids %v, want %s once", missing["uuids"], nobody)
+	}
+	if users, _ := found["users"].(map[string]interface{}); len(users) != 2 || users[annUUID] == nil {
+		t.Errorf("users %v, want ann and nobody", found["users"])
+	}
+
+	c.json(http.MethodPost, "/api/v1/internal/users/lookup", token, &LookupUsersRequest{
+		Emails: []string{"ann@example.com", "ann@example.com", "bob@example.com", "carol@example.com"},
+	}, http.StatusBadRequest)
+}
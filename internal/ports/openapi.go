@@ -0,0 +1,238 @@
+package ports
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bysoft-wallet/users/pkg/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const (
+	openAPITitle   = "Users API"
+	openAPIVersion = "v1"
+	OpenAPIPath    = "/api/v1/openapi.json"
+
+	securityBearer       = "bearer"
+	securityDPoP         = "dpop"
+	securityAccessHeader = "accessHeader"
+	securityAccessCookie = "accessCookie"
+	securityClientBasic  = "clientBasic"
+)
+
+func queryParam(name, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+var (
+	pageParams = []*openapi.Parameter{
+		queryParam("limit", "Page size"),
+		queryParam("cursor", "next_cursor of the previous page"),
+	}
+	auditParams = append([]*openapi.Parameter{
+		queryParam("user", "Subject user uuid"),
+		queryParam("actor", "Actor user uuid"),
+		queryParam("type", "Event type"),
+		queryParam("ip", "Client IP"),
+		queryParam("from", "RFC 3339 time the events start at"),
+		queryParam("to", "RFC 3339 time the events end before"),
+	}, pageParams...)
+	usersParams = append([]*openapi.Parameter{
+		queryParam("email", "Email prefix"),
+		queryParam("created_from", "RFC 3339 time the users were created from"),
+		queryParam("created_to", "RFC 3339 time the users were created before"),
+		queryParam("verified", "true or false"),
+	}, pageParams...)
+)
+
+func ok(types ...interface{}) map[int][]interface{} {
+	return map[int][]interface{}{http.StatusOK: types}
+}
+
+// openAPIRoutes describes every route of registerRoutes, routes missing here
+// are reported when the document is built.
+func (h *HttpServer) openAPIRoutes() []*openapi.Route {
+	user := []string{securityBearer, securityDPoP, securityAccessHeader}
+	if h.config.AccessCookie != "" {
+		user = append(user, securityAccessCookie)
+	}
+	service := []string{securityBearer}
+	tokens := ok(TokenPairResponse{}, CookieSessionResponse{})
+
+	return []*openapi.Route{
+		{Method: http.MethodGet, Path: "/api/v1/health", Summary: "Health check", Tag: "service", Responses: ok(StatusResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/errors", Summary: "Error slug catalog", Tag: "service", Responses: ok(ErrorCatalogResponse{})},
+		{Method: http.MethodGet, Path: OpenAPIPath, Summary: "This document", Tag: "service", Responses: ok(nil)},
+
+		{Method: http.MethodPost, Path: "/api/v1/signIn", Summary: "Sign in", Tag: "auth", Request: LoginRequest{}, Responses: tokens},
+		{Method: http.MethodPost, Path: "/api/v1/signUp", Summary: "Sign up", Tag: "auth", Request: SignUpRequest{}, Responses: tokens},
+		{Method: http.MethodPost, Path: "/api/v1/refresh", Summary: "Refresh the token pair", Tag: "auth", Request: RefreshRequest{}, Responses: tokens},
+		{Method: http.MethodPost, Path: "/api/v1/logout", Summary: "End the session of the refresh token", Tag: "auth", Request: RefreshRequest{}, Responses: ok(StatusResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/devices/notMe", Summary: "Page confirming a sign in from a new device wasn't the user", Tag: "auth", Query: []*openapi.Parameter{queryParam("token", "Token from the email link")}, HTML: true, Responses: ok(nil)},
+		{Method: http.MethodPost, Path: "/api/v1/devices/notMe", Summary: "Report a sign in from a new device, end every session and email a password reset link", Tag: "auth", Request: NotMeRequest{}, Form: true, HTML: true, Responses: ok(StatusResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/password/reset", Summary: "Page setting a new password with the emailed link", Tag: "auth", Query: []*openapi.Parameter{queryParam("token", "Token from the email link")}, HTML: true, Responses: ok(nil)},
+		{Method: http.MethodPost, Path: "/api/v1/password/reset", Summary: "Set a new password with the emailed link", Tag: "auth", Request: PasswordResetRequest{}, Form: true, HTML: true, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/token", Summary: "Client credentials grant", Tag: "auth", Security: []string{securityClientBasic}, Request: ClientTokenRequest{}, Form: true, Responses: ok(ClientTokenResponse{})},
+
+		{Method: http.MethodGet, Path: "/api/v1/me", Summary: "Current user profile", Tag: "profile", Security: user, Responses: ok(UserResponse{})},
+		{Method: http.MethodDelete, Path: "/api/v1/me", Summary: "Delete the account", Tag: "profile", Security: user, Request: DeleteMeRequest{}, Responses: ok(StatusResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/activity", Summary: "Audit events of the current user", Tag: "profile", Security: user, Query: pageParams, Responses: ok(AuditEventsResponse{})},
+		{Method: http.MethodPut, Path: "/api/v1/settings", Summary: "Update settings", Tag: "profile", Security: user, Request: SettingsPayload{}, Responses: ok(UserResponse{})},
+		{Method: http.MethodPut, Path: "/api/v1/password", Summary: "Change the password", Tag: "profile", Security: user, Request: ChangePasswordRequest{}, Responses: tokens},
+		{Method: http.MethodPost, Path: "/api/v1/impersonation/stop", Summary: "End the impersonation", Tag: "profile", Security: user, Responses: ok(StatusResponse{})},
+
+		{Method: http.MethodGet, Path: "/api/v1/apiKeys/", Summary: "List API keys", Tag: "apiKeys", Security: user, Responses: ok(APIKeysResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/apiKeys/", Summary: "Create an API key", Tag: "apiKeys", Security: user, Request: CreateAPIKeyRequest{}, Responses: map[int][]interface{}{http.StatusCreated: {APIKeyResponse{}}}},
+		{Method: http.MethodDelete, Path: "/api/v1/apiKeys/{uuid}", Summary: "Revoke an API key", Tag: "apiKeys", Security: user, Responses: ok(StatusResponse{})},
+
+		{Method: http.MethodPost, Path: "/api/v1/internal/introspect", Summary: "Token introspection", Tag: "internal", Security: service, Request: IntrospectRequest{}, Responses: ok(IntrospectResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/internal/users/lookup", Summary: "Batch user lookup", Tag: "internal", Security: service, Request: LookupUsersRequest{}, Responses: ok(LookupUsersResponse{})},
+
+		{Method: http.MethodGet, Path: "/api/v1/admin/users", Summary: "Search users", Tag: "admin", Security: user, Query: usersParams, Responses: ok(AdminUsersResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/admin/users/{uuid}", Summary: "User details", Tag: "admin", Security: user, Responses: ok(AdminUserDetailsResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/users/{uuid}/logout", Summary: "End every session of the user", Tag: "admin", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/users/{uuid}/lock", Summary: "Lock the user", Tag: "admin", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/users/{uuid}/unlock", Summary: "Unlock the user", Tag: "admin", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/users/{uuid}/resetPassword", Summary: "Require a new password", Tag: "admin", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPut, Path: "/api/v1/admin/users/{uuid}/settings", Summary: "Update user settings", Tag: "admin", Security: user, Request: SettingsPayload{}, Responses: ok(AdminUserResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/users/{uuid}/impersonate", Summary: "Impersonate the user", Tag: "admin", Security: user, Request: ImpersonateRequest{}, Responses: ok(ImpersonationResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/admin/audit", Summary: "Search audit events", Tag: "admin", Security: user, Query: auditParams, Responses: ok(AuditEventsResponse{})},
+
+		{Method: http.MethodGet, Path: "/api/v1/admin/webhooks/", Summary: "List webhooks", Tag: "webhooks", Security: user, Responses: ok(WebhooksResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/webhooks/", Summary: "Create a webhook", Tag: "webhooks", Security: user, Request: CreateWebhookRequest{}, Responses: map[int][]interface{}{http.StatusCreated: {WebhookResponse{}}}},
+		{Method: http.MethodGet, Path: "/api/v1/admin/webhooks/{uuid}", Summary: "Webhook details", Tag: "webhooks", Security: user, Responses: ok(WebhookResponse{})},
+		{Method: http.MethodDelete, Path: "/api/v1/admin/webhooks/{uuid}", Summary: "Delete a webhook", Tag: "webhooks", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/webhooks/{uuid}/enable", Summary: "Enable a disabled webhook", Tag: "webhooks", Security: user, Responses: ok(StatusResponse{})},
+		{Method: http.MethodGet, Path: "/api/v1/admin/webhooks/{uuid}/deliveries", Summary: "List deliveries", Tag: "webhooks", Security: user, Query: []*openapi.Parameter{queryParam("status", "Delivery status"), queryParam("limit", "Page size")}, Responses: ok(WebhookDeliveriesResponse{})},
+		{Method: http.MethodPost, Path: "/api/v1/admin/webhooks/deliveries/{uuid}/replay", Summary: "Replay a delivery", Tag: "webhooks", Security: user, Responses: map[int][]interface{}{http.StatusAccepted: {WebhookDeliveryResponse{}}}},
+	}
+}
+
+// buildOpenAPI describes the routes of the router. Routes without a
+// description and descriptions of missing routes are logged so the two
+// can't drift apart unnoticed.
+func (h *HttpServer) buildOpenAPI(router chi.Routes) *openapi.Document {
+	b := openapi.NewBuilder(openAPITitle, openAPIVersion, ProblemResponse{})
+
+	accessHeader := h.config.AccessHeader
+	if accessHeader == "" {
+		accessHeader = "X-API-Token"
+	}
+
+	b.AddSecurityScheme(securityBearer, &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	b.AddSecurityScheme(securityDPoP, &openapi.SecurityScheme{Type: "http", Scheme: "DPoP", Description: "DPoP bound access token with a proof in the DPoP header"})
+	b.AddSecurityScheme(securityAccessHeader, &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: accessHeader})
+	b.AddSecurityScheme(securityClientBasic, &openapi.SecurityScheme{Type: "http", Scheme: "basic", Description: "Client id and secret"})
+	if h.config.AccessCookie != "" {
+		b.AddSecurityScheme(securityAccessCookie, &openapi.SecurityScheme{Type: "apiKey", In: "cookie", Name: h.config.AccessCookie, Description: "Requires the X-CSRF-Token header for unsafe methods"})
+	}
+
+	for _, route := range h.openAPIRoutes() {
+		b.Add(route)
+	}
+
+	missing, unregistered := h.routeDrift(router)
+	for _, route := range missing {
+		h.app.Logger.Warnf("Route %s is missing in the OpenAPI document", route)
+	}
+	for _, route := range unregistered {
+		h.app.Logger.Warnf("OpenAPI route %s is not registered", route)
+	}
+
+	return b.Document()
+}
+
+// routeDrift compares the router with openAPIRoutes, missing routes are
+// registered without a description and unregistered ones are described
+// only. Both are "METHOD /path" sorted.
+func (h *HttpServer) routeDrift(router chi.Routes) (missing, unregistered []string) {
+	described := map[string]bool{}
+	for _, route := range h.openAPIRoutes() {
+		described[route.Method+" "+route.Path] = true
+	}
+
+	_ = chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !described[method+" "+route] {
+			missing = append(missing, method+" "+route)
+		}
+		delete(described, method+" "+route)
+		return nil
+	})
+
+	for route := range described {
+		unregistered = append(unregistered, route)
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unregistered)
+	return missing, unregistered
+}
+
+func (h *HttpServer) openAPI(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, h.openAPIDoc)
+}
+
+// ValidateContract checks request and response bodies of described routes
+// against the OpenAPI document and logs every mismatch. It is meant for
+// development and staging, bodies are buffered in memory.
+func (h *HttpServer) ValidateContract(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.NewRouteContext()
+		if h.openAPIDoc == nil || !h.router.Match(rctx, r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := rctx.RoutePattern()
+		op, ok := h.openAPIDoc.Operation(r.Method, route)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := h.app.Logger.WithField("route", r.Method+" "+route)
+
+		if op.RequestBody != nil && !strings.HasPrefix(r.Header.Get("Content-Type"), openapi.ContentTypeForm) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err == nil {
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				for _, p := range h.openAPIDoc.ValidateJSON(op.RequestBody.Content[openapi.ContentTypeJSON].Schema, body) {
+					logger.Warnf("Request does not match the OpenAPI document: %s", p)
+				}
+			}
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		response, ok := op.Responses[strconv.Itoa(status)]
+		if !ok && status >= http.StatusBadRequest {
+			response, ok = op.Responses["default"]
+		}
+		if !ok {
+			logger.Warnf("Status %d is not documented", status)
+			return
+		}
+
+		for contentType, media := range response.Content {
+			// pages are not JSON, only their content type is checked
+			if contentType == openapi.ContentTypeHTML || !strings.HasPrefix(ww.Header().Get("Content-Type"), contentType) {
+				continue
+			}
+
+			for _, p := range h.openAPIDoc.ValidateJSON(media.Schema, buf.Bytes()) {
+				logger.Warnf("Response does not match the OpenAPI document: %s", p)
+			}
+		}
+	})
+}
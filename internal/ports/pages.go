@@ -0,0 +1,59 @@
+package ports
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/pkg/openapi"
+)
+
+// isForm tells the request was posted by an HTML form, it is answered with
+// a page instead of JSON.
+func isForm(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), openapi.ContentTypeForm)
+}
+
+// renderPage writes a localized page. The pages carry tokens from email
+// links, so they are not cached, framed or sent in Referer.
+func (h *HttpServer) renderPage(w http.ResponseWriter, r *http.Request, status int, name string, data *i18n.PageData) {
+	var buf bytes.Buffer
+	if err := i18n.RenderPage(&buf, acceptLanguage(r), name, data); err != nil {
+		h.InternalError(apperrors.SlugInternalServerError, err, w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", openapi.ContentTypeHTML+"; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; form-action 'self'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// renderErrorPage is RespondWithAppError for pages.
+func (h *HttpServer) renderErrorPage(err error, w http.ResponseWriter, r *http.Request) {
+	status, slug := http.StatusInternalServerError, apperrors.SlugInternalServerError
+	if appError, ok := apperrors.As(err); ok {
+		slug = appError.Slug()
+		switch appError.ErrorType() {
+		case apperrors.ErrorTypeAuthorization:
+			status = http.StatusUnauthorized
+		case apperrors.ErrorTypeIncorrectInput:
+			status = http.StatusBadRequest
+		case apperrors.ErrorNotFound:
+			status = http.StatusNotFound
+		case apperrors.ErrorTypeRateLimited:
+			status = http.StatusTooManyRequests
+		}
+	}
+
+	if status == http.StatusInternalServerError {
+		h.app.Logger.WithError(err).Error("Page request failed")
+	}
+
+	message, _ := i18n.Message(acceptLanguage(r), slug)
+	h.renderPage(w, r, status, i18n.PageError, &i18n.PageData{Message: message})
+}
@@ -0,0 +1,80 @@
+package ports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type PasswordResetRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,gte=5"`
+}
+
+// passwordResetPage is opened from the password reset email.
+func (h *HttpServer) passwordResetPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.renderErrorPage(apperrors.NewIncorrectInputError("token is required", apperrors.SlugFieldTokenRequired), w, r)
+		return
+	}
+
+	h.renderPage(w, r, http.StatusOK, i18n.PagePasswordReset, &i18n.PageData{Action: service.PasswordResetPath, Token: token})
+}
+
+// passwordReset sets a new password with the emailed token, the token is
+// the only credential so the route is public. Forms posted by
+// passwordResetPage get a page back.
+func (h *HttpServer) passwordReset(w http.ResponseWriter, r *http.Request) {
+	var request PasswordResetRequest
+	if isForm(r) {
+		request = PasswordResetRequest{
+			Token:       r.PostFormValue("token"),
+			NewPassword: r.PostFormValue("new_password"),
+		}
+	} else {
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+
+		if err := json.Unmarshal(body, &request); err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+	}
+
+	if err := h.validator.Struct(request); err != nil {
+		errs := err.(validator.ValidationErrors)
+		if isForm(r) {
+			h.renderErrorPage(apperrors.NewIncorrectInputError(errs.Error(), validationSlug(errs[0])), w, r)
+			return
+		}
+		h.RespondValidationError(errs, w, r)
+		return
+	}
+
+	if err := h.app.PasswordResetService.Reset(r.Context(), request.Token, request.NewPassword); err != nil {
+		if isForm(r) {
+			h.renderErrorPage(err, w, r)
+			return
+		}
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	if isForm(r) {
+		h.renderPage(w, r, http.StatusOK, i18n.PagePasswordResetDone, &i18n.PageData{})
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
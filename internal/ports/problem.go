@@ -0,0 +1,106 @@
+package ports
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/bysoft-wallet/users/internal/app/i18n"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// problemType is used for every problem, the slug tells them apart.
+	problemType = "about:blank"
+)
+
+// ProblemResponse is an RFC 7807 problem details body extended with the
+// error slug and the invalid fields.
+type ProblemResponse struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Slug     string         `json:"slug"`
+	Errors   []FieldProblem `json:"errors,omitempty"`
+}
+
+type FieldProblem struct {
+	Field  string `json:"field"`
+	Slug   string `json:"slug"`
+	Detail string `json:"detail"`
+}
+
+func newProblem(r *http.Request, status int, slug, detail string) *ProblemResponse {
+	return &ProblemResponse{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: middleware.GetReqID(r.Context()),
+		Slug:     slug,
+	}
+}
+
+func fieldProblems(errs []validator.FieldError) []FieldProblem {
+	problems := make([]FieldProblem, 0, len(errs))
+	for _, err := range errs {
+		problems = append(problems, FieldProblem{
+			Field:  err.Field(),
+			Slug:   validationSlug(err),
+			Detail: fieldDetail(err),
+		})
+	}
+
+	return problems
+}
+
+func fieldDetail(err validator.FieldError) string {
+	if err.Param() != "" {
+		return "failed on the " + err.Tag() + "=" + err.Param() + " rule"
+	}
+
+	return "failed on the " + err.Tag() + " rule"
+}
+
+// respondWithProblem writes the problem, or only its slug and message for
+// clients served in the legacy error format.
+func (h *HttpServer) respondWithProblem(w http.ResponseWriter, r *http.Request, p *ProblemResponse) {
+	l := locale(r)
+	w.Header().Set("Content-Language", string(l))
+
+	if h.legacyErrors(r) {
+		message, _ := i18n.Message(l, p.Slug)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(p.Status)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{Slug: p.Slug, Message: message})
+		return
+	}
+
+	localize(l, p)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// legacyErrors tells whether the request gets the old {"slug": ...} body,
+// clients asking for problem+json always get problems.
+func (h *HttpServer) legacyErrors(r *http.Request) bool {
+	return h.config.LegacyErrors && !strings.Contains(r.Header.Get("Accept"), problemContentType)
+}
+
+// jsonFieldName makes validation errors report the JSON names of fields.
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+
+	return name
+}
@@ -0,0 +1,111 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type proxiedKey struct{}
+
+// ParseTrustedProxies parses proxy IPs and CIDR ranges, e.g. 10.0.0.0/8.
+func ParseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP or a CIDR range", item)
+			}
+
+			bits := 8 * net.IPv6len
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or a CIDR range", item)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+// RealIP replaces RemoteAddr with the client address a trusted proxy
+// forwarded in X-Forwarded-For or X-Real-IP. The forwarding headers of other
+// peers are ignored, any client can set them.
+func (h *HttpServer) RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.isTrustedProxy(net.ParseIP(clientIp(r))) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ip := h.forwardedIp(r); ip != "" {
+			r.RemoteAddr = ip
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxiedKey{}, true)))
+	})
+}
+
+// forwardedIp is the rightmost X-Forwarded-For address that is not a trusted
+// proxy, the addresses left of it were set by the client.
+func (h *HttpServer) forwardedIp(r *http.Request) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+
+			client = ip.String()
+			if !h.isTrustedProxy(ip) {
+				break
+			}
+		}
+
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+func (h *HttpServer) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range h.config.TrustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isProxied reports whether a trusted proxy sent the request, only then its
+// X-Forwarded-* headers are relied on.
+func isProxied(r *http.Request) bool {
+	proxied, _ := r.Context().Value(proxiedKey{}).(bool)
+	return proxied
+}
@@ -0,0 +1,133 @@
+package ports
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.10 ", "2001:db8::/32", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"10.0.0.0/8", "192.0.2.10/32", "2001:db8::/32"}
+	if len(proxies) != len(want) {
+		t.Fatalf("%d proxies, want %d", len(proxies), len(want))
+	}
+	for i, proxy := range proxies {
+		if proxy.String() != want[i] {
+			t.Errorf("proxy %d = %s, want %s", i, proxy, want[i])
+		}
+	}
+
+	for _, invalid := range []string{"proxy.local", "10.0.0.0/33", "192.0.2"} {
+		if _, err := ParseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("%q is accepted", invalid)
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	h := &HttpServer{config: HttpConfig{TrustedProxies: proxies}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"direct client", "198.51.100.7:1234", nil, "198.51.100.7:1234"},
+		{"forged by a client", "198.51.100.7:1234", http.Header{"X-Forwarded-For": {"192.0.2.1"}, "X-Real-Ip": {"192.0.2.1"}}, "198.51.100.7:1234"},
+		{"real ip of a proxy", "10.0.0.2:1234", http.Header{"X-Real-Ip": {"192.0.2.1"}}, "192.0.2.1"},
+		{"forwarded for", "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"192.0.2.1"}}, "192.0.2.1"},
+		{"client prepends a forged hop", "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"203.0.113.5, 192.0.2.1, 10.0.0.3"}}, "192.0.2.1"},
+		{"hops in several headers", "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"203.0.113.5", "192.0.2.1"}}, "192.0.2.1"},
+		{"only proxies", "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"10.0.0.4, 10.0.0.3"}}, "10.0.0.4"},
+		{"garbage", "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"unknown"}}, "10.0.0.2:1234"},
+		{"no headers from a proxy", "10.0.0.2:1234", nil, "10.0.0.2:1234"},
+	}
+
+	for _, test := range tests {
+		var got string
+		handler := h.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.RemoteAddr
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		r.RemoteAddr = test.remoteAddr
+		for name, values := range test.header {
+			r.Header[name] = values
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != test.want {
+			t.Errorf("%s: RemoteAddr = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// refreshFrom refreshes from the remote address with the extra header.
+func (c *contract) refreshFrom(refresh, remoteAddr string, header http.Header, status int) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	b, _ := json.Marshal(&RefreshRequest{Refresh: refresh})
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/refresh", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	r.RemoteAddr = remoteAddr
+	for name, values := range header {
+		r.Header[name] = values
+	}
+
+	return c.serve(r, status)
+}
+
+func TestRefreshFromAnotherIpViolatesTheBinding(t *testing.T) {
+	store := newMemoryStore()
+	c := newContractWith(t, store, store.Repositories(), app.Config{SessionBinding: string(service.BindingIp), RevokeOnBindingViolation: true})
+	_, pair := c.signUp("ann@example.com", "password")
+
+	// the client IP can't be forged without a trusted proxy
+	w := c.refreshFrom(str(pair, "refresh"), "198.51.100.7:1234", http.Header{"X-Real-Ip": {"192.0.2.1"}, "X-Forwarded-For": {"192.0.2.1"}}, http.StatusUnauthorized)
+	if slug := problemSlug(t, w); slug != apperrors.SlugSessionBindingViolated {
+		t.Errorf("slug %s, want %s", slug, apperrors.SlugSessionBindingViolated)
+	}
+
+	violations := c.auditEvents(audit.EventSessionBindingViolated)
+	if len(violations) != 1 || violations[0].Data["ip"] != "198.51.100.7" || violations[0].Data["revoked"] != true {
+		t.Errorf("violations %v", violations)
+	}
+
+	// the session ended with the violation
+	c.refreshFrom(str(pair, "refresh"), "192.0.2.1:1234", nil, http.StatusUnauthorized)
+}
+
+func TestRefreshThroughATrustedProxyKeepsTheBinding(t *testing.T) {
+	store := newMemoryStore()
+	application := newTestApplicationWith(t, store, store.Repositories(), app.Config{SessionBinding: string(service.BindingIp)})
+	proxies, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	c := &contract{
+		t:       t,
+		h:       NewHttpServer(application, HttpConfig{TrustedProxies: proxies}),
+		store:   store,
+		covered: map[string]bool{},
+	}
+	_, pair := c.signUp("ann@example.com", "password")
+
+	var rotated TokenPairResponse
+	w := c.refreshFrom(str(pair, "refresh"), "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"192.0.2.1"}}, http.StatusOK)
+	if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	c.refreshFrom(rotated.Refresh, "10.0.0.2:1234", http.Header{"X-Forwarded-For": {"198.51.100.7"}}, http.StatusUnauthorized)
+}
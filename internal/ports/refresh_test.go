@@ -0,0 +1,105 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/google/uuid"
+)
+
+// racingSessions lets a concurrent refresh rotate every session right after
+// it is found.
+type racingSessions struct {
+	service.RefreshJWTRepository
+}
+
+func (s racingSessions) Find(ctx context.Context, id, userUUID uuid.UUID, token string) (*service.Session, error) {
+	session, err := s.RefreshJWTRepository.Find(ctx, id, userUUID, token)
+	if err == nil {
+		err = s.RefreshJWTRepository.Delete(ctx, id)
+	}
+
+	return session, err
+}
+
+func (c *contract) refresh(refresh string, status int) map[string]interface{} {
+	c.t.Helper()
+
+	return c.json(http.MethodPost, "/api/v1/refresh", "", &RefreshRequest{Refresh: refresh}, status)
+}
+
+func (c *contract) auditEvents(t audit.EventType) []*audit.Event {
+	c.t.Helper()
+
+	events, err := c.store.Repositories().Audit.Find(context.Background(), &audit.Filter{Types: []string{string(t)}})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return events
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	c := newContract(t)
+	_, pair := c.signUp("ann@example.com", "password")
+
+	rotated := c.refresh(str(pair, "refresh"), http.StatusOK)
+	if str(rotated, "refresh") == str(pair, "refresh") {
+		t.Fatal("the refresh token is not rotated")
+	}
+
+	c.refresh(str(rotated, "refresh"), http.StatusOK)
+	if n := len(c.auditEvents(audit.EventTokenRefreshed)); n != 2 {
+		t.Errorf("%d refreshes recorded, want 2", n)
+	}
+}
+
+func TestRefreshTokenReuseEndsEverySession(t *testing.T) {
+	c := newContract(t)
+	_, pair := c.signUp("ann@example.com", "password")
+	_, other := c.signIn("ann@example.com", "password", "contract-test", "192.0.2.1")
+
+	rotated := c.refresh(str(pair, "refresh"), http.StatusOK)
+	c.refresh(str(pair, "refresh"), http.StatusUnauthorized)
+
+	if n := len(c.auditEvents(audit.EventRefreshTokenReused)); n != 1 {
+		t.Errorf("%d reuses recorded, want 1", n)
+	}
+	revoked := c.auditEvents(audit.EventSessionsRevoked)
+	if len(revoked) == 0 || revoked[0].Data["reason"] != service.RevokeReasonTokenReused {
+		t.Errorf("sessions are not revoked for the reuse: %v", revoked)
+	}
+
+	c.refresh(str(rotated, "refresh"), http.StatusUnauthorized)
+	c.refresh(other, http.StatusUnauthorized)
+}
+
+func TestConcurrentRefreshIsTokenReuse(t *testing.T) {
+	store := newMemoryStore()
+	repositories := store.Repositories()
+	repositories.Sessions = racingSessions{repositories.Sessions}
+	c := newContractWith(t, store, repositories, app.Config{})
+
+	_, pair := c.signUp("ann@example.com", "password")
+	_, other := c.signIn("ann@example.com", "password", "contract-test", "192.0.2.1")
+
+	// the refresh finds the session, the concurrent one deletes it first
+	c.refresh(str(pair, "refresh"), http.StatusUnauthorized)
+
+	sessions, err := store.Repositories().Sessions.FindForUser(context.Background(), uuid.MustParse(str(c.json(http.MethodGet, "/api/v1/me", str(pair, "access"), nil, http.StatusOK), "uuid")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions left after the reuse, want none", len(sessions))
+	}
+	if n := len(c.auditEvents(audit.EventRefreshTokenReused)); n != 1 {
+		t.Errorf("%d reuses recorded, want 1", n)
+	}
+
+	c.refresh(other, http.StatusUnauthorized)
+}
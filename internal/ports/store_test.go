@@ -0,0 +1,935 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bysoft-wallet/users/internal/app"
+	"github.com/bysoft-wallet/users/internal/app/apikey"
+	"github.com/bysoft-wallet/users/internal/app/audit"
+	"github.com/bysoft-wallet/users/internal/app/client"
+	"github.com/bysoft-wallet/users/internal/app/device"
+	appErr "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/event"
+	"github.com/bysoft-wallet/users/internal/app/mail"
+	"github.com/bysoft-wallet/users/internal/app/role"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/user"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/bysoft-wallet/users/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// rolePermissions mirrors the roles seeded by the migrations.
+var rolePermissions = map[string][]string{
+	role.RoleUser:  {role.PermissionProfileRead, role.PermissionProfileWrite},
+	role.RoleAdmin: {role.PermissionProfileRead, role.PermissionProfileWrite, role.PermissionAdmin},
+}
+
+type outboxEvent struct {
+	event         *event.Event
+	nextAttemptAt time.Time
+	publishedAt   *time.Time
+	lastError     string
+}
+
+// memoryStore keeps everything the repositories save, so the application
+// runs without a database. Transactions are not
+// isolated: InTx runs the function as is.
+type memoryStore struct {
+	mu             sync.Mutex
+	users          map[uuid.UUID]*user.User
+	sessions       map[uuid.UUID]*storedSession
+	userRoles      map[uuid.UUID]map[string]bool
+	outbox         []*outboxEvent
+	audit          []*audit.Event
+	devices        map[uuid.UUID]*device.Device
+	impersonations map[uuid.UUID]*service.Impersonation
+	apiKeys        map[uuid.UUID]*apikey.APIKey
+	clients        map[string]*client.Client
+	subscriptions  map[uuid.UUID]*webhook.Subscription
+	deliveries     []*webhook.Delivery
+	messages       []*mail.Message
+}
+
+type storedSession struct {
+	session service.Session
+	token   string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:          map[uuid.UUID]*user.User{},
+		sessions:       map[uuid.UUID]*storedSession{},
+		userRoles:      map[uuid.UUID]map[string]bool{},
+		devices:        map[uuid.UUID]*device.Device{},
+		impersonations: map[uuid.UUID]*service.Impersonation{},
+		apiKeys:        map[uuid.UUID]*apikey.APIKey{},
+		clients:        map[string]*client.Client{},
+		subscriptions:  map[uuid.UUID]*webhook.Subscription{},
+	}
+}
+
+// Repositories returns the repositories saving to the store.
+func (s *memoryStore) Repositories() *app.Repositories {
+	return &app.Repositories{
+		Users:                (*users)(s),
+		Sessions:             (*sessions)(s),
+		Roles:                (*roles)(s),
+		Tx:                   txManager{},
+		Outbox:               (*outbox)(s),
+		Audit:                (*auditLog)(s),
+		Devices:              (*devices)(s),
+		Impersonations:       (*impersonations)(s),
+		APIKeys:              (*apiKeys)(s),
+		Clients:              (*clients)(s),
+		WebhookSubscriptions: (*webhookSubscriptions)(s),
+		WebhookDeliveries:    (*webhookDeliveries)(s),
+	}
+}
+
+// Mailer keeps the sent messages in the store.
+func (s *memoryStore) Mailer() mail.Mailer {
+	return (*mailer)(s)
+}
+
+// Messages returns the sent emails.
+func (s *memoryStore) Messages() []*mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*mail.Message(nil), s.messages...)
+}
+
+type txManager struct{}
+
+func (txManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type mailer memoryStore
+
+func (s *mailer) Send(ctx context.Context, m *mail.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *m
+	s.messages = append(s.messages, &copied)
+	return nil
+}
+
+type users memoryStore
+
+func (s *users) FindById(ctx context.Context, id uuid.UUID) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return &user.User{}, appErr.NewNotFoundError("User not found", appErr.SlugUserNotFound)
+	}
+
+	copied := *u
+	return &copied, nil
+}
+
+func (s *users) FindByEmail(ctx context.Context, email string) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			copied := *u
+			return &copied, nil
+		}
+	}
+
+	return &user.User{}, appErr.NewNotFoundError("User not found", appErr.SlugUserNotFound)
+}
+
+func (s *users) FindByIds(ctx context.Context, ids []uuid.UUID) ([]*user.User, error) {
+	return s.filter(func(u *user.User) bool {
+		for _, id := range ids {
+			if u.UUID == id {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (s *users) FindByEmails(ctx context.Context, emails []string) ([]*user.User, error) {
+	return s.filter(func(u *user.User) bool {
+		for _, email := range emails {
+			if u.Email == email {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (s *users) Search(ctx context.Context, f *user.Filter) ([]*user.User, error) {
+	found := s.filter(func(u *user.User) bool {
+		switch {
+		case f.EmailPrefix != "" && !strings.HasPrefix(u.Email, f.EmailPrefix):
+		case f.CreatedFrom != nil && u.CreatedAt.Before(*f.CreatedFrom):
+		case f.CreatedTo != nil && !u.CreatedAt.Before(*f.CreatedTo):
+		case f.EmailVerified != nil && u.IsEmailVerified() != *f.EmailVerified:
+		case f.After != nil && !before(u.CreatedAt, u.UUID, f.After.CreatedAt, f.After.UUID):
+		default:
+			return true
+		}
+		return false
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		return before(found[j].CreatedAt, found[j].UUID, found[i].CreatedAt, found[i].UUID)
+	})
+
+	if f.Limit > 0 && len(found) > f.Limit {
+		found = found[:f.Limit]
+	}
+
+	return found, nil
+}
+
+func (s *users) filter(match func(u *user.User) bool) []*user.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*user.User
+	for _, u := range s.users {
+		if match(u) {
+			copied := *u
+			found = append(found, &copied)
+		}
+	}
+
+	return found
+}
+
+func (s *users) Add(ctx context.Context, u *user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == u.Email {
+			return appErr.NewConflictError("Email already in use", appErr.SlugEmailAlreadyInUse)
+		}
+	}
+
+	copied := *u
+	s.users[u.UUID] = &copied
+	return nil
+}
+
+func (s *users) UpdateSettings(ctx context.Context, id uuid.UUID, settings *user.Settings) (*user.User, error) {
+	s.update(id, func(u *user.User) { u.Settings = *settings })
+	return s.FindById(ctx, id)
+}
+
+func (s *users) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	s.update(id, func(u *user.User) {
+		u.Hash = hash
+		u.PasswordResetRequired = false
+		u.UpdatedAt = time.Now()
+	})
+	return nil
+}
+
+func (s *users) SetLocked(ctx context.Context, id uuid.UUID, lockedAt *time.Time) error {
+	s.update(id, func(u *user.User) { u.LockedAt = lockedAt })
+	return nil
+}
+
+func (s *users) SetPasswordResetRequired(ctx context.Context, id uuid.UUID, required bool) error {
+	s.update(id, func(u *user.User) { u.PasswordResetRequired = required })
+	return nil
+}
+
+func (s *users) Delete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, id)
+	delete(s.userRoles, id)
+	for sid, session := range s.sessions {
+		if session.session.UserUUID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	return nil
+}
+
+func (s *users) update(id uuid.UUID, change func(u *user.User)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok {
+		change(u)
+	}
+}
+
+type sessions memoryStore
+
+func (s *sessions) Add(ctx context.Context, refresh *jwt.RefreshJWT) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sessions[refresh.Claims.UUID] = &storedSession{
+		session: service.Session{
+			UUID:      refresh.Claims.UUID,
+			UserUUID:  refresh.Claims.UserId,
+			Ip:        refresh.Ip,
+			DeviceId:  refresh.DeviceId,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		token: refresh.Token,
+	}
+	return nil
+}
+
+func (s *sessions) Find(ctx context.Context, id, userUUID uuid.UUID, token string) (*service.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[id]
+	if !ok || stored.session.UserUUID != userUUID || stored.token != token {
+		return &service.Session{}, appErr.NewNotFoundError("Session not found", appErr.SlugSessionNotFound)
+	}
+
+	copied := stored.session
+	return &copied, nil
+}
+
+func (s *sessions) Delete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return appErr.NewNotFoundError("Session not found", appErr.SlugSessionNotFound)
+	}
+
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *sessions) DeleteForUserUUID(ctx context.Context, userUUID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stored := range s.sessions {
+		if stored.session.UserUUID == userUUID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *sessions) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*service.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*service.Session
+	for _, stored := range s.sessions {
+		if stored.session.UserUUID == userUUID {
+			copied := stored.session
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].UpdatedAt.After(found[j].UpdatedAt) })
+	return found, nil
+}
+
+func (s *sessions) CountForUser(ctx context.Context, userUUID uuid.UUID) (int, error) {
+	found, err := s.FindForUser(ctx, userUUID)
+	return len(found), err
+}
+
+func (s *sessions) LockForUser(ctx context.Context, userUUID uuid.UUID) error {
+	return nil
+}
+
+func (s *sessions) DeleteLeastRecentlyUsed(ctx context.Context, userUUID uuid.UUID, keep int) ([]uuid.UUID, error) {
+	found, _ := s.FindForUser(ctx, userUUID)
+	if len(found) <= keep {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []uuid.UUID
+	for _, session := range found[keep:] {
+		delete(s.sessions, session.UUID)
+		deleted = append(deleted, session.UUID)
+	}
+	return deleted, nil
+}
+
+type roles memoryStore
+
+func (s *roles) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*role.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*role.Role
+	for name := range s.userRoles[userUUID] {
+		found = append(found, &role.Role{Name: name, Permissions: rolePermissions[name]})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, nil
+}
+
+func (s *roles) AssignToUser(ctx context.Context, userUUID uuid.UUID, name string) error {
+	if _, ok := rolePermissions[name]; !ok {
+		return appErr.NewNotFoundError("Role not found", appErr.SlugRoleNotFound)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.userRoles[userUUID] == nil {
+		s.userRoles[userUUID] = map[string]bool{}
+	}
+	s.userRoles[userUUID][name] = true
+	return nil
+}
+
+func (s *roles) RevokeFromUser(ctx context.Context, userUUID uuid.UUID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.userRoles[userUUID], name)
+	return nil
+}
+
+type outbox memoryStore
+
+func (s *outbox) Add(ctx context.Context, e *event.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *e
+	s.outbox = append(s.outbox, &outboxEvent{event: &copied, nextAttemptAt: e.OccurredAt})
+	return nil
+}
+
+func (s *outbox) ClaimPending(ctx context.Context, n int, until time.Time) ([]*event.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var claimed []*event.Event
+	for _, stored := range s.outbox {
+		if len(claimed) == n {
+			break
+		}
+
+		if stored.publishedAt != nil || stored.nextAttemptAt.After(now) {
+			continue
+		}
+
+		stored.nextAttemptAt = until
+		copied := *stored.event
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *outbox) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	s.mark(id, func(stored *outboxEvent) {
+		stored.publishedAt = &now
+		stored.lastError = ""
+	})
+	return nil
+}
+
+func (s *outbox) MarkFailed(ctx context.Context, id uuid.UUID, reason string, nextAttemptAt time.Time) error {
+	s.mark(id, func(stored *outboxEvent) {
+		stored.lastError = reason
+		stored.nextAttemptAt = nextAttemptAt
+	})
+	return nil
+}
+
+func (s *outbox) mark(id uuid.UUID, change func(stored *outboxEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.outbox {
+		if stored.event.UUID == id {
+			stored.event.Attempts++
+			change(stored)
+		}
+	}
+}
+
+type auditLog memoryStore
+
+func (s *auditLog) Add(ctx context.Context, e *audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *e
+	s.audit = append(s.audit, &copied)
+	return nil
+}
+
+func (s *auditLog) Find(ctx context.Context, f *audit.Filter) ([]*audit.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*audit.Event
+	for _, e := range s.audit {
+		switch {
+		case f.UserUUID != nil && (e.UserUUID == nil || *e.UserUUID != *f.UserUUID):
+		case f.ActorUUID != nil && (e.ActorUUID == nil || *e.ActorUUID != *f.ActorUUID):
+		case len(f.Types) > 0 && !contains(f.Types, string(e.Type)):
+		case f.Ip != "" && e.Ip != f.Ip:
+		case f.From != nil && e.CreatedAt.Before(*f.From):
+		case f.To != nil && !e.CreatedAt.Before(*f.To):
+		case f.After != nil && !before(e.CreatedAt, e.UUID, f.After.CreatedAt, f.After.UUID):
+		default:
+			copied := *e
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return before(found[j].CreatedAt, found[j].UUID, found[i].CreatedAt, found[i].UUID)
+	})
+
+	if f.Limit > 0 && len(found) > f.Limit {
+		found = found[:f.Limit]
+	}
+
+	return found, nil
+}
+
+type devices memoryStore
+
+func (s *devices) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*device.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*device.Device
+	for _, d := range s.devices {
+		if d.UserUUID == userUUID {
+			copied := *d
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].LastSeenAt.After(found[j].LastSeenAt) })
+	return found, nil
+}
+
+func (s *devices) Add(ctx context.Context, d *device.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.devices {
+		if existing.UserUUID == d.UserUUID && existing.Ip == d.Ip && existing.UserAgent == d.UserAgent {
+			existing.LastSeenAt = d.LastSeenAt
+			return nil
+		}
+	}
+
+	copied := *d
+	s.devices[d.UUID] = &copied
+	return nil
+}
+
+func (s *devices) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.devices[id]; ok {
+		d.LastSeenAt = at
+	}
+	return nil
+}
+
+func (s *devices) Delete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.devices, id)
+	return nil
+}
+
+type impersonations memoryStore
+
+func (s *impersonations) Add(ctx context.Context, i *service.Impersonation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *i
+	s.impersonations[i.UUID] = &copied
+	return nil
+}
+
+func (s *impersonations) FindById(ctx context.Context, id uuid.UUID) (*service.Impersonation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.impersonations[id]
+	if !ok {
+		return &service.Impersonation{}, appErr.NewNotFoundError("Impersonation not found", appErr.SlugImpersonationNotFound)
+	}
+
+	copied := *i
+	return &copied, nil
+}
+
+func (s *impersonations) End(ctx context.Context, id uuid.UUID, endedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.impersonations[id]; ok && i.EndedAt == nil {
+		i.EndedAt = &endedAt
+	}
+	return nil
+}
+
+type apiKeys memoryStore
+
+func (s *apiKeys) Add(ctx context.Context, k *apikey.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *k
+	s.apiKeys[k.UUID] = &copied
+	return nil
+}
+
+func (s *apiKeys) FindByHash(ctx context.Context, hash string) (*apikey.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.Hash == hash {
+			copied := *k
+			return &copied, nil
+		}
+	}
+
+	return &apikey.APIKey{}, appErr.NewNotFoundError("API key not found", appErr.SlugAPIKeyNotFound)
+}
+
+func (s *apiKeys) FindForUser(ctx context.Context, userUUID uuid.UUID) ([]*apikey.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*apikey.APIKey
+	for _, k := range s.apiKeys {
+		if k.UserUUID == userUUID && k.RevokedAt == nil {
+			copied := *k
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.After(found[j].CreatedAt) })
+	return found, nil
+}
+
+func (s *apiKeys) Revoke(ctx context.Context, id, userUUID uuid.UUID, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.apiKeys[id]
+	if !ok || k.UserUUID != userUUID || k.RevokedAt != nil {
+		return appErr.NewNotFoundError("API key not found", appErr.SlugAPIKeyNotFound)
+	}
+
+	k.RevokedAt = &revokedAt
+	return nil
+}
+
+func (s *apiKeys) RevokeForUser(ctx context.Context, userUUID uuid.UUID, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.apiKeys {
+		if k.UserUUID == userUUID && k.RevokedAt == nil {
+			at := revokedAt
+			k.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (s *apiKeys) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.apiKeys[id]; ok {
+		k.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+type clients memoryStore
+
+func (s *clients) FindById(ctx context.Context, id string) (*client.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.clients[id]
+	if !ok {
+		return &client.Client{}, appErr.NewNotFoundError("Client not found", appErr.SlugClientNotFound)
+	}
+
+	copied := *c
+	return &copied, nil
+}
+
+func (s *clients) Add(ctx context.Context, c *client.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[c.ID]; ok {
+		return appErr.NewConflictError("Client already exists", appErr.SlugClientAlreadyExists)
+	}
+
+	copied := *c
+	s.clients[c.ID] = &copied
+	return nil
+}
+
+type webhookSubscriptions memoryStore
+
+func (s *webhookSubscriptions) get(id uuid.UUID) *webhook.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil
+	}
+
+	copied := *sub
+	return &copied
+}
+
+func (s *webhookSubscriptions) Add(ctx context.Context, sub *webhook.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *sub
+	s.subscriptions[sub.UUID] = &copied
+	return nil
+}
+
+func (s *webhookSubscriptions) FindById(ctx context.Context, id uuid.UUID) (*webhook.Subscription, error) {
+	if sub := s.get(id); sub != nil {
+		return sub, nil
+	}
+
+	return &webhook.Subscription{}, appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+}
+
+func (s *webhookSubscriptions) FindAll(ctx context.Context) ([]*webhook.Subscription, error) {
+	return s.filter(func(sub *webhook.Subscription) bool { return true }), nil
+}
+
+func (s *webhookSubscriptions) FindActiveForEvent(ctx context.Context, eventType string) ([]*webhook.Subscription, error) {
+	return s.filter(func(sub *webhook.Subscription) bool {
+		return !sub.IsDisabled() && sub.Matches(eventType)
+	}), nil
+}
+
+func (s *webhookSubscriptions) filter(match func(sub *webhook.Subscription) bool) []*webhook.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*webhook.Subscription
+	for _, sub := range s.subscriptions {
+		if match(sub) {
+			copied := *sub
+			found = append(found, &copied)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.Before(found[j].CreatedAt) })
+	return found
+}
+
+func (s *webhookSubscriptions) RecordFailure(ctx context.Context, id uuid.UUID, disableAfter int, at time.Time) error {
+	return s.update(id, func(sub *webhook.Subscription) {
+		sub.FailureCount++
+		if sub.DisabledAt == nil && sub.FailureCount >= disableAfter {
+			sub.DisabledAt = &at
+		}
+		sub.UpdatedAt = at
+	})
+}
+
+func (s *webhookSubscriptions) ResetFailures(ctx context.Context, id uuid.UUID) error {
+	return s.update(id, func(sub *webhook.Subscription) { sub.FailureCount = 0 })
+}
+
+func (s *webhookSubscriptions) Enable(ctx context.Context, id uuid.UUID) error {
+	return s.update(id, func(sub *webhook.Subscription) {
+		sub.DisabledAt = nil
+		sub.FailureCount = 0
+	})
+}
+
+func (s *webhookSubscriptions) Delete(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+	}
+
+	delete(s.subscriptions, id)
+	kept := s.deliveries[:0]
+	for _, d := range s.deliveries {
+		if d.SubscriptionUUID != id {
+			kept = append(kept, d)
+		}
+	}
+	s.deliveries = kept
+	return nil
+}
+
+func (s *webhookSubscriptions) update(id uuid.UUID, change func(sub *webhook.Subscription)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return appErr.NewNotFoundError("Webhook not found", appErr.SlugWebhookNotFound)
+	}
+
+	change(sub)
+	return nil
+}
+
+type webhookDeliveries memoryStore
+
+func (s *webhookDeliveries) all() []*webhook.Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]*webhook.Delivery, 0, len(s.deliveries))
+	for _, d := range s.deliveries {
+		copied := *d
+		all = append(all, &copied)
+	}
+
+	return all
+}
+
+func (s *webhookDeliveries) Add(ctx context.Context, d *webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.deliveries {
+		if d.ReplayOf == nil && existing.ReplayOf == nil && existing.SubscriptionUUID == d.SubscriptionUUID && existing.EventUUID == d.EventUUID {
+			return nil
+		}
+	}
+
+	copied := *d
+	s.deliveries = append(s.deliveries, &copied)
+	return nil
+}
+
+func (s *webhookDeliveries) FindById(ctx context.Context, id uuid.UUID) (*webhook.Delivery, error) {
+	for _, d := range s.all() {
+		if d.UUID == id {
+			return d, nil
+		}
+	}
+
+	return &webhook.Delivery{}, appErr.NewNotFoundError("Webhook delivery not found", appErr.SlugWebhookDeliveryNotFound)
+}
+
+func (s *webhookDeliveries) Find(ctx context.Context, f *webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	var found []*webhook.Delivery
+	for _, d := range s.all() {
+		if d.SubscriptionUUID == f.SubscriptionUUID && (f.Status == "" || d.Status == f.Status) {
+			found = append(found, d)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].CreatedAt.After(found[j].CreatedAt) })
+	if f.Limit > 0 && len(found) > f.Limit {
+		found = found[:f.Limit]
+	}
+
+	return found, nil
+}
+
+func (s *webhookDeliveries) Claim(ctx context.Context, n int, until time.Time) ([]*webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var claimed []*webhook.Delivery
+	for _, d := range s.deliveries {
+		if len(claimed) == n {
+			break
+		}
+
+		sub, ok := s.subscriptions[d.SubscriptionUUID]
+		if !ok || sub.IsDisabled() || d.Status != webhook.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+
+		d.NextAttemptAt = until
+		copied := *d
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (s *webhookDeliveries) Update(ctx context.Context, d *webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.deliveries {
+		if existing.UUID == d.UUID {
+			copied := *d
+			s.deliveries[i] = &copied
+		}
+	}
+
+	return nil
+}
+
+// before orders like (created_at, uuid) in SQL.
+func before(at time.Time, id uuid.UUID, thanAt time.Time, than uuid.UUID) bool {
+	if !at.Equal(thanAt) {
+		return at.Before(thanAt)
+	}
+
+	return bytes.Compare(id[:], than[:]) < 0
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,267 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/bysoft-wallet/users/internal/app/errors"
+	"github.com/bysoft-wallet/users/internal/app/service"
+	"github.com/bysoft-wallet/users/internal/app/webhook"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// registerWebhookRoutes is mounted inside the admin routes.
+func (h *HttpServer) registerWebhookRoutes(r chi.Router) {
+	r.Get("/", h.adminListWebhooks)
+	r.Post("/", h.adminCreateWebhook)
+	r.Get("/{uuid}", h.adminGetWebhook)
+	r.Delete("/{uuid}", h.adminDeleteWebhook)
+	r.Post("/{uuid}/enable", h.adminEnableWebhook)
+	r.Get("/{uuid}/deliveries", h.adminListWebhookDeliveries)
+	r.Post("/deliveries/{uuid}/replay", h.adminReplayWebhookDelivery)
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required"`
+}
+
+type WebhookResponse struct {
+	UUID         uuid.UUID  `json:"uuid"`
+	URL          string     `json:"url"`
+	EventTypes   []string   `json:"event_types"`
+	FailureCount int        `json:"failure_count"`
+	Disabled     bool       `json:"disabled"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// Secret signs deliveries, it is returned only once on creation.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	UUID          uuid.UUID       `json:"uuid"`
+	EventUUID     uuid.UUID       `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  *int            `json:"response_code"`
+	LastError     *string         `json:"last_error"`
+	ReplayOf      *uuid.UUID      `json:"replay_of"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Body          json.RawMessage `json:"body"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+func (e *WebhookResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if e.Secret != "" {
+		render.Status(r, 201)
+		return nil
+	}
+
+	render.Status(r, 200)
+	return nil
+}
+
+func (e *WebhooksResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func (e *WebhookDeliveryResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 202)
+	return nil
+}
+
+func (e *WebhookDeliveriesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, 200)
+	return nil
+}
+
+func newWebhookResponse(s *webhook.Subscription) WebhookResponse {
+	return WebhookResponse{
+		UUID:         s.UUID,
+		URL:          s.URL,
+		EventTypes:   s.EventTypes,
+		FailureCount: s.FailureCount,
+		Disabled:     s.IsDisabled(),
+		DisabledAt:   s.DisabledAt,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+func newWebhookDeliveryResponse(d *webhook.Delivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		UUID:          d.UUID,
+		EventUUID:     d.EventUUID,
+		EventType:     d.EventType,
+		Status:        string(d.Status),
+		Attempts:      d.Attempts,
+		ResponseCode:  d.ResponseCode,
+		LastError:     d.LastError,
+		ReplayOf:      d.ReplayOf,
+		NextAttemptAt: d.NextAttemptAt,
+		DeliveredAt:   d.DeliveredAt,
+		CreatedAt:     d.CreatedAt,
+		Body:          json.RawMessage(d.Body),
+	}
+}
+
+func (h *HttpServer) adminListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.app.WebhookService.List(r.Context())
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &WebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(subs))}
+	for _, s := range subs {
+		response.Webhooks = append(response.Webhooks, newWebhookResponse(s))
+	}
+
+	render.Render(w, r, response)
+}
+
+func (h *HttpServer) adminCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	var request CreateWebhookRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+		return
+	}
+
+	err = h.validator.Struct(request)
+	if err != nil {
+		h.RespondValidationError(err.(validator.ValidationErrors), w, r)
+		return
+	}
+
+	sub, err := h.app.WebhookService.Create(r.Context(), &service.CreateWebhookRequest{
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+	})
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := newWebhookResponse(sub)
+	response.Secret = sub.Secret
+
+	render.Render(w, r, &response)
+}
+
+func (h *HttpServer) adminGetWebhook(w http.ResponseWriter, r *http.Request) {
+	subUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugWebhookNotFound, err, w, r)
+		return
+	}
+
+	sub, err := h.app.WebhookService.Get(r.Context(), subUUID)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := newWebhookResponse(sub)
+	render.Render(w, r, &response)
+}
+
+func (h *HttpServer) adminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h.adminWebhookAction(h.app.WebhookService.Delete, w, r)
+}
+
+func (h *HttpServer) adminEnableWebhook(w http.ResponseWriter, r *http.Request) {
+	h.adminWebhookAction(h.app.WebhookService.Enable, w, r)
+}
+
+func (h *HttpServer) adminWebhookAction(
+	action func(ctx context.Context, subUUID uuid.UUID) error,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	subUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugWebhookNotFound, err, w, r)
+		return
+	}
+
+	if err = action(r.Context(), subUUID); err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	render.Render(w, r, &StatusResponse{Status: "ok"})
+}
+
+func (h *HttpServer) adminListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	subUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugWebhookNotFound, err, w, r)
+		return
+	}
+
+	filter := &webhook.DeliveryFilter{
+		SubscriptionUUID: subUUID,
+		Status:           webhook.DeliveryStatus(r.URL.Query().Get("status")),
+	}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			h.BadRequest(apperrors.SlugInvalidInput, err, w, r)
+			return
+		}
+	}
+
+	deliveries, err := h.app.WebhookService.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := &WebhookDeliveriesResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, d := range deliveries {
+		response.Deliveries = append(response.Deliveries, newWebhookDeliveryResponse(d))
+	}
+
+	render.Render(w, r, response)
+}
+
+func (h *HttpServer) adminReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryUUID, err := uuid.Parse(chi.URLParam(r, "uuid"))
+	if err != nil {
+		h.NotFound(apperrors.SlugWebhookDeliveryNotFound, err, w, r)
+		return
+	}
+
+	replay, err := h.app.WebhookService.Replay(r.Context(), deliveryUUID)
+	if err != nil {
+		h.RespondWithAppError(err, w, r)
+		return
+	}
+
+	response := newWebhookDeliveryResponse(replay)
+	render.Render(w, r, &response)
+}
@@ -0,0 +1,183 @@
+// Package dpop verifies DPoP proofs (RFC 9449) that bind tokens to a key
+// held by the client.
+package dpop
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// Header carries the proof, NonceHeader the nonce the server expects in
+	// the next proof.
+	Header      = "DPoP"
+	NonceHeader = "DPoP-Nonce"
+	// Scheme is the authorization scheme of DPoP bound access tokens.
+	Scheme = "DPoP"
+
+	proofType = "dpop+jwt"
+
+	DefaultMaxAge   = 60 * time.Second
+	DefaultNonceTTL = 5 * time.Minute
+	// clockSkew tolerates client clocks running slightly ahead.
+	clockSkew = 5 * time.Second
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid dpop proof")
+	// ErrUseNonce means the proof lacks a valid server nonce, the client
+	// retries with the nonce from the NonceHeader.
+	ErrUseNonce = errors.New("dpop nonce required")
+	ErrReplayed = errors.New("dpop proof replayed")
+)
+
+var signingMethods = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "EdDSA"}
+
+type ProofClaims struct {
+	Method          string `json:"htm"`
+	URL             string `json:"htu"`
+	AccessTokenHash string `json:"ath,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Proof is a verified proof, Thumbprint identifies the client key.
+type Proof struct {
+	Claims     ProofClaims
+	Thumbprint string
+}
+
+type Config struct {
+	// MaxAge limits how old a proof may be.
+	MaxAge time.Duration
+	// NonceSecret signs server nonces, they are required when it is set.
+	NonceSecret string
+	NonceTTL    time.Duration
+}
+
+type Verifier struct {
+	maxAge time.Duration
+	cache  ReplayCache
+	nonces *nonceSource
+}
+
+func NewVerifier(config Config, cache ReplayCache) *Verifier {
+	v := &Verifier{
+		maxAge: config.MaxAge,
+		cache:  cache,
+	}
+
+	if v.maxAge <= 0 {
+		v.maxAge = DefaultMaxAge
+	}
+
+	if config.NonceSecret != "" {
+		ttl := config.NonceTTL
+		if ttl <= 0 {
+			ttl = DefaultNonceTTL
+		}
+
+		v.nonces = &nonceSource{secret: []byte(config.NonceSecret), ttl: ttl}
+	}
+
+	return v
+}
+
+// RequiresNonce tells whether proofs must carry a server nonce.
+func (v *Verifier) RequiresNonce() bool {
+	return v.nonces != nil
+}
+
+// Nonce returns a fresh server nonce, empty when nonces are not required.
+func (v *Verifier) Nonce() string {
+	if v.nonces == nil {
+		return ""
+	}
+
+	return v.nonces.issue(time.Now())
+}
+
+// Verify checks the proof was made for the request method and URL, and for
+// accessToken when it is not empty.
+func (v *Verifier) Verify(proof, method, requestURL, accessToken string) (*Proof, error) {
+	var key *JWK
+	t, err := jwt.ParseWithClaims(proof, &ProofClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); typ != proofType {
+			return nil, errors.New("unexpected proof type")
+		}
+
+		var err error
+		if key, err = parseJWK(t.Header["jwk"]); err != nil {
+			return nil, err
+		}
+
+		return key.PublicKey()
+	}, jwt.WithValidMethods(signingMethods), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProof, err)
+	}
+
+	claims := t.Claims.(*ProofClaims)
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: jti and iat are required", ErrInvalidProof)
+	}
+
+	now := time.Now()
+	issued := claims.IssuedAt.Time
+	if issued.After(now.Add(clockSkew)) || now.Sub(issued) > v.maxAge {
+		return nil, fmt.Errorf("%w: proof expired", ErrInvalidProof)
+	}
+
+	if !strings.EqualFold(claims.Method, method) {
+		return nil, fmt.Errorf("%w: method mismatch", ErrInvalidProof)
+	}
+
+	if normalizeURL(claims.URL) != normalizeURL(requestURL) {
+		return nil, fmt.Errorf("%w: url mismatch", ErrInvalidProof)
+	}
+
+	if accessToken != "" && claims.AccessTokenHash != AccessTokenHash(accessToken) {
+		return nil, fmt.Errorf("%w: access token hash mismatch", ErrInvalidProof)
+	}
+
+	if v.nonces != nil && !v.nonces.valid(claims.Nonce, now) {
+		return nil, ErrUseNonce
+	}
+
+	thumbprint, err := key.Thumbprint()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProof, err)
+	}
+
+	// the proof id is only unique per key
+	if !v.cache.Add(thumbprint+":"+claims.ID, issued.Add(v.maxAge+clockSkew)) {
+		return nil, ErrReplayed
+	}
+
+	return &Proof{Claims: *claims, Thumbprint: thumbprint}, nil
+}
+
+// AccessTokenHash is the ath claim value for the access token.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// normalizeURL drops the query and fragment, scheme and host are case
+// insensitive.
+func normalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+}
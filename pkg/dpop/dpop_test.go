@@ -0,0 +1,274 @@
+package dpop
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testMethod = "POST"
+	testURL    = "https://users.example.com/api/v1/refresh"
+)
+
+var proofIds int64
+
+// client holds the key proofs are signed with.
+type client struct {
+	t   *testing.T
+	key *ecdsa.PrivateKey
+	jwk *JWK
+}
+
+func newClient(t *testing.T) *client {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &client{
+		t:   t,
+		key: key,
+		jwk: &JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		},
+	}
+}
+
+func (c *client) thumbprint() string {
+	thumbprint, err := c.jwk.Thumbprint()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return thumbprint
+}
+
+// claims returns valid claims of a fresh proof for the test request.
+func (c *client) claims() ProofClaims {
+	return ProofClaims{
+		Method: testMethod,
+		URL:    testURL,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       strconv.FormatInt(atomic.AddInt64(&proofIds, 1), 10),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+}
+
+func (c *client) sign(claims ProofClaims) string {
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	t.Header["typ"] = proofType
+	t.Header["jwk"] = c.jwk
+
+	proof, err := t.SignedString(c.key)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return proof
+}
+
+func (c *client) proof(change func(claims *ProofClaims)) string {
+	claims := c.claims()
+	if change != nil {
+		change(&claims)
+	}
+
+	return c.sign(claims)
+}
+
+func TestVerify(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{}, NewMemoryReplayCache())
+
+	proof, err := v.Verify(c.proof(nil), testMethod, testURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Thumbprint != c.thumbprint() {
+		t.Errorf("thumbprint %s, want %s", proof.Thumbprint, c.thumbprint())
+	}
+
+	// the query is ignored, the method, scheme and host are case insensitive
+	if _, err = v.Verify(c.proof(nil), "post", "HTTPS://Users.Example.com/api/v1/refresh?x=1", ""); err != nil {
+		t.Errorf("equivalent request: %v", err)
+	}
+
+	if _, err = v.Verify(c.proof(func(claims *ProofClaims) {
+		claims.AccessTokenHash = AccessTokenHash("access")
+	}), testMethod, testURL, "access"); err != nil {
+		t.Errorf("proof for the access token: %v", err)
+	}
+}
+
+func TestVerifyRejectsProofsForAnotherRequest(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{}, NewMemoryReplayCache())
+
+	tests := map[string]func(claims *ProofClaims){
+		"htm":         func(claims *ProofClaims) { claims.Method = "GET" },
+		"htu path":    func(claims *ProofClaims) { claims.URL = "https://users.example.com/api/v1/signIn" },
+		"htu host":    func(claims *ProofClaims) { claims.URL = "https://evil.example.com/api/v1/refresh" },
+		"htu scheme":  func(claims *ProofClaims) { claims.URL = "http://users.example.com/api/v1/refresh" },
+		"missing ath": func(claims *ProofClaims) {},
+		"ath":         func(claims *ProofClaims) { claims.AccessTokenHash = AccessTokenHash("another access") },
+		"missing jti": func(claims *ProofClaims) { claims.ID = "" },
+		"missing iat": func(claims *ProofClaims) { claims.IssuedAt = nil },
+	}
+
+	for name, change := range tests {
+		if _, err := v.Verify(c.proof(change), testMethod, testURL, "access"); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: %v, want %v", name, err, ErrInvalidProof)
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedProofs(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{}, NewMemoryReplayCache())
+
+	wrongType := jwt.NewWithClaims(jwt.SigningMethodES256, c.claims())
+	wrongType.Header["typ"] = "JWT"
+	wrongType.Header["jwk"] = c.jwk
+
+	// the embedded key is not the one the proof is signed with
+	anotherKey := jwt.NewWithClaims(jwt.SigningMethodES256, c.claims())
+	anotherKey.Header["typ"] = proofType
+	anotherKey.Header["jwk"] = newClient(t).jwk
+
+	privateKey := jwt.NewWithClaims(jwt.SigningMethodES256, c.claims())
+	privateKey.Header["typ"] = proofType
+	privateKey.Header["jwk"] = &JWK{Kty: c.jwk.Kty, Crv: c.jwk.Crv, X: c.jwk.X, Y: c.jwk.Y, D: "secret"}
+
+	for name, token := range map[string]*jwt.Token{"typ": wrongType, "signature": anotherKey, "private jwk": privateKey} {
+		proof, err := token.SignedString(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = v.Verify(proof, testMethod, testURL, ""); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: %v, want %v", name, err, ErrInvalidProof)
+		}
+	}
+
+	if _, err := v.Verify("not a proof", testMethod, testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("garbage: %v, want %v", err, ErrInvalidProof)
+	}
+}
+
+func TestVerifyRejectsExpiredProofs(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{MaxAge: 30 * time.Second}, NewMemoryReplayCache())
+
+	issuedAt := func(at time.Time) func(claims *ProofClaims) {
+		return func(claims *ProofClaims) { claims.IssuedAt = jwt.NewNumericDate(at) }
+	}
+
+	if _, err := v.Verify(c.proof(issuedAt(time.Now().Add(-time.Minute))), testMethod, testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expired proof: %v, want %v", err, ErrInvalidProof)
+	}
+
+	if _, err := v.Verify(c.proof(issuedAt(time.Now().Add(time.Minute))), testMethod, testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("proof from the future: %v, want %v", err, ErrInvalidProof)
+	}
+
+	// client clocks may run slightly ahead
+	if _, err := v.Verify(c.proof(issuedAt(time.Now().Add(2*time.Second))), testMethod, testURL, ""); err != nil {
+		t.Errorf("proof within the clock skew: %v", err)
+	}
+}
+
+func TestVerifyRejectsReplays(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{}, NewMemoryReplayCache())
+
+	proof := c.proof(nil)
+	if _, err := v.Verify(proof, testMethod, testURL, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Verify(proof, testMethod, testURL, ""); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replayed proof: %v, want %v", err, ErrReplayed)
+	}
+
+	// proof ids are only unique per key
+	other := newClient(t)
+	claims := c.claims()
+	if _, err := v.Verify(c.sign(claims), testMethod, testURL, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(other.sign(claims), testMethod, testURL, ""); err != nil {
+		t.Errorf("same jti of another key: %v", err)
+	}
+}
+
+func TestVerifyNonces(t *testing.T) {
+	c := newClient(t)
+	v := NewVerifier(Config{NonceSecret: "secret", NonceTTL: time.Minute}, NewMemoryReplayCache())
+	if !v.RequiresNonce() {
+		t.Fatal("nonces are not required with a secret")
+	}
+
+	withNonce := func(nonce string) func(claims *ProofClaims) {
+		return func(claims *ProofClaims) { claims.Nonce = nonce }
+	}
+
+	if _, err := v.Verify(c.proof(withNonce(v.Nonce())), testMethod, testURL, ""); err != nil {
+		t.Errorf("issued nonce: %v", err)
+	}
+
+	if _, err := v.Verify(c.proof(withNonce(v.nonces.issue(time.Now().Add(-30*time.Second)))), testMethod, testURL, ""); err != nil {
+		t.Errorf("nonce within its lifetime: %v", err)
+	}
+
+	foreign := NewVerifier(Config{NonceSecret: "another secret"}, NewMemoryReplayCache())
+	tampered := []byte(v.Nonce())
+	tampered[0] ^= 1
+
+	for name, nonce := range map[string]string{
+		"missing":           "",
+		"expired":           v.nonces.issue(time.Now().Add(-2 * time.Minute)),
+		"from the future":   v.nonces.issue(time.Now().Add(2 * time.Minute)),
+		"of another secret": foreign.Nonce(),
+		"tampered":          string(tampered),
+		"not a nonce":       "nonce",
+	} {
+		if _, err := v.Verify(c.proof(withNonce(nonce)), testMethod, testURL, ""); !errors.Is(err, ErrUseNonce) {
+			t.Errorf("%s nonce: %v, want %v", name, err, ErrUseNonce)
+		}
+	}
+
+	if NewVerifier(Config{}, NewMemoryReplayCache()).Nonce() != "" {
+		t.Error("a nonce is issued without a secret")
+	}
+}
+
+func TestMemoryReplayCache(t *testing.T) {
+	cache := NewMemoryReplayCache()
+	now := time.Now()
+
+	if !cache.Add("a", now.Add(time.Minute)) {
+		t.Error("new id is reported as seen")
+	}
+	if cache.Add("a", now.Add(time.Minute)) {
+		t.Error("seen id is reported as new")
+	}
+
+	// an expired id may be used again
+	cache.Add("b", now.Add(-time.Second))
+	if !cache.Add("b", now.Add(time.Minute)) {
+		t.Error("expired id is reported as seen")
+	}
+}
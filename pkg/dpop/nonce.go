@@ -0,0 +1,47 @@
+package dpop
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+)
+
+const nonceMacSize = 16
+
+// nonceSource issues stateless server nonces: a timestamp with its HMAC, so
+// any instance sharing the secret accepts them until they expire.
+type nonceSource struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func (n *nonceSource) issue(now time.Time) string {
+	b := make([]byte, 8, 8+nonceMacSize)
+	binary.BigEndian.PutUint64(b, uint64(now.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(append(b, n.mac(b)...))
+}
+
+func (n *nonceSource) valid(nonce string, now time.Time) bool {
+	b, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(b) != 8+nonceMacSize {
+		return false
+	}
+
+	if !hmac.Equal(b[8:], n.mac(b[:8])) {
+		return false
+	}
+
+	issued := time.Unix(int64(binary.BigEndian.Uint64(b[:8])), 0)
+
+	return !issued.After(now.Add(time.Minute)) && now.Sub(issued) <= n.ttl
+}
+
+func (n *nonceSource) mac(b []byte) []byte {
+	m := hmac.New(sha256.New, n.secret)
+	m.Write(b)
+
+	return m.Sum(nil)[:nonceMacSize]
+}
@@ -0,0 +1,49 @@
+package dpop
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers proof ids until they expire.
+type ReplayCache interface {
+	// Add stores the id and reports false when it was already seen.
+	Add(id string, expires time.Time) bool
+}
+
+const sweepInterval = time.Minute
+
+// MemoryReplayCache keeps proof ids in process memory, every instance of the
+// service has its own cache.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{seen: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+func (c *MemoryReplayCache) Add(id string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > sweepInterval {
+		for k, exp := range c.seen {
+			if exp.Before(now) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.seen[id]; ok && exp.After(now) {
+		return false
+	}
+
+	c.seen[id] = expires
+
+	return true
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
@@ -15,13 +16,47 @@ type JWTService struct {
 }
 
 type AccessClaims struct {
-	UserId uuid.UUID
+	UserId        uuid.UUID
+	Email         string                 `json:"email,omitempty"`
+	Name          string                 `json:"name,omitempty"`
+	EmailVerified *bool                  `json:"email_verified,omitempty"`
+	Currency      string                 `json:"currency,omitempty"`
+	Locale        string                 `json:"locale,omitempty"`
+	Roles         []string               `json:"roles,omitempty"`
+	Scope         string                 `json:"scope,omitempty"`
+	Extra         map[string]interface{} `json:"ext,omitempty"`
+	Act           *ActorClaim            `json:"act,omitempty"`
+	ClientId      string                 `json:"client_id,omitempty"`
+	Cnf           *Confirmation          `json:"cnf,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Confirmation binds a token to the key of a DPoP proof (RFC 9449).
+type Confirmation struct {
+	Jkt string `json:"jkt"`
+}
+
+// ActorClaim identifies the party acting on behalf of the token subject (RFC 8693).
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
 type RefreshClaims struct {
 	UUID   uuid.UUID
 	UserId uuid.UUID
+	Cnf    *Confirmation `json:"cnf,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActionClaims authorize a single action sent to the user out of band, e.g.
+// a link in an email. They carry no UserId so they are never accepted as
+// access tokens.
+type ActionClaims struct {
+	Action    string    `json:"action"`
+	SessionId uuid.UUID `json:"sid,omitempty"`
+	// Fingerprint binds the token to state the action changes, e.g. the
+	// password hash, so the token can be used only once.
+	Fingerprint string `json:"fpr,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -31,9 +66,10 @@ type AccessJWT struct {
 }
 
 type RefreshJWT struct {
-	Claims RefreshClaims
-	Token  string
-	Ip     string
+	Claims   RefreshClaims
+	Token    string
+	Ip       string
+	DeviceId string
 }
 
 type JWTConfig struct {
@@ -42,12 +78,96 @@ type JWTConfig struct {
 	RefreshTTL *int
 }
 
-func NewAccessClaims(UserId uuid.UUID, Email, Name string) *AccessClaims {
+func NewAccessClaims(UserId uuid.UUID) *AccessClaims {
 	return &AccessClaims{
 		UserId: UserId,
 	}
 }
 
+// NewServiceClaims returns claims of a token issued to a machine client
+// rather than to a user.
+func NewServiceClaims(ClientId string, scopes []string) *AccessClaims {
+	c := &AccessClaims{
+		ClientId: ClientId,
+	}
+	c.Subject = ClientId
+	c.AddScopes(scopes...)
+
+	return c
+}
+
+// Thumbprint returns the DPoP key thumbprint the token is bound to, empty
+// for bearer tokens.
+func (c *AccessClaims) Thumbprint() string {
+	if c.Cnf == nil {
+		return ""
+	}
+
+	return c.Cnf.Jkt
+}
+
+func (c *AccessClaims) IsService() bool {
+	return c.ClientId != "" && c.UserId == uuid.Nil
+}
+
+func (c *AccessClaims) IsImpersonated() bool {
+	return c.Act != nil
+}
+
+// Scopes returns the space-delimited scope claim as a list.
+func (c *AccessClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func (c *AccessClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *AccessClaims) AddScopes(scopes ...string) {
+	for _, s := range scopes {
+		if !c.HasScope(s) {
+			c.Scope = strings.TrimSpace(c.Scope + " " + s)
+		}
+	}
+}
+
+func (c *AccessClaims) SetExpiresAt(t time.Time) {
+	c.ExpiresAt = jwt.NewNumericDate(t)
+}
+
+// SetExtra stores a custom claim under the "ext" object, so callers can
+// contribute claims without extending AccessClaims itself.
+func (c *AccessClaims) SetExtra(key string, value interface{}) {
+	if c.Extra == nil {
+		c.Extra = map[string]interface{}{}
+	}
+	c.Extra[key] = value
+}
+
+func NewActionClaims(action string, UserId, id uuid.UUID) *ActionClaims {
+	return &ActionClaims{
+		Action: action,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      id.String(),
+			Subject: UserId.String(),
+		},
+	}
+}
+
+func (c *RefreshClaims) Thumbprint() string {
+	if c.Cnf == nil {
+		return ""
+	}
+
+	return c.Cnf.Jkt
+}
+
 func NewRefreshClaims(UserId uuid.UUID) *RefreshClaims {
 	return &RefreshClaims{
 		UserId: UserId,
@@ -67,7 +187,11 @@ func (h *JWTService) CreateAccess(c AccessClaims) (*AccessJWT, error) {
 		return &AccessJWT{}, errors.New("jwt access ttl configuration must be provided")
 	}
 
-	c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Duration(*h.accessTTL) * time.Second))
+	return h.CreateAccessWithTTL(c, time.Duration(*h.accessTTL)*time.Second)
+}
+
+func (h *JWTService) CreateAccessWithTTL(c AccessClaims, ttl time.Duration) (*AccessJWT, error) {
+	c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(ttl))
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
 
 	sign, err := token.SignedString([]byte(h.secret))
@@ -111,8 +235,13 @@ func (h *JWTService) ValidateAccess(token string) (*AccessJWT, error) {
 		return &AccessJWT{}, errors.New("invalid token")
 	}
 
+	claims := *t.Claims.(*AccessClaims)
+	if claims.UserId == uuid.Nil && claims.ClientId == "" {
+		return &AccessJWT{}, errors.New("invalid token")
+	}
+
 	return &AccessJWT{
-		Claims: *t.Claims.(*AccessClaims),
+		Claims: claims,
 		Token:  token,
 	}, nil
 }
@@ -120,7 +249,7 @@ func (h *JWTService) ValidateAccess(token string) (*AccessJWT, error) {
 func (h *JWTService) ValidateRefresh(token, ip string) (*RefreshJWT, error) {
 	t, err := jwt.ParseWithClaims(token, &RefreshClaims{}, h.validateParsed)
 	if err != nil {
-		return &RefreshJWT{}, nil
+		return &RefreshJWT{}, err
 	}
 	claims := *t.Claims.(*RefreshClaims)
 
@@ -135,6 +264,28 @@ func (h *JWTService) ValidateRefresh(token, ip string) (*RefreshJWT, error) {
 	}, nil
 }
 
+func (h *JWTService) CreateAction(c ActionClaims, ttl time.Duration) (string, error) {
+	c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(ttl))
+	c.IssuedAt = jwt.NewNumericDate(time.Now())
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(h.secret))
+}
+
+// ValidateAction parses an action token and checks it was issued for action.
+func (h *JWTService) ValidateAction(token, action string) (*ActionClaims, error) {
+	t, err := jwt.ParseWithClaims(token, &ActionClaims{}, h.validateParsed)
+	if err != nil {
+		return &ActionClaims{}, err
+	}
+
+	claims := t.Claims.(*ActionClaims)
+	if !t.Valid || claims.Action != action {
+		return &ActionClaims{}, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
 func (h *JWTService) validateParsed(parsed *jwt.Token) (interface{}, error) {
 	if _, ok := parsed.Method.(*jwt.SigningMethodHMAC); !ok {
 		return nil, fmt.Errorf("unexpected signing method: %v", parsed.Header["alg"])
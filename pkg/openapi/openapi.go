@@ -0,0 +1,288 @@
+// Package openapi builds an OpenAPI 3 document with schemas derived from Go
+// types and checks JSON values against them.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Version = "3.0.3"
+
+	ContentTypeJSON    = "application/json"
+	ContentTypeProblem = "application/problem+json"
+	ContentTypeForm    = "application/x-www-form-urlencoded"
+	ContentTypeHTML    = "text/html"
+)
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Route describes an operation. Request and the Responses values are zero
+// values of the Go types sent and returned, their schemas are generated.
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	// Security lists the accepted security schemes, any one of them is
+	// enough. Empty means a public route.
+	Security []string
+	Query    []*Parameter
+	Request  interface{}
+	// Form tells the request body may also be form encoded.
+	Form bool
+	// HTML tells the responses may also be HTML pages, e.g. to forms posted
+	// from a browser.
+	HTML bool
+	// Responses maps statuses to the returned types, nil for no body.
+	// Several types for one status are alternatives.
+	Responses map[int][]interface{}
+}
+
+type Builder struct {
+	doc     *Document
+	schemas *schemaGenerator
+	// errorType is returned by every operation as the default response.
+	errorType interface{}
+}
+
+func NewBuilder(title, version string, errorType interface{}) *Builder {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{},
+		},
+	}
+
+	return &Builder{
+		doc:       doc,
+		schemas:   &schemaGenerator{components: doc.Components.Schemas},
+		errorType: errorType,
+	}
+}
+
+func (b *Builder) AddSecurityScheme(name string, s *SecurityScheme) {
+	b.doc.Components.SecuritySchemes[name] = s
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (b *Builder) Add(route *Route) {
+	op := &Operation{
+		Summary:   route.Summary,
+		Responses: map[string]*Response{},
+	}
+
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, route.Query...)
+
+	for _, s := range route.Security {
+		op.Security = append(op.Security, map[string][]string{s: {}})
+	}
+
+	if route.Request != nil {
+		schema := b.schemas.request(reflect.TypeOf(route.Request))
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{ContentTypeJSON: {Schema: schema}},
+		}
+
+		if route.Form {
+			op.RequestBody.Content[ContentTypeForm] = &MediaType{Schema: schema}
+		}
+	}
+
+	for status, types := range route.Responses {
+		response := &Response{Description: http.StatusText(status)}
+
+		var schemas []*Schema
+		for _, t := range types {
+			if t != nil {
+				schemas = append(schemas, b.schemas.response(reflect.TypeOf(t)))
+			}
+		}
+
+		switch len(schemas) {
+		case 0:
+		case 1:
+			response.Content = map[string]*MediaType{ContentTypeJSON: {Schema: schemas[0]}}
+		default:
+			response.Content = map[string]*MediaType{ContentTypeJSON: {Schema: &Schema{OneOf: schemas}}}
+		}
+
+		if route.HTML {
+			if response.Content == nil {
+				response.Content = map[string]*MediaType{}
+			}
+			response.Content[ContentTypeHTML] = &MediaType{Schema: &Schema{Type: "string"}}
+		}
+
+		op.Responses[strconv.Itoa(status)] = response
+	}
+
+	if b.errorType != nil {
+		op.Responses["default"] = &Response{
+			Description: "Error",
+			Content: map[string]*MediaType{
+				ContentTypeProblem: {Schema: b.schemas.response(reflect.TypeOf(b.errorType))},
+			},
+		}
+
+		if route.HTML {
+			op.Responses["default"].Content[ContentTypeHTML] = &MediaType{Schema: &Schema{Type: "string"}}
+		}
+	}
+
+	item, ok := b.doc.Paths[route.Path]
+	if !ok {
+		item = &PathItem{}
+		b.doc.Paths[route.Path] = item
+	}
+	item.set(route.Method, op)
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+func (p *PathItem) set(method string, op *Operation) {
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		p.Get = op
+	case http.MethodPut:
+		p.Put = op
+	case http.MethodPost:
+		p.Post = op
+	case http.MethodDelete:
+		p.Delete = op
+	case http.MethodPatch:
+		p.Patch = op
+	}
+}
+
+// Operation returns the operation of the method on the path template.
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil, false
+	}
+
+	var op *Operation
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		op = item.Get
+	case http.MethodPut:
+		op = item.Put
+	case http.MethodPost:
+		op = item.Post
+	case http.MethodDelete:
+		op = item.Delete
+	case http.MethodPatch:
+		op = item.Patch
+	}
+
+	return op, op != nil
+}
+
+// Routes lists "METHOD path" of every operation.
+func (d *Document) Routes() []string {
+	var routes []string
+	for path, item := range d.Paths {
+		for method, op := range map[string]*Operation{
+			http.MethodGet:    item.Get,
+			http.MethodPut:    item.Put,
+			http.MethodPost:   item.Post,
+			http.MethodDelete: item.Delete,
+			http.MethodPatch:  item.Patch,
+		} {
+			if op != nil {
+				routes = append(routes, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(routes)
+
+	return routes
+}
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator derives schemas from Go types the way encoding/json
+// marshals them. Response types become components, request types are
+// inlined since their required fields come from validate tags instead.
+type schemaGenerator struct {
+	components map[string]*Schema
+}
+
+func (g *schemaGenerator) response(t reflect.Type) *Schema {
+	return g.schema(t, false)
+}
+
+func (g *schemaGenerator) request(t reflect.Type) *Schema {
+	return g.schema(t, true)
+}
+
+func (g *schemaGenerator) schema(t reflect.Type, request bool) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem(), request)
+		if s.Ref != "" {
+			// $ref siblings are ignored, nullable needs a wrapper
+			return &Schema{OneOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), request), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), request), Nullable: true}
+	case reflect.Struct:
+		if request || t.Name() == "" {
+			return g.object(t, request)
+		}
+
+		if _, ok := g.components[t.Name()]; !ok {
+			// registered before the fields so recursive types terminate
+			g.components[t.Name()] = &Schema{}
+			*g.components[t.Name()] = *g.object(t, request)
+		}
+
+		return &Schema{Ref: refPrefix + t.Name()}
+	default:
+		// interface{} and anything else accept any value
+		return &Schema{}
+	}
+}
+
+func (g *schemaGenerator) object(t reflect.Type, request bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, omitempty, skip := jsonName(f)
+		if skip {
+			continue
+		}
+
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			embedded := g.object(f.Type, request)
+			for n, p := range embedded.Properties {
+				s.Properties[n] = p
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		p := g.schema(f.Type, request)
+		rules := f.Tag.Get("validate")
+		if request {
+			applyRules(p, rules)
+		}
+
+		if (request && hasRule(rules, "required")) || (!request && !omitempty) {
+			s.Required = append(s.Required, name)
+		}
+
+		s.Properties[name] = p
+	}
+
+	return s
+}
+
+func jsonName(f reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = f.Name
+	}
+
+	for _, o := range parts[1:] {
+		if o == "omitempty" {
+			omitempty = true
+		}
+	}
+
+	return name, omitempty, false
+}
+
+func hasRule(rules, rule string) bool {
+	for _, r := range strings.Split(rules, ",") {
+		if r == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyRules documents the validator rules the schema can express.
+func applyRules(s *Schema, rules string) {
+	if s.Type != "string" {
+		return
+	}
+
+	for _, r := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(r, "=")
+		n, err := strconv.Atoi(param)
+
+		switch {
+		case name == "email":
+			s.Format = "email"
+		case name == "url":
+			s.Format = "uri"
+		case (name == "gte" || name == "min") && err == nil:
+			s.MinLength = &n
+		case (name == "lte" || name == "max") && err == nil:
+			s.MaxLength = &n
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Validate checks a decoded JSON value against the schema and returns the
+// mismatches, each prefixed with the path to the offending value.
+func (d *Document) Validate(s *Schema, v interface{}) []string {
+	var problems []string
+	d.validate(s, v, "$", &problems)
+	return problems
+}
+
+// ValidateJSON is Validate for raw JSON.
+func (d *Document) ValidateJSON(s *Schema, data []byte) []string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []string{"$: " + err.Error()}
+	}
+
+	return d.Validate(s, v)
+}
+
+func (d *Document) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+		if !ok {
+			return &Schema{}
+		}
+		s = resolved
+	}
+
+	return s
+}
+
+func (d *Document) validate(s *Schema, v interface{}, path string, problems *[]string) {
+	s = d.resolve(s)
+
+	if v == nil {
+		if !s.Nullable && s.Type != "" {
+			*problems = append(*problems, path+": null is not allowed")
+		}
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			if len(d.Validate(alt, v)) == 0 {
+				return
+			}
+		}
+		*problems = append(*problems, path+": matches none of the alternatives")
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "":
+		// any value
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("expected an object")
+			return
+		}
+
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("%s is required", name)
+			}
+		}
+
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if p, ok := s.Properties[name]; ok {
+				d.validate(p, obj[name], path+"."+name, problems)
+			} else if s.AdditionalProperties != nil {
+				d.validate(s.AdditionalProperties, obj[name], path+"."+name, problems)
+			} else if s.Properties != nil {
+				fail("%s is not documented", name)
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			fail("expected an array")
+			return
+		}
+
+		for i, item := range arr {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected a string")
+			return
+		}
+
+		if s.MinLength != nil && len([]rune(str)) < *s.MinLength {
+			fail("shorter than %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && len([]rune(str)) > *s.MaxLength {
+			fail("longer than %d", *s.MaxLength)
+		}
+
+		switch s.Format {
+		case "uuid":
+			if _, err := uuid.Parse(str); err != nil {
+				fail("expected a uuid")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("expected an RFC 3339 date-time")
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected an integer")
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			fail("expected a number")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected a boolean")
+		}
+	}
+}